@@ -47,7 +47,7 @@ func main() {
 	}
 	defer cleanup() // close connection such as mysql, redis,...
 
-	actionPtr := flag.String("action", "", "action: SyncTrades")
+	actionPtr := flag.String("action", "", "action: SyncTrades, CompressData, ListDeadBlocks, RequeueDeadBlocks, VerifyBackfill, BackfillStatus, ServeRpcFixtures")
 	param1Ptr := flag.String("param1", "", "param1 of action")
 	param2Ptr := flag.String("param2", "", "param2 of action")
 	param3Ptr := flag.String("param3", "", "param3 of action")
@@ -15,3 +15,8 @@ func initSuiWorkerApp(ctx context.Context) (sui_worker.App, func(), error) {
 	wire.Build(sui_worker.GraphSet)
 	return nil, nil, nil
 }
+
+func initOfflineSuiWorkerApp(ctx context.Context) (sui_worker.App, func(), error) {
+	wire.Build(sui_worker.OfflineGraphSet)
+	return nil, nil, nil
+}
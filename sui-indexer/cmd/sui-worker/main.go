@@ -10,6 +10,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/errgroup"
 
+	"feng-sui-core/internal/app/sui-worker"
 	"feng-sui-core/internal/conf"
 	"feng-sui-core/internal/repo/gorm"
 )
@@ -36,13 +37,21 @@ func main() {
 	}
 	logger.Info("env is: ", conf.Config.Env)
 
-	// migration db
-	if err := gorm.RunMigration(ctx); err != nil {
-		logger.Fatalf("failed to migration db: %v", err)
+	// init app, the offline mode only needs the sui rpc
+	var (
+		app     sui_worker.App
+		cleanup func()
+		err     error
+	)
+	if conf.Config.IsOffline() {
+		app, cleanup, err = initOfflineSuiWorkerApp(ctx)
+	} else {
+		// migration db
+		if err := gorm.RunMigration(ctx); err != nil {
+			logger.Fatalf("failed to migration db: %v", err)
+		}
+		app, cleanup, err = initSuiWorkerApp(ctx)
 	}
-
-	// init app
-	app, cleanup, err := initSuiWorkerApp(ctx)
 	if err != nil {
 		logger.Fatalf("failed to init app: %v", err)
 	}
@@ -5,14 +5,27 @@ CREATE TABLE "public"."block_status" (
      "updated_at" timestamptz,
      "chain" varchar NOT NULL,
      "block_number" int8 NOT NULL,
+     "to_block_number" int8 NOT NULL,
      "status" int4 NOT NULL DEFAULT 0,
      "type" int4 NOT NULL DEFAULT 0,
+     "digest" varchar(64),
+     "previous_digest" varchar(64),
+     "event_count" int4 NOT NULL DEFAULT 0,
+     "attempts" int4 NOT NULL DEFAULT 0,
+     "last_error" text,
+     "skip_dedup" bool NOT NULL DEFAULT false,
+     "owner" varchar(100),
+     "heartbeat_at" timestamptz,
+     "lease_expires_at" timestamptz,
      PRIMARY KEY ("id")
 );
+CREATE UNIQUE INDEX "idx_block_status_chain_type_block_number" ON "public"."block_status" ("chain", "type", "block_number");
+CREATE INDEX "idx_block_status_status_lease_expires_at" ON "public"."block_status" ("status", "lease_expires_at");
 
 -- Column Comment
-COMMENT ON COLUMN "public"."block_status"."status" IS '0: NOT_READY, 1: PROCESSING, 2: DONE, 3: FAILED';
+COMMENT ON COLUMN "public"."block_status"."status" IS '0: NOT_READY, 1: PROCESSING, 2: DONE, 3: FAILED, 4: DEAD';
 COMMENT ON COLUMN "public"."block_status"."type" IS '0: REALTIME, 1: BACKFILL';
+COMMENT ON COLUMN "public"."block_status"."to_block_number" IS 'last block of the range, inclusive';
 
 -- Table sui_index
 CREATE TABLE "public"."sui_index" (
@@ -24,4 +37,78 @@ CREATE TABLE "public"."sui_index" (
       "package_id" text,
       "event_type" text,
       PRIMARY KEY ("checkpoint_seq","tx_digest","event_seq")
-);
\ No newline at end of file
+);
+
+-- Table checkpoint_mismatch
+CREATE TABLE "public"."checkpoint_mismatch" (
+     "id" varchar NOT NULL,
+     "created_at" timestamptz,
+     "updated_at" timestamptz,
+     "chain" varchar NOT NULL,
+     "block_number" int8 NOT NULL,
+     "digest" varchar(64),
+     "previous_digest" varchar(64),
+     "neighbour_block_number" int8 NOT NULL,
+     "neighbour_digest" varchar(64),
+     "neighbour_previous_digest" varchar(64),
+     PRIMARY KEY ("id")
+);
+-- Table event_dedup
+CREATE TABLE "public"."event_dedup" (
+     "key" varchar(128) NOT NULL,
+     "expires_at" timestamptz NOT NULL,
+     PRIMARY KEY ("key")
+);
+CREATE INDEX "idx_event_dedup_expires_at" ON "public"."event_dedup" ("expires_at");
+
+-- Table sink_record
+CREATE TABLE "public"."sink_record" (
+     "topic" varchar(255) NOT NULL,
+     "checkpoint" int8 NOT NULL,
+     "idx" int4 NOT NULL,
+     "partition_key" varchar(255),
+     "data" jsonb NOT NULL,
+     PRIMARY KEY ("topic", "checkpoint", "idx")
+);
+
+-- Upgrade block_status for checkpoint chain verification
+ALTER TABLE "public"."block_status" ADD COLUMN IF NOT EXISTS "digest" varchar(64);
+ALTER TABLE "public"."block_status" ADD COLUMN IF NOT EXISTS "previous_digest" varchar(64);
+
+-- Upgrade block_status for gap detection
+ALTER TABLE "public"."block_status" ADD COLUMN IF NOT EXISTS "event_count" int4 NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS "idx_block_status_chain_type_block_number" ON "public"."block_status" ("chain", "type", "block_number");
+
+-- Upgrade block_status for retry counter and dead-letter status
+ALTER TABLE "public"."block_status" ADD COLUMN IF NOT EXISTS "attempts" int4 NOT NULL DEFAULT 0;
+ALTER TABLE "public"."block_status" ADD COLUMN IF NOT EXISTS "last_error" text;
+COMMENT ON COLUMN "public"."block_status"."status" IS '0: NOT_READY, 1: PROCESSING, 2: DONE, 3: FAILED, 4: DEAD';
+
+-- Upgrade block_status for range-based work units with leases
+ALTER TABLE "public"."block_status" ADD COLUMN IF NOT EXISTS "to_block_number" int8;
+UPDATE "public"."block_status" SET "to_block_number" = "block_number" WHERE "to_block_number" IS NULL;
+ALTER TABLE "public"."block_status" ALTER COLUMN "to_block_number" SET NOT NULL;
+ALTER TABLE "public"."block_status" ADD COLUMN IF NOT EXISTS "owner" varchar(100);
+ALTER TABLE "public"."block_status" ADD COLUMN IF NOT EXISTS "heartbeat_at" timestamptz;
+ALTER TABLE "public"."block_status" ADD COLUMN IF NOT EXISTS "lease_expires_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_block_status_status_lease_expires_at" ON "public"."block_status" ("status", "lease_expires_at");
+COMMENT ON COLUMN "public"."block_status"."to_block_number" IS 'last block of the range, inclusive';
+-- optionally merge the existing DONE rows into ranges with script/postgres/merge_block_status_ranges.sql
+
+-- Upgrade block_status for reprocessing ranges without event deduplication
+ALTER TABLE "public"."block_status" ADD COLUMN IF NOT EXISTS "skip_dedup" bool NOT NULL DEFAULT false;
+
+-- Upgrade block_status for idempotent range inserts, a range starting at the same block as an existing one is skipped.
+-- The duplicates inserted by concurrent gap healing are deleted first, the DONE and then the largest range is kept.
+DELETE FROM "public"."block_status" WHERE "id" IN (
+    SELECT "id" FROM (
+        SELECT "id", ROW_NUMBER() OVER (
+            PARTITION BY "chain", "type", "block_number"
+            ORDER BY ("status" = 2) DESC, "to_block_number" DESC, "created_at"
+        ) AS "rn"
+        FROM "public"."block_status"
+    ) AS t
+    WHERE "rn" > 1
+);
+DROP INDEX IF EXISTS "public"."idx_block_status_chain_type_block_number";
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_block_status_chain_type_block_number" ON "public"."block_status" ("chain", "type", "block_number");
@@ -2,18 +2,26 @@ package backfill_indexer
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/getnimbus/ultrago/u_logger"
 	"github.com/getnimbus/ultrago/u_monitor"
 	"golang.org/x/sync/errgroup"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/repo"
+	"feng-sui-core/pkg/alert"
 )
 
 func NewApp(
 	worker Worker,
+	blockStatusRepo repo.BlockStatusRepo,
 ) App {
 	return &app{
-		worker: worker,
+		worker:          worker,
+		blockStatusRepo: blockStatusRepo,
 	}
 }
 
@@ -23,7 +31,8 @@ type App interface {
 }
 
 type app struct {
-	worker Worker
+	worker          Worker
+	blockStatusRepo repo.BlockStatusRepo
 }
 
 func (a *app) Start(ctx context.Context) error {
@@ -45,10 +54,51 @@ func (a *app) Start(ctx context.Context) error {
 		return nil
 	})
 
+	if interval := conf.Config.BackfillProgressInterval; interval > 0 {
+		eg.Go(func() error {
+			a.reportProgress(childCtx, interval)
+			return nil
+		})
+	}
+
 	logger.Info("worker started!")
 	return eg.Wait()
 }
 
+// reportProgress logs and sends to discord the progress of the backfill every interval,
+// with the throughput of all workers and of this process
+func (a *app) reportProgress(ctx context.Context, interval time.Duration) {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	var (
+		ticker   = time.NewTicker(interval)
+		previous = a.worker.Stats()
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		progress, err := a.blockStatusRepo.GetProgress(ctx, entity.BlockStatusType_BACKFILL, interval)
+		if err != nil {
+			logger.Errorf("failed to get backfill progress: %v", err)
+			continue
+		}
+		stats := a.worker.Stats()
+		message := fmt.Sprintf("[sui-indexer] backfill progress: %v\nthis worker: %v done, %v failed, %v skipped, %.2f checkpoints/s, %.2f rpc calls/s",
+			progress, stats.Done, stats.Failed, stats.Skipped,
+			float64(stats.Done-previous.Done)/interval.Seconds(), float64(stats.RpcCalls-previous.RpcCalls)/interval.Seconds())
+		previous = stats
+
+		logger.Info(message)
+		alert.AlertDiscord(ctx, message)
+	}
+}
+
 func (a *app) Stop(ctx context.Context) error {
 	ctx, logger := u_logger.GetLogger(ctx)
 	logger.Infof("worker stopped!")
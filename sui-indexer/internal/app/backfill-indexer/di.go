@@ -13,15 +13,16 @@ import (
 var deps = wire.NewSet(
 	u_http_client.NewHttpExecutor,
 	infra.GraphSet,
-	infra.NewAwsSession,
 	gorm_scope.GraphSet,
 	gorm.GraphSet,
 	service.GraphSet,
-	service.NewS3Service,
+	service.NewBlockRangeService,
 )
 
 var GraphSet = wire.NewSet(
 	deps,
+	NewSink,
+	NewInventory,
 	NewWorker,
 	NewApp,
 )
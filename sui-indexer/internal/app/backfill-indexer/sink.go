@@ -0,0 +1,38 @@
+package backfill_indexer
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/infra"
+	"feng-sui-core/internal/service"
+	"feng-sui-core/internal/sink"
+)
+
+// NewSink returns the sinks of the backfill chosen by BACKFILL_SINKS, s3 files roll up BACKFILL_ROLLUP_CHECKPOINTS checkpoints
+func NewSink(ctx context.Context, db *gorm.DB) (sink.Sink, func(), error) {
+	return sink.New(ctx, conf.Config.BackfillSinks, db, sink.RollupConfig{
+		Checkpoints: conf.Config.BackfillRollupCheckpoints,
+		MaxRecords:  conf.Config.BackfillRollupMaxRecords,
+		MaxWait:     conf.Config.BackfillRollupMaxWait,
+	})
+}
+
+// NewInventory reads back the checkpoints stored by the s3 sink, it is nil unless BACKFILL_SKIP_EXISTING is set
+func NewInventory() (*sink.Inventory, error) {
+	if !conf.Config.IsBackfillSkipExisting() {
+		return nil, nil
+	}
+	if conf.Config.BackfillRollupCheckpoints < 2 {
+		return nil, fmt.Errorf("BACKFILL_SKIP_EXISTING needs the manifests of BACKFILL_ROLLUP_CHECKPOINTS")
+	}
+
+	sess, err := infra.NewAwsSession()
+	if err != nil {
+		return nil, err
+	}
+	return sink.NewS3Inventory(service.NewS3Service(sess), conf.Config.AwsBucket, conf.Config.BackfillRollupCheckpoints), nil
+}
@@ -1,104 +1,128 @@
 package backfill_indexer
 
 import (
-	"compress/gzip"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
-	"net/url"
+	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/alitto/pond"
 	"github.com/avast/retry-go/v4"
-	sui_client "github.com/coming-chat/go-sui/v2/client"
 	"github.com/getnimbus/ultrago/u_logger"
+	"github.com/google/uuid"
 	"github.com/samber/lo"
 	"golang.org/x/sync/errgroup"
-	"gorm.io/gorm"
 
 	"feng-sui-core/internal/conf"
 	"feng-sui-core/internal/entity"
 	"feng-sui-core/internal/entity_dto/sui_model"
-	"feng-sui-core/internal/repo"
+	"feng-sui-core/internal/infra"
 	"feng-sui-core/internal/service"
+	"feng-sui-core/internal/setting"
+	"feng-sui-core/internal/sink"
 	"feng-sui-core/pkg/alert"
+	"feng-sui-core/pkg/sui_rpc"
 )
 
 func NewWorker(
-	blockStatusRepo repo.BlockStatusRepo,
-	baseSvc service.BaseService,
-	s3Svc service.S3Service,
+	blockRangeSvc service.BlockRangeService,
+	sink sink.Sink,
+	inventory *sink.Inventory,
 ) (Worker, error) {
-	var transport *http.Transport
-	if conf.Config.IsUseProxy() {
-		proxyUrl, err := url.Parse(conf.Config.HttpProxy)
-		if err != nil {
-			return nil, err
-		}
-		transport = &http.Transport{Proxy: http.ProxyURL(proxyUrl)}
-	} else {
-		transport = http.DefaultTransport.(*http.Transport)
+	pool, err := service.NewSuiRpcPool()
+	if err != nil {
+		return nil, err
 	}
 
-	client, err := sui_client.DialWithClient(conf.Config.SuiRpc, &http.Client{
-		Transport: transport.Clone(),
-		Timeout:   2 * 60 * time.Second, // 2 mins
-	})
+	hostname, _ := os.Hostname()
+
+	eventFilter, err := sui_model.ReadEventFilterConfig(conf.Config.EventFilterFile)
+	if err != nil {
+		return nil, err
+	}
+
+	signatureVerifier, err := service.NewCheckpointSignatureVerifier(pool)
 	if err != nil {
 		return nil, err
 	}
-	fallbackClient, _ := sui_client.DialWithClient(conf.Config.FallbackSuiRpc, &http.Client{
-		Transport: transport.Clone(),
-		Timeout:   2 * 60 * time.Second, // 2 mins
-	})
 
 	return &worker{
-		blockStatusRepo:  blockStatusRepo,
-		baseSvc:          baseSvc,
-		s3Svc:            s3Svc,
-		suiIndexer:       service.NewSuiIndexer(client, fallbackClient),
-		limitCheckpoints: 10, // maximum is 10
-		numWorkers:       10,
-		cooldown:         1 * time.Second,
-		indexTopic:       conf.Config.SuiIndexTopic,
+		blockRangeSvc:     blockRangeSvc,
+		sink:              sink,
+		inventory:         inventory,
+		owner:             fmt.Sprintf("%v-%v", hostname, uuid.NewString()),
+		suiIndexer:        service.NewSuiIndexer(pool),
+		signatureVerifier: signatureVerifier,
+		limitCheckpoints:  max(10, conf.Config.BackfillRollupCheckpoints), // a claim fills a roll-up window
+		numWorkers:        10,
+		cooldown:          1 * time.Second,
+		indexTopic:        conf.Config.SuiIndexTopic,
+		eventFilter:       eventFilter,
+
+		checkpointsTopic:    conf.Config.SuiCheckpointsTopic,
+		eventsTopic:         conf.Config.SuiEventsTopic,
+		txsTopic:            conf.Config.SuiTxsTopic,
+		objectChangesTopic:  conf.Config.SuiObjectChangesTopic,
+		balanceChangesTopic: conf.Config.SuiBalanceChangesTopic,
 	}, nil
 }
 
 type worker struct {
-	blockStatusRepo  repo.BlockStatusRepo
-	baseSvc          service.BaseService
-	s3Svc            service.S3Service
-	suiIndexer       *service.SuiIndexer
-	limitCheckpoints int
-	numWorkers       int
-	cooldown         time.Duration
-	indexTopic       string
+	blockRangeSvc     service.BlockRangeService
+	sink              sink.Sink
+	inventory         *sink.Inventory
+	suiIndexer        *service.SuiIndexer
+	signatureVerifier service.CheckpointSignatureVerifier
+	owner             string
+	limitCheckpoints  int64
+	numWorkers        int
+	cooldown          time.Duration
+	indexTopic        string
+	eventFilter       *sui_model.EventFilterConfig
+
+	checkpointsTopic    string
+	eventsTopic         string
+	txsTopic            string
+	objectChangesTopic  string
+	balanceChangesTopic string
+
+	// counters of the checkpoints completed by this process
+	done    atomic.Int64
+	failed  atomic.Int64
+	skipped atomic.Int64
 }
 
 type Worker interface {
 	FetchTxs(ctx context.Context) error
+	// Stats returns what the worker did since it started
+	Stats() WorkerStats
+}
+
+// errNoBlockRange is returned by fetchTxs when there is no block range to claim
+var errNoBlockRange = errors.New("no block range to claim")
+
+// WorkerStats counts the checkpoints completed by a worker process and its rpc calls
+type WorkerStats struct {
+	Done     int64
+	Failed   int64
+	Skipped  int64
+	RpcCalls int64
 }
 
 func (w *worker) FetchTxs(ctx context.Context) error {
 	ctx, logger := u_logger.GetLogger(ctx)
 	logger.Info("start fetching txs...")
 
-	var (
-		checkpointCh = make(chan *sui_model.Checkpoint, 300)
-		txsCh        = make(chan []*sui_model.Transaction, 300)
-	)
-	defer func() {
-		close(checkpointCh)
-		close(txsCh)
-	}()
+	var batchCh = make(chan *storeTask, 300)
+	defer close(batchCh)
 
 	eg, childCtx := errgroup.WithContext(ctx)
 	eg.Go(func() error {
-		if err := w.StoreS3(childCtx, checkpointCh, txsCh); err != nil {
+		if err := w.Store(childCtx, batchCh); err != nil {
 			logger.Errorf("failed to store: %v", err)
 		}
 		return fmt.Errorf("stop stored goroutine") // force to stop goroutine
@@ -112,8 +136,13 @@ func (w *worker) FetchTxs(ctx context.Context) error {
 					logger.Infof("stop fetching txs!")
 					return nil
 				default:
-					w.fetchTxs(childCtx, checkpointCh, txsCh)
-					time.Sleep(w.cooldown) // cooldown api
+				}
+				// the rpc calls are paced by the rate limiters of the endpoints, only wait when there is nothing to do
+				if err := w.fetchTxs(childCtx, batchCh); err != nil {
+					select {
+					case <-childCtx.Done():
+					case <-time.After(w.cooldown): // cooldown db
+					}
 				}
 			}
 		})
@@ -122,113 +151,98 @@ func (w *worker) FetchTxs(ctx context.Context) error {
 	return eg.Wait()
 }
 
-func (w *worker) fetchTxs(ctx context.Context, checkpointCh chan<- *sui_model.Checkpoint, txsCh chan<- []*sui_model.Transaction) error {
+func (w *worker) fetchTxs(ctx context.Context, batchCh chan<- *storeTask) error {
 	ctx, logger := u_logger.GetLogger(ctx)
 	logger.Info("waiting query from db...")
 
-	var blockStatuses []*entity.BlockStatus
+	// claims are aligned like the roll-up windows, the sink stores a claim without waiting for the other ones
+	blockStatus, err := w.blockRangeSvc.Claim(ctx, entity.BlockStatusType_BACKFILL, w.owner, w.limitCheckpoints)
+	if err != nil {
+		logger.Errorf("failed to claim block range from db: %v", err)
+		return fmt.Errorf("failed to claim block range from db: %v", err)
+	} else if blockStatus == nil {
+		logger.Warnf("not found any new blocks in db")
+		return errNoBlockRange
+	}
 
 	// recover panic
 	defer func() {
 		if re := recover(); re != nil {
 			logger.Infof("panic: %v", re)
 			// update status FAIL
-			w.updateCheckpointStatus(ctx, entity.BlockStatus_FAIL, blockStatuses...)
+			w.blockRangeSvc.Fail(ctx, blockStatus, fmt.Errorf("panic: %v", re))
 			return
 		}
 	}()
 
-	var dbErr = w.baseSvc.ExecTx(ctx, func(txCtx context.Context) error {
-		scopes := []func(db *gorm.DB) *gorm.DB{
-			w.blockStatusRepo.S().Locking(),
-			w.blockStatusRepo.S().FilterStatuses(
-				entity.BlockStatus_NOT_READY,
-				entity.BlockStatus_FAIL,
-			),
-			w.blockStatusRepo.S().ColumnEqual("chain", "SUI"),
-			w.blockStatusRepo.S().FilterType(entity.BlockStatusType_BACKFILL),
-			w.blockStatusRepo.S().Limit(w.limitCheckpoints),
-			w.blockStatusRepo.S().SortBy("block_number", "ASC"),
+	// keep the lease while processing, stop processing when the lease is lost
+	leaseCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		if err := w.blockRangeSvc.KeepAlive(leaseCtx, blockStatus, w.owner); err != nil {
+			logger.Errorf("stop processing block range: %v", err)
+			cancel()
 		}
+	}()
 
-		var err error
-		blockStatuses, err = w.blockStatusRepo.GetList(txCtx, scopes...)
-		if err != nil {
+	var results = make([]*entity.BlockResult, blockStatus.Size())
+	for i := range results {
+		results[i] = &entity.BlockResult{BlockNumber: blockStatus.BlockNumber + int64(i)}
+	}
+
+	stored, err := w.storedCheckpoints(leaseCtx, blockStatus)
+	if err != nil {
+		logger.Errorf("failed to read stored checkpoints %v-%v, fetching all of them: %v", blockStatus.BlockNumber, blockStatus.ToBlockNumber, err)
+	}
+	if len(stored) == len(results) {
+		logger.Infof("skip stored checkpoints %v-%v", blockStatus.BlockNumber, blockStatus.ToBlockNumber)
+		for _, result := range results {
+			result.Digest = stored[result.BlockNumber].Digest
+			result.PreviousDigest = stored[result.BlockNumber].PreviousDigest
+		}
+		cancel()
+		if err := w.blockRangeSvc.Complete(ctx, blockStatus, w.owner, results); err != nil {
 			return err
 		}
-
-		// update block status PROCESSING
-		return w.updateCheckpointStatus(txCtx, entity.BlockStatus_PROCESSING, blockStatuses...)
-	})
-	if dbErr != nil {
-		logger.Errorf("failed to fetch block status from db: %v", dbErr)
-		// update status FAIL
-		w.updateCheckpointStatus(ctx, entity.BlockStatus_FAIL, blockStatuses...)
-		return fmt.Errorf("failed to fetch block status from db: %v", dbErr)
-	} else if len(blockStatuses) == 0 {
-		logger.Warnf("not found any new blocks in db")
+		w.skipped.Add(int64(len(results)))
 		return nil
 	}
+	var skipped int64
 
-	var (
-		checkpoints = make([]*sui_model.Checkpoint, 0)
-		wg1         sync.WaitGroup
-	)
-	for _, b := range blockStatuses {
-		blockStatus := b
-		wg1.Add(1)
-
-		go func() {
-			defer wg1.Done()
+	previous, checkpoints := w.fetchCheckpoints(leaseCtx, results)
+	// the network totals of consecutive checkpoints must add up with their txs, from the checkpoint before the range
+	totalErrs := sui_model.VerifyTotals(previous, checkpoints)
+	// the checkpoints must be the ones certified by the validators
+	w.verifySignatures(leaseCtx, checkpoints, results)
 
-			checkpoint, err := retry.DoWithData(
-				func() (*sui_model.Checkpoint, error) {
-					return w.suiIndexer.FetchCheckpoint(ctx, strconv.FormatInt(blockStatus.BlockNumber, 10))
-				},
-				// retry configs
-				[]retry.Option{
-					retry.Attempts(uint(5)),
-					retry.OnRetry(func(n uint, err error) {
-						logger.Errorf("Retry invoke function FetchCheckpoint %d to and get error: %v", n+1, err)
-					}),
-					retry.Delay(3 * time.Second),
-					retry.Context(ctx),
-				}...,
-			)
-			if err != nil {
-				logger.Errorf("failed to fetch checkpoint from %v: %v", blockStatus.BlockNumber, err)
-				// if node rpc has some errors so that it cannot return checkpoints => update status to failed
-				// update status FAIL
-				w.updateCheckpointStatus(ctx, entity.BlockStatus_FAIL, blockStatus)
-				return
-			}
-
-			checkpoints = append(checkpoints, checkpoint)
-		}()
-	}
-
-	// wait for all workers to finish
-	wg1.Wait()
-
-	if len(checkpoints) == 0 {
-		logger.Warnf("no data checkpoints to process")
-		w.updateCheckpointStatus(ctx, entity.BlockStatus_FAIL, blockStatuses...)
-		return nil
-	}
 	var wg2 sync.WaitGroup
-	for _, c := range checkpoints {
-		checkpoint := c.WithDateKey()
-		blockStatus, ok := lo.Find(blockStatuses, func(item *entity.BlockStatus) bool {
-			return strconv.FormatInt(item.BlockNumber, 10) == checkpoint.SequenceNumber
-		})
-		if !ok {
-			logger.Warnf("not found sequence number of checkpoint in block status")
+	for i, c := range checkpoints {
+		if c == nil {
+			continue
+		}
+		checkpoint, result := c.WithDateKey(), results[i]
+		if result.Err != nil {
 			continue
 		}
 		if err := checkpoint.Validate(); err != nil {
 			logger.Errorf("invalid checkpoint: %v", err)
-			// update status FAIL
-			w.updateCheckpointStatus(ctx, entity.BlockStatus_FAIL, blockStatus)
+			result.Err = err
+			continue
+		}
+		if err, ok := totalErrs[i]; ok {
+			logger.Errorf("invalid checkpoint: %v", err)
+			result.Err = err
+			continue
+		}
+		if checkpoint.SequenceNumber != strconv.FormatInt(result.BlockNumber, 10) {
+			logger.Warnf("not found sequence number of checkpoint in block status")
+			result.Err = fmt.Errorf("unexpected checkpoint %v for block %v", checkpoint.SequenceNumber, result.BlockNumber)
+			continue
+		}
+		if storedCheckpoint, ok := stored[result.BlockNumber]; ok && storedCheckpoint.Digest == checkpoint.Digest {
+			result.Digest = checkpoint.Digest
+			result.PreviousDigest = checkpoint.PreviousDigest
+			skipped++
 			continue
 		}
 
@@ -237,12 +251,13 @@ func (w *worker) fetchTxs(ctx context.Context, checkpointCh chan<- *sui_model.Ch
 			defer wg2.Done()
 
 			var fetchDataErr = func() error {
+				var fetchedTxs = make([]*sui_model.Transaction, 0)
 				uniqueTxs := lo.Uniq(checkpoint.Transactions)
 				chunkTxDigests := lo.Chunk(uniqueTxs, 20)
 				for _, txDigests := range chunkTxDigests {
 					txs, err := retry.DoWithData(
 						func() ([]*sui_model.Transaction, error) {
-							return w.suiIndexer.FetchTxs(ctx, txDigests...)
+							return w.suiIndexer.FetchTxs(leaseCtx, txDigests...)
 						},
 						// retry configs
 						[]retry.Option{
@@ -250,73 +265,261 @@ func (w *worker) fetchTxs(ctx context.Context, checkpointCh chan<- *sui_model.Ch
 							retry.OnRetry(func(n uint, err error) {
 								logger.Errorf("Retry invoke function FetchTxs %d to and get error: %v", n+1, err)
 							}),
-							retry.Delay(3 * time.Second),
-							retry.Context(ctx),
+							sui_rpc.RetryDelay(3 * time.Second),
+							retry.Context(leaseCtx),
 						}...,
 					)
 					if err != nil {
 						if !errors.Is(err, context.Canceled) {
-							alert.AlertDiscord(ctx, fmt.Sprintf("[sui-indexer] failed to fetch txs: %v", err))
+							alert.AlertDiscord(leaseCtx, fmt.Sprintf("[sui-indexer] failed to fetch txs: %v", err))
 						}
 						return err
 					}
-					if err := checkpoint.SetBloomFilter(txs); err != nil {
-						return err
-					}
 
-					var parsedTxs = make([]*sui_model.Transaction, 0)
-					for _, tx := range txs {
-						if err := tx.Validate(); err != nil {
-							logger.Errorf("invalid tx: %v", err)
-							return fmt.Errorf("invalid tx: %v", err)
-						}
-						parsedTxs = append(parsedTxs, tx.WithDateKey())
-					}
+					fetchedTxs = append(fetchedTxs, txs...)
+				}
+				// never store a partial checkpoint
+				if err := checkpoint.VerifyTxs(fetchedTxs); err != nil {
+					alert.AlertDiscord(leaseCtx, fmt.Sprintf("[sui-indexer] incomplete checkpoint: %v", err))
+					return err
+				}
 
-					// send txs to kafka
-					if len(parsedTxs) > 0 {
-						txsCh <- parsedTxs
+				var parsedTxs = make([]*sui_model.Transaction, 0, len(fetchedTxs))
+				for _, tx := range fetchedTxs {
+					if err := tx.Validate(); err != nil {
+						logger.Errorf("invalid tx: %v", err)
+						return fmt.Errorf("invalid tx: %v", err)
 					}
+					parsedTxs = append(parsedTxs, tx.WithDateKey())
+				}
+				if err := checkpoint.SetBloomFilter(parsedTxs); err != nil {
+					return err
+				}
 
-					// send checkpoints to kafka
-					if checkpoint != nil {
-						checkpointCh <- checkpoint
+				// store all records of the checkpoint together
+				batch, eventCount, err := w.newBatch(checkpoint, result.BlockNumber, parsedTxs)
+				if err != nil {
+					return err
+				}
+				result.EventCount = eventCount
+				task := &storeTask{
+					batch: batch,
+					done:  make(chan error, 1),
+				}
+				select {
+				case batchCh <- task:
+				case <-leaseCtx.Done():
+					return leaseCtx.Err()
+				}
+
+				// the checkpoint is only DONE once the sinks confirmed the upload
+				select {
+				case err := <-task.done:
+					if err != nil {
+						return fmt.Errorf("failed to store checkpoint %v: %v", batch.Checkpoint, err)
 					}
+				case <-leaseCtx.Done():
+					return leaseCtx.Err()
 				}
 				return nil
 			}()
 			if fetchDataErr != nil {
 				logger.Errorf("failed to fetch txs: %v", fetchDataErr)
-				// update status FAIL
-				w.updateCheckpointStatus(ctx, entity.BlockStatus_FAIL, blockStatus)
+				result.Err = fetchDataErr
 				return
 			}
-			// update status DONE
-			w.updateCheckpointStatus(ctx, entity.BlockStatus_DONE, blockStatus)
+			result.Digest = checkpoint.Digest
+			result.PreviousDigest = checkpoint.PreviousDigest
 		}()
 	}
 
 	// wait for all workers to finish
 	wg2.Wait()
 
+	// release the lease by completing the range
+	cancel()
+	if err := w.blockRangeSvc.Complete(ctx, blockStatus, w.owner, results); err != nil {
+		logger.Errorf("failed to complete block range %v-%v: %v", blockStatus.BlockNumber, blockStatus.ToBlockNumber, err)
+		return err
+	}
+	failed := int64(lo.CountBy(results, func(item *entity.BlockResult) bool {
+		return item.Err != nil
+	}))
+	w.done.Add(int64(len(results)) - failed - skipped)
+	w.failed.Add(failed)
+	w.skipped.Add(skipped)
+
 	return nil
 }
 
-func (w *worker) updateCheckpointStatus(ctx context.Context, status int, checkpointIds ...*entity.BlockStatus) error {
-	if len(checkpointIds) == 0 {
-		return nil
+func (w *worker) Stats() WorkerStats {
+	return WorkerStats{
+		Done:     w.done.Load(),
+		Failed:   w.failed.Load(),
+		Skipped:  w.skipped.Load(),
+		RpcCalls: w.suiIndexer.RpcCalls(),
 	}
-	if err := w.blockStatusRepo.UpdateStatus(ctx, status, lo.Map(checkpointIds, func(item *entity.BlockStatus, _ int) string {
-		return item.ID
-	})...); err != nil {
-		return err
+}
+
+// storedCheckpoints returns the checkpoints of blockStatus which are already stored by the s3 sink,
+// none when BACKFILL_SKIP_EXISTING is not set
+func (w *worker) storedCheckpoints(ctx context.Context, blockStatus *entity.BlockStatus) (map[int64]*sui_model.Checkpoint, error) {
+	var res = make(map[int64]*sui_model.Checkpoint)
+	if w.inventory == nil {
+		return res, nil
+	}
+
+	storedCheckpoints, err := w.inventory.Checkpoints(ctx, blockStatus.BlockNumber, blockStatus.ToBlockNumber, false)
+	if err != nil {
+		return res, err
+	}
+	for _, item := range storedCheckpoints {
+		if item.Stored() {
+			res[item.Seq] = item.Checkpoint
+		}
+	}
+	return res, nil
+}
+
+// verifySignatures marks the checkpoints which do not match their certified checkpoint as failed, all of them
+// are failed when the certified checkpoints cannot be read
+func (w *worker) verifySignatures(ctx context.Context, checkpoints []*sui_model.Checkpoint, results []*entity.BlockResult) {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	var verifying = make([]*sui_model.Checkpoint, 0, len(checkpoints))
+	for i, checkpoint := range checkpoints {
+		if checkpoint != nil && results[i].Err == nil {
+			verifying = append(verifying, checkpoint)
+		}
+	}
+
+	failures, err := w.signatureVerifier.VerifySignatures(ctx, verifying...)
+	if err != nil {
+		logger.Errorf("failed to verify checkpoint signatures: %v", err)
+		if errors.Is(err, setting.CheckpointCertificateErr) {
+			alert.AlertDiscord(ctx, fmt.Sprintf("[sui-indexer] %v", err))
+		}
+		for _, result := range results {
+			if result.Err == nil {
+				result.Err = err
+			}
+		}
+		return
+	}
+	for _, result := range results {
+		if failure, ok := failures[result.BlockNumber]; ok {
+			logger.Errorf("invalid checkpoint: %v", failure)
+			alert.AlertDiscord(ctx, fmt.Sprintf("[sui-indexer] %v", failure))
+			result.Err = failure
+		}
 	}
-	return nil
 }
 
-func (w *worker) StoreS3(ctx context.Context, checkpointCh <-chan *sui_model.Checkpoint, txsCh <-chan []*sui_model.Transaction) error {
+// fetchCheckpoints fetches the blocks of results with one getCheckpoints call, together with the checkpoint
+// before them to verify the network total of the first one. The checkpoints missing from its response are fetched
+// one by one. previous is nil for the genesis checkpoint, and the first checkpoint is failed when previous is missing.
+func (w *worker) fetchCheckpoints(ctx context.Context, results []*entity.BlockResult) (previous *sui_model.Checkpoint, checkpoints []*sui_model.Checkpoint) {
 	ctx, logger := u_logger.GetLogger(ctx)
-	logger.Info("start goroutine store s3...")
+
+	var (
+		from  = results[0].BlockNumber
+		to    = results[len(results)-1].BlockNumber
+		first = max(from-1, 0)
+		// fetched holds the checkpoints first..to
+		fetched = make([]*sui_model.Checkpoint, to-first+1)
+	)
+	batch, err := retry.DoWithData(
+		func() ([]*sui_model.Checkpoint, error) {
+			return w.suiIndexer.FetchCheckpoints(ctx, strconv.FormatInt(first, 10), strconv.FormatInt(to, 10))
+		},
+		// retry configs
+		[]retry.Option{
+			retry.Attempts(uint(2)),
+			retry.OnRetry(func(n uint, err error) {
+				logger.Errorf("Retry invoke function FetchCheckpoints %d to and get error: %v", n+1, err)
+			}),
+			sui_rpc.RetryDelay(1 * time.Second),
+			retry.Context(ctx),
+		}...,
+	)
+	if err != nil {
+		logger.Warnf("failed to fetch checkpoints %v-%v, fallback to fetch one by one: %v", from, to, err)
+	}
+	for _, checkpoint := range batch {
+		if checkpoint == nil {
+			continue
+		}
+		seq, err := strconv.ParseInt(checkpoint.SequenceNumber, 10, 64)
+		if err != nil || seq < first || seq > to {
+			continue
+		}
+		fetched[seq-first] = checkpoint
+	}
+
+	var wg1 sync.WaitGroup
+	for i := range fetched {
+		if fetched[i] != nil {
+			continue
+		}
+		idx, seq := i, first+int64(i)
+		wg1.Add(1)
+
+		go func() {
+			defer wg1.Done()
+
+			checkpoint, err := retry.DoWithData(
+				func() (*sui_model.Checkpoint, error) {
+					return w.suiIndexer.FetchCheckpoint(ctx, strconv.FormatInt(seq, 10))
+				},
+				// retry configs
+				[]retry.Option{
+					retry.Attempts(uint(5)),
+					retry.OnRetry(func(n uint, err error) {
+						logger.Errorf("Retry invoke function FetchCheckpoint %d to and get error: %v", n+1, err)
+					}),
+					sui_rpc.RetryDelay(3 * time.Second),
+					retry.Context(ctx),
+				}...,
+			)
+			if err != nil {
+				logger.Errorf("failed to fetch checkpoint from %v: %v", seq, err)
+				// if node rpc has some errors so that it cannot return checkpoints => update status to failed
+				if seq >= from {
+					results[seq-from].Err = err
+				}
+				return
+			}
+
+			fetched[idx] = checkpoint
+		}()
+	}
+
+	// wait for all workers to finish
+	wg1.Wait()
+
+	if from == 0 {
+		return nil, fetched
+	}
+	previous, checkpoints = fetched[0], fetched[1:]
+	if previous == nil && checkpoints[0] != nil {
+		checkpoints[0] = nil
+		if results[0].Err == nil {
+			results[0].Err = fmt.Errorf("missing checkpoint %v to verify the network total of checkpoint %v", from-1, from)
+		}
+	}
+	return previous, checkpoints
+}
+
+// storeTask is a batch to store, done receives the result of the write
+type storeTask struct {
+	batch *sink.Batch
+	done  chan error
+}
+
+// Store writes the batches to the sinks until ctx is done or batchCh is closed, and acknowledges every write
+func (w *worker) Store(ctx context.Context, batchCh <-chan *storeTask) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+	logger.Info("start goroutine store...")
 
 	pool := pond.New(100, 0, pond.MinWorkers(10))
 	defer pool.StopAndWait()
@@ -325,185 +528,65 @@ func (w *worker) StoreS3(ctx context.Context, checkpointCh <-chan *sui_model.Che
 		select {
 		case <-ctx.Done():
 			return nil
-		case c, ok := <-checkpointCh:
+		case t, ok := <-batchCh:
 			if !ok {
 				logger.Info("channel is closed")
 				return nil
 			}
-			checkpoint := c
-			if checkpoint == nil {
+			task := t
+			if task == nil {
 				continue
 			}
 
 			pool.Submit(func() {
-				errCh := make(chan error, 1)
-				defer close(errCh)
-
-				pw := w.s3Svc.FileStreamWriter(ctx, conf.Config.AwsBucket, fmt.Sprintf("checkpoints/sui-checkpoints/datekey=%v/%v.json.gz", checkpoint.DateKey, checkpoint.SequenceNumber), errCh)
-				zw, err := gzip.NewWriterLevel(pw, gzip.BestSpeed)
+				err := w.sink.Write(ctx, task.batch)
+				task.done <- err
 				if err != nil {
-					logger.Errorf("failed to create gzip writer: %v", err)
-					return
-				}
-
-				// add data to gzip
-				data, err := json.Marshal(checkpoint)
-				if err != nil {
-					logger.Errorf("failed to marshal checkpoint: %v", err)
-					return
-				}
-				_, err = zw.Write(data)
-				if err != nil {
-					logger.Errorf("failed to write checkpoint to S3: %v", err)
+					logger.Errorf("failed to store checkpoint %v: %v", task.batch.Checkpoint, err)
+					alert.AlertDiscord(ctx, fmt.Sprintf("[sui-indexer] failed to store checkpoint %v: %v", task.batch.Checkpoint, err))
 					return
 				}
+				logger.Infof("[%v] store checkpoint %v success", task.batch.DateKey, task.batch.Checkpoint)
+			})
+		}
+	}
+}
 
-				// flush data to S3
-				zw.Close()
-				pw.Close()
+// newBatch builds the records stored for a checkpoint and its txs, the same records as the realtime worker
+// except that events are not deduplicated, and returns the number of index rows
+func (w *worker) newBatch(checkpoint *sui_model.Checkpoint, checkpointSeq int64, txs []*sui_model.Transaction) (*sink.Batch, int, error) {
+	var (
+		batch = &sink.Batch{
+			DateKey:    checkpoint.DateKey,
+			Checkpoint: checkpoint.SequenceNumber,
+		}
+		eventCount int
+	)
+	batch.Add(sink.Dataset_CHECKPOINTS, w.checkpointsTopic, checkpoint)
+	for _, tx := range txs {
+		batch.Add(sink.Dataset_TXS, w.txsTopic, tx)
 
-				returnErr := <-errCh
-				if returnErr != nil {
-					logger.Errorf("failed to store checkpoint to S3: %v", err)
-					return
-				}
-				logger.Infof("[%v] submit checkpoint %v to S3 success", checkpoint.DateKey, checkpoint.SequenceNumber)
-			})
-		case t, ok := <-txsCh:
-			if !ok {
-				logger.Info("channel is closed")
-				return nil
+		events, err := tx.ParseEvents()
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to parse events: %v", err)
+		}
+		for _, event := range events {
+			if w.eventFilter.Accept(event.SuiEvent) {
+				batch.Add(sink.Dataset_EVENTS, w.eventsTopic, event)
 			}
-			txs := t
-			if len(txs) == 0 {
-				continue
+			for _, topic := range w.eventFilter.Topics(event.SuiEvent) {
+				batch.Add(sink.Dataset_EVENTS, topic, event)
 			}
-
-			pool.Submit(func() {
-				errCh := make(chan error, 1)
-				defer close(errCh)
-
-				pw := w.s3Svc.FileStreamWriter(ctx, conf.Config.AwsBucket, fmt.Sprintf("txs/sui-txs/datekey=%v/%v.json.gz", txs[0].DateKey, txs[0].Checkpoint), errCh)
-				zw, err := gzip.NewWriterLevel(pw, gzip.BestSpeed)
-				if err != nil {
-					logger.Errorf("failed to create gzip writer: %v", err)
-					return
-				}
-
-				// add data to gzip
-				for _, tx := range txs {
-					data, err := json.Marshal(tx)
-					if err != nil {
-						logger.Errorf("failed to marshal tx: %v", err)
-						return
-					}
-					_, err = zw.Write(data)
-					if err != nil {
-						logger.Errorf("failed to write tx to S3: %v", err)
-						return
-					}
-					zw.Write([]byte("\n"))
-				}
-
-				// flush data to S3
-				zw.Close()
-				pw.Close()
-
-				returnErr := <-errCh
-				if returnErr != nil {
-					logger.Errorf("failed to store tx to S3: %v", err)
-					return
-				}
-				logger.Infof("[%v] submit txs in checkpoint %v to S3 success", txs[0].DateKey, txs[0].Checkpoint)
-			})
+			batch.Add(sink.Dataset_INDEX, w.indexTopic, entity.NewSuiIndex(checkpoint, checkpointSeq, event).ToKafka())
+			eventCount++
 		}
+
+		batch.Add(sink.Dataset_OBJECT_CHANGES, w.objectChangesTopic, lo.Map(tx.ParseObjectChanges(), func(item *sui_model.ObjectChange, _ int) infra.KafkaMsg {
+			return item
+		})...)
+		batch.Add(sink.Dataset_BALANCE_CHANGES, w.balanceChangesTopic, lo.Map(tx.ParseBalanceChanges(), func(item *sui_model.BalanceChange, _ int) infra.KafkaMsg {
+			return item
+		})...)
 	}
+	return batch, eventCount, nil
 }
-
-// TODO: not used kafka for backfill for fast growth kafka cluster (disk performance issues)
-//func (w *worker) StoreKafka(ctx context.Context, checkpointCh <-chan *sui_model.Checkpoint, txsCh <-chan []*sui_model.Transaction, eventsCh <-chan []*sui_model.Event, indicesCh <-chan []*entity.SuiIndex) error {
-//	ctx, logger := u_logger.GetLogger(ctx)
-//	logger.Info("start goroutine store kafka...")
-//
-//	pool := pond.New(100, 0, pond.MinWorkers(10))
-//	defer pool.StopAndWait()
-//
-//	for {
-//		select {
-//		case <-ctx.Done():
-//			return nil
-//		case c, ok := <-checkpointCh:
-//			if !ok {
-//				logger.Info("channel is closed")
-//				return nil
-//			}
-//
-//			checkpoint := c
-//			if checkpoint == nil {
-//				continue
-//			}
-//			pool.Submit(func() {
-//				if err := w.kafkaProducer.SendJson(ctx, conf.Config.SuiCheckpointsTopic, checkpoint); err != nil {
-//					logger.Errorf("failed to send payload to kafka sui checkpoints topic: %v", err)
-//				}
-//			})
-//		case t, ok := <-txsCh:
-//			if !ok {
-//				logger.Info("channel is closed")
-//				return nil
-//			}
-//
-//			txs := t
-//			if len(txs) == 0 {
-//				continue
-//			}
-//			for _, t := range txs {
-//				tx := t
-//				pool.Submit(func() {
-//					if err := w.kafkaProducer.SendJson(ctx, conf.Config.SuiTxsTopic, tx.WithDateKey()); err != nil {
-//						logger.Errorf("failed to send payload to kafka sui txs topic: %v", err)
-//					}
-//				})
-//			}
-//		case e, ok := <-eventsCh:
-//			if !ok {
-//				logger.Info("channel is closed")
-//				return nil
-//			}
-//
-//			events := e
-//			if len(events) == 0 {
-//				continue
-//			}
-//			for _, e := range events {
-//				event := e
-//				pool.Submit(func() {
-//					if err := w.kafkaProducer.SendJson(ctx, conf.Config.SuiEventsTopic, event); err != nil {
-//						logger.Errorf("failed to send payload to kafka sui events topic: %v", err)
-//					}
-//				})
-//			}
-//		case i, ok := <-indicesCh:
-//			if !ok {
-//				logger.Info("channel is closed")
-//				return nil
-//			}
-//
-//			indices := i
-//			if len(indices) == 0 {
-//				continue
-//			}
-//			pool.Submit(func() {
-//				for _, index := range indices {
-//					if err := w.kafkaProducer.SendJson(
-//						ctx,
-//						conf.Config.SuiIndexTopic,
-//						index.ToKafka(),
-//					); err != nil {
-//						logger.Errorf("failed to send payload to kafka sui index topic: %v", err)
-//					}
-//				}
-//			})
-//		}
-//	}
-//}
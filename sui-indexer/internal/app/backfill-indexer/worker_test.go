@@ -0,0 +1,162 @@
+package backfill_indexer
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/smartystreets/goconvey/convey"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/entity_dto/sui_model"
+	"feng-sui-core/internal/service"
+	"feng-sui-core/internal/setting"
+	"feng-sui-core/internal/sink"
+)
+
+type recordingSink struct {
+	batches chan *sink.Batch
+}
+
+func (s *recordingSink) Write(ctx context.Context, batch *sink.Batch) error {
+	s.batches <- batch
+	return nil
+}
+
+// failingSignatureVerifier fails checkpoint seq the first times times
+type failingSignatureVerifier struct {
+	seq   int64
+	times atomic.Int64
+}
+
+func (v *failingSignatureVerifier) VerifySignatures(ctx context.Context, checkpoints ...*sui_model.Checkpoint) (map[int64]error, error) {
+	var failures = make(map[int64]error)
+	for _, checkpoint := range checkpoints {
+		if checkpoint.SequenceNumber == strconv.FormatInt(v.seq, 10) && v.times.Add(-1) >= 0 {
+			failures[v.seq] = fmt.Errorf("%w: checkpoint %v", setting.CheckpointCertificateErr, v.seq)
+		}
+	}
+	return failures, nil
+}
+
+func TestWorker(t *testing.T) {
+	convey.Convey("TestWorker", t, func() {
+		ctx := context.Background()
+
+		// the rpc fixtures of the checkpoints 999..1011, 2 txs per checkpoint and 1 event per checkpoint
+		handler, err := service.NewRpcReplayHandler(filepath.Join("..", "..", "service", "testdata", "rpc"))
+		convey.So(err, convey.ShouldBeNil)
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		saved := conf.Config
+		defer func() {
+			conf.Config = saved
+		}()
+		conf.Config.SuiRpcs = server.URL
+		conf.Config.BackfillRollupCheckpoints = 10
+
+		convey.Convey("Backfill stores the checkpoints served by the rpc", func() {
+			blockStore, err := service.NewLocalBlockStore(filepath.Join(t.TempDir(), "block_status.json"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(blockStore.AddRange(ctx, entity.BlockStatusType_BACKFILL, 1000, 1011), convey.ShouldBeNil)
+
+			batchSink := &recordingSink{batches: make(chan *sink.Batch, 100)}
+			w, err := NewWorker(blockStore, batchSink, nil)
+			convey.So(err, convey.ShouldBeNil)
+
+			timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			go func() {
+				for blockStore.Remaining(timeoutCtx) > 0 && timeoutCtx.Err() == nil {
+					time.Sleep(10 * time.Millisecond)
+				}
+				cancel()
+			}()
+			_ = w.FetchTxs(timeoutCtx)
+			convey.So(timeoutCtx.Err(), convey.ShouldEqual, context.Canceled)
+			convey.So(w.Stats().Done, convey.ShouldEqual, 12)
+			convey.So(w.Stats().Failed, convey.ShouldEqual, 0)
+
+			var records = make(map[string]int)
+			close(batchSink.batches)
+			for batch := range batchSink.batches {
+				for _, stream := range batch.Streams {
+					records[stream.Topic] += len(stream.Records)
+				}
+			}
+			convey.So(records[conf.Config.SuiCheckpointsTopic], convey.ShouldEqual, 12)
+			convey.So(records[conf.Config.SuiTxsTopic], convey.ShouldEqual, 24)
+			convey.So(records[conf.Config.SuiEventsTopic], convey.ShouldEqual, 12)
+		})
+
+		convey.Convey("Checkpoints failing their certificate are not stored", func() {
+			blockStore, err := service.NewLocalBlockStore(filepath.Join(t.TempDir(), "block_status.json"))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(blockStore.AddRange(ctx, entity.BlockStatusType_BACKFILL, 1000, 1011), convey.ShouldBeNil)
+
+			batchSink := &recordingSink{batches: make(chan *sink.Batch, 100)}
+			w, err := NewWorker(blockStore, batchSink, nil)
+			convey.So(err, convey.ShouldBeNil)
+			verifier := &failingSignatureVerifier{seq: 1003}
+			verifier.times.Store(1)
+			w.(*worker).signatureVerifier = verifier
+
+			timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			go func() {
+				for blockStore.Remaining(timeoutCtx) > 0 && timeoutCtx.Err() == nil {
+					time.Sleep(10 * time.Millisecond)
+				}
+				cancel()
+			}()
+			_ = w.FetchTxs(timeoutCtx)
+			convey.So(timeoutCtx.Err(), convey.ShouldEqual, context.Canceled)
+			convey.So(w.Stats().Failed, convey.ShouldEqual, 1)
+
+			// 1003 is only stored once it is retried and verified
+			var checkpoints []string
+			close(batchSink.batches)
+			for batch := range batchSink.batches {
+				checkpoints = append(checkpoints, batch.Checkpoint)
+			}
+			convey.So(checkpoints, convey.ShouldHaveLength, 12)
+			convey.So(lo.Uniq(checkpoints), convey.ShouldHaveLength, 12)
+		})
+
+		convey.Convey("Checkpoints are fetched with the one before the range", func() {
+			w, err := NewWorker(nil, nil, nil)
+			convey.So(err, convey.ShouldBeNil)
+
+			results := lo.Map(lo.RangeFrom(int64(1000), 5), func(seq int64, _ int) *entity.BlockResult {
+				return &entity.BlockResult{BlockNumber: seq}
+			})
+			previous, checkpoints := w.(*worker).fetchCheckpoints(ctx, results)
+			convey.So(previous.SequenceNumber, convey.ShouldEqual, "999")
+			convey.So(checkpoints, convey.ShouldHaveLength, 5)
+			convey.So(checkpoints[0].SequenceNumber, convey.ShouldEqual, "1000")
+			convey.So(sui_model.VerifyTotals(previous, checkpoints), convey.ShouldBeEmpty)
+
+			// the checkpoint 998 is not served, 999 cannot be verified. The page fails and is retried once,
+			// then the checkpoints are fetched one by one and 998 until the timeout.
+			timeoutCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+			defer cancel()
+			results = lo.Map(lo.RangeFrom(int64(999), 2), func(seq int64, _ int) *entity.BlockResult {
+				return &entity.BlockResult{BlockNumber: seq}
+			})
+			previous, checkpoints = w.(*worker).fetchCheckpoints(timeoutCtx, results)
+			convey.So(previous, convey.ShouldBeNil)
+			convey.So(checkpoints[0], convey.ShouldBeNil)
+			convey.So(results[0].Err, convey.ShouldNotBeNil)
+			convey.So(checkpoints[1].SequenceNumber, convey.ShouldEqual, "1000")
+			convey.So(results[1].Err, convey.ShouldBeNil)
+		})
+	})
+}
@@ -2,35 +2,56 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/getnimbus/ultrago/u_logger"
 	"github.com/getnimbus/ultrago/u_monitor"
 	"github.com/golang-module/carbon/v2"
+	"github.com/samber/lo"
 	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
 
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/repo"
 	"feng-sui-core/internal/service"
+	"feng-sui-core/internal/sink"
 )
 
 func NewApp(
 	syncTradeSvc service.SyncTradeService,
 	compressionSvc service.CompressionService,
+	blockStatusRepo repo.BlockStatusRepo,
+	inventory *sink.Inventory,
 ) App {
 	return &app{
-		syncTradeSvc:   syncTradeSvc,
-		compressionSvc: compressionSvc,
+		syncTradeSvc:    syncTradeSvc,
+		compressionSvc:  compressionSvc,
+		blockStatusRepo: blockStatusRepo,
+		inventory:       inventory,
 	}
 }
 
 type App interface {
 	SyncTrades(ctx context.Context, rawParams ...string) error
 	CompressData(ctx context.Context, rawParams ...string) error
+	ListDeadBlocks(ctx context.Context, rawParams ...string) error
+	RequeueDeadBlocks(ctx context.Context, rawParams ...string) error
+	VerifyBackfill(ctx context.Context, rawParams ...string) error
+	BackfillStatus(ctx context.Context, rawParams ...string) error
+	ServeRpcFixtures(ctx context.Context, rawParams ...string) error
 }
 
 type app struct {
-	syncTradeSvc   service.SyncTradeService
-	compressionSvc service.CompressionService
+	syncTradeSvc    service.SyncTradeService
+	compressionSvc  service.CompressionService
+	blockStatusRepo repo.BlockStatusRepo
+	inventory       *sink.Inventory
 }
 
 func (a *app) SyncTrades(ctx context.Context, rawParams ...string) error {
@@ -114,6 +135,210 @@ func (a *app) CompressData(ctx context.Context, rawParams ...string) error {
 	return nil
 }
 
+// ListDeadBlocks prints the DEAD ranges, param1 is the optional limit (default 100)
+func (a *app) ListDeadBlocks(ctx context.Context, rawParams ...string) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	params, err := a.prepareParams(0, rawParams...)
+	if err != nil {
+		return err
+	}
+
+	limit := 100
+	if len(params) >= 1 {
+		limit, err = strconv.Atoi(params[0])
+		if err != nil {
+			return fmt.Errorf("invalid limit %v: %v", params[0], err)
+		}
+	}
+
+	blockStatuses, err := a.blockStatusRepo.GetList(ctx,
+		a.blockStatusRepo.S().FilterStatuses(entity.BlockStatus_DEAD),
+		a.blockStatusRepo.S().ColumnEqual("chain", "SUI"),
+		a.blockStatusRepo.S().Limit(limit),
+		a.blockStatusRepo.S().SortBy("block_number", "ASC"),
+	)
+	if err != nil {
+		logger.Errorf("failed to get dead blocks: %v", err)
+		return err
+	}
+
+	for _, blockStatus := range blockStatuses {
+		logger.Infof("dead blocks %v-%v (type %v): attempts=%v updated_at=%v last_error=%v",
+			blockStatus.BlockNumber, blockStatus.ToBlockNumber, blockStatus.Type, blockStatus.Attempts, blockStatus.UpdatedAt, blockStatus.LastError)
+	}
+	logger.Infof("found %v dead block ranges", len(blockStatuses))
+	return nil
+}
+
+// RequeueDeadBlocks resets DEAD ranges to NOT_READY, param1 is "all" or comma separated block numbers contained in the ranges
+func (a *app) RequeueDeadBlocks(ctx context.Context, rawParams ...string) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	params, err := a.prepareParams(1, rawParams...)
+	if err != nil {
+		return err
+	}
+
+	scopes := []func(db *gorm.DB) *gorm.DB{
+		a.blockStatusRepo.S().FilterStatuses(entity.BlockStatus_DEAD),
+		a.blockStatusRepo.S().ColumnEqual("chain", "SUI"),
+	}
+	if params[0] != "all" {
+		var blockNumbers []int64
+		for _, raw := range strings.Split(params[0], ",") {
+			blockNumber, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid block number %v: %v", raw, err)
+			}
+			blockNumbers = append(blockNumbers, blockNumber)
+		}
+		scopes = append(scopes, a.blockStatusRepo.S().FilterContains(blockNumbers...))
+	}
+
+	blockStatuses, err := a.blockStatusRepo.GetList(ctx, scopes...)
+	if err != nil {
+		logger.Errorf("failed to get dead blocks: %v", err)
+		return err
+	}
+
+	for _, chunk := range lo.Chunk(blockStatuses, 1000) {
+		if err := a.blockStatusRepo.Requeue(ctx, lo.Map(chunk, func(item *entity.BlockStatus, _ int) string {
+			return item.ID
+		})...); err != nil {
+			logger.Errorf("failed to requeue dead blocks: %v", err)
+			return err
+		}
+	}
+	logger.Infof("requeued %v dead block ranges", len(blockStatuses))
+	return nil
+}
+
+// VerifyBackfill checks that the s3 sink stored every checkpoint from param1 to param2 (inclusive) with all of its txs,
+// it fails when a checkpoint is missing or its stored tx count differs from the txs of the checkpoint
+func (a *app) VerifyBackfill(ctx context.Context, rawParams ...string) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	defer u_monitor.TimeTrackWithCtx(ctx, time.Now())
+
+	params, err := a.prepareParams(2, rawParams...)
+	if err != nil {
+		return err
+	}
+	from, err := strconv.ParseInt(params[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid from checkpoint %v: %v", params[0], err)
+	}
+	to, err := strconv.ParseInt(params[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid to checkpoint %v: %v", params[1], err)
+	}
+
+	if conf.Config.BackfillRollupCheckpoints < 2 {
+		return fmt.Errorf("only the checkpoints stored with the manifests of BACKFILL_ROLLUP_CHECKPOINTS can be verified")
+	}
+
+	var (
+		windowSize = conf.Config.BackfillRollupCheckpoints
+		missing    = make([]*entity.BlockRange, 0)
+		incomplete = 0
+	)
+	// read one roll-up window at a time
+	for start := from; start <= to; start = start - start%windowSize + windowSize {
+		end := min(start-start%windowSize+windowSize-1, to)
+		storedCheckpoints, err := a.inventory.Checkpoints(ctx, start, end, true)
+		if err != nil {
+			logger.Errorf("failed to read stored checkpoints %v-%v: %v", start, end, err)
+			return err
+		}
+		for _, item := range storedCheckpoints {
+			switch {
+			case !item.Stored():
+				if len(missing) > 0 && missing[len(missing)-1].To == item.Seq-1 {
+					missing[len(missing)-1].To = item.Seq
+				} else {
+					missing = append(missing, &entity.BlockRange{From: item.Seq, To: item.Seq})
+				}
+			case !item.Complete():
+				logger.Warnf("checkpoint %v has %v stored txs instead of %v", item.Seq, item.StoredTxs, len(lo.Uniq(item.Checkpoint.Transactions)))
+				incomplete++
+			}
+		}
+	}
+
+	for _, blockRange := range missing {
+		logger.Warnf("checkpoints %v-%v are not stored", blockRange.From, blockRange.To)
+	}
+	if len(missing) > 0 || incomplete > 0 {
+		return fmt.Errorf("checkpoints %v-%v are not complete: %v missing ranges, %v checkpoints with missing txs", from, to, len(missing), incomplete)
+	}
+	logger.Infof("checkpoints %v-%v are complete", from, to)
+	return nil
+}
+
+// BackfillStatus prints the progress of the backfill, param1 is the optional window of the throughput (default 10m)
+func (a *app) BackfillStatus(ctx context.Context, rawParams ...string) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	params, err := a.prepareParams(0, rawParams...)
+	if err != nil {
+		return err
+	}
+
+	window := 10 * time.Minute
+	if len(params) >= 1 {
+		window, err = time.ParseDuration(params[0])
+		if err != nil || window <= 0 {
+			return fmt.Errorf("invalid window %v: %v", params[0], err)
+		}
+	}
+
+	progress, err := a.blockStatusRepo.GetProgress(ctx, entity.BlockStatusType_BACKFILL, window)
+	if err != nil {
+		logger.Errorf("failed to get backfill progress: %v", err)
+		return err
+	}
+	logger.Infof("backfill progress: %v", progress)
+	return nil
+}
+
+// ServeRpcFixtures serves the rpc calls recorded with RPC_RECORD_DIR, param1 is the fixtures directory and
+// param2 the optional listen address (default :9000)
+func (a *app) ServeRpcFixtures(ctx context.Context, rawParams ...string) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	params, err := a.prepareParams(1, rawParams...)
+	if err != nil {
+		return err
+	}
+
+	addr := ":9000"
+	if len(params) >= 2 {
+		addr = params[1]
+	}
+
+	handler, err := service.NewRpcReplayHandler(params[0])
+	if err != nil {
+		logger.Errorf("failed to load rpc fixtures: %v", err)
+		return err
+	}
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	logger.Infof("serving rpc fixtures of %v on %v", params[0], addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
 func (a *app) prepareParams(requires int, params ...string) ([]string, error) {
 	var results = make([]string, 0, len(params))
 	for idx, param := range params {
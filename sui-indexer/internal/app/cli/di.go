@@ -4,10 +4,12 @@ import (
 	"github.com/getnimbus/ultrago/u_http_client"
 	"github.com/google/wire"
 
+	"feng-sui-core/internal/conf"
 	"feng-sui-core/internal/infra"
 	"feng-sui-core/internal/repo/gorm"
 	"feng-sui-core/internal/repo/gorm_scope"
 	"feng-sui-core/internal/service"
+	"feng-sui-core/internal/sink"
 )
 
 var deps = wire.NewSet(
@@ -19,9 +21,15 @@ var deps = wire.NewSet(
 	service.NewS3Service,
 	service.NewSyncTradeService,
 	service.NewCompressionService,
+	NewInventory,
 )
 
 var GraphSet = wire.NewSet(
 	deps,
 	NewApp,
 )
+
+// NewInventory reads back the checkpoints stored by the s3 sink of the backfill
+func NewInventory(s3Svc service.S3Service) *sink.Inventory {
+	return sink.NewS3Inventory(s3Svc, conf.Config.AwsBucket, conf.Config.BackfillRollupCheckpoints)
+}
@@ -15,11 +15,13 @@ import (
 func NewApp(
 	cronjob Cronjob,
 	master Master,
+	leaderElector LeaderElector,
 	compressionSvc service.CompressionService,
 ) App {
 	return &app{
 		cronjob:        cronjob,
 		master:         master,
+		leaderElector:  leaderElector,
 		compressionSvc: compressionSvc,
 	}
 }
@@ -32,6 +34,7 @@ type App interface {
 type app struct {
 	cronjob        Cronjob
 	master         Master
+	leaderElector  LeaderElector
 	compressionSvc service.CompressionService
 }
 
@@ -59,27 +62,30 @@ func (a *app) Start(ctx context.Context) error {
 	//}
 	//return nil
 
-	eg, childCtx := errgroup.WithContext(ctx)
-	// start cronjob for update failed block status
-	eg.Go(func() error {
-		if err := a.cronjob.Start(childCtx); err != nil {
-			logger.Errorf("failed to start cronjob: %v", err)
-			return fmt.Errorf("failed to start cronjob: %v", err)
-		}
-		return nil
-	})
+	logger.Info("master started!")
+	// only the leader runs the cronjobs and inserts new checkpoints, the other replicas are standby
+	return a.leaderElector.RunAsLeader(ctx, func(leaderCtx context.Context) error {
+		eg, childCtx := errgroup.WithContext(leaderCtx)
+		// start cronjob for update failed block status
+		eg.Go(func() error {
+			if err := a.cronjob.Start(childCtx); err != nil {
+				logger.Errorf("failed to start cronjob: %v", err)
+				return fmt.Errorf("failed to start cronjob: %v", err)
+			}
+			return nil
+		})
 
-	// fetch latest checkpoint periodically
-	eg.Go(func() error {
-		if err := a.master.FetchCheckpoint(childCtx); err != nil {
-			logger.Errorf("failed to fetch checkpoint: %v", err)
-			return fmt.Errorf("failed to fetch checkpoint: %v", err)
-		}
-		return nil
-	})
+		// fetch latest checkpoint periodically
+		eg.Go(func() error {
+			if err := a.master.FetchCheckpoint(childCtx); err != nil {
+				logger.Errorf("failed to fetch checkpoint: %v", err)
+				return fmt.Errorf("failed to fetch checkpoint: %v", err)
+			}
+			return nil
+		})
 
-	logger.Info("master started!")
-	return eg.Wait()
+		return eg.Wait()
+	})
 }
 
 func (a *app) Stop(ctx context.Context) error {
@@ -10,6 +10,7 @@ import (
 	"github.com/golang-module/carbon/v2"
 	"github.com/google/uuid"
 
+	"feng-sui-core/internal/conf"
 	"feng-sui-core/internal/repo"
 	"feng-sui-core/internal/service"
 	"feng-sui-core/pkg/alert"
@@ -19,11 +20,13 @@ func NewCronjob(
 	blockStatusRepo repo.BlockStatusRepo,
 	master Master,
 	compressionSvc service.CompressionService,
+	eventDedupRepo repo.EventDedupRepo,
 ) Cronjob {
 	return &cronjob{
 		blockStatusRepo: blockStatusRepo,
 		master:          master,
 		compressionSvc:  compressionSvc,
+		eventDedupRepo:  eventDedupRepo,
 	}
 }
 
@@ -31,6 +34,7 @@ type cronjob struct {
 	blockStatusRepo repo.BlockStatusRepo
 	master          Master
 	compressionSvc  service.CompressionService
+	eventDedupRepo  repo.EventDedupRepo
 }
 
 type Cronjob interface {
@@ -48,23 +52,24 @@ func (c *cronjob) Start(ctx context.Context) error {
 	}
 	defer func() { _ = s.Shutdown() }()
 
-	// update checkpoint status PENDING to FAIL if exceed 15 mins
+	// update block ranges PROCESSING to FAIL if their lease expired,
+	// workers also expire leases before claiming, this job keeps the statuses accurate when no worker is running
 	j1, err := s.NewJob(
 		gocron.CronJob(
-			"*/15 * * * *",
+			"* * * * *",
 			false,
 		),
 		gocron.NewTask(
 			func() {
-				logger.Info("start update failed block status...")
-				if err := c.blockStatusRepo.UpdateFailedStatus(ctx); err != nil {
-					logger.Errorf("failed to update block status: %v", err)
+				logger.Info("start expire block leases...")
+				if err := c.blockStatusRepo.ExpireLeases(ctx, conf.Config.MaxBlockAttempts); err != nil {
+					logger.Errorf("failed to expire block leases: %v", err)
 					return
 				}
-				logger.Info("end update failed block status!")
+				logger.Info("end expire block leases!")
 			},
 		),
-		gocron.WithName("update_failed_status"),
+		gocron.WithName("expire_block_leases"),
 		gocron.WithSingletonMode(gocron.LimitModeReschedule),
 		gocron.WithEventListeners(
 			gocron.AfterJobRuns(
@@ -162,6 +167,82 @@ func (c *cronjob) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to registered job %s: %v", j3.Name(), err)
 	}
 
+	// re-insert missing blocks and requeue done blocks without downstream data
+	j4, err := s.NewJob(
+		gocron.CronJob(
+			"30 * * * *",
+			false,
+		),
+		gocron.NewTask(
+			func() {
+				logger.Info("start heal block gaps...")
+				if err := c.master.HealGaps(ctx); err != nil {
+					logger.Errorf("failed to heal block gaps: %v", err)
+					return
+				}
+				logger.Info("end heal block gaps!")
+			},
+		),
+		gocron.WithName("heal_block_gaps"),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		gocron.WithEventListeners(
+			gocron.AfterJobRuns(
+				func(jobID uuid.UUID, jobName string) {
+					logger.Infof("job %s with id %s finished", jobName, jobID)
+				},
+			),
+			gocron.AfterJobRunsWithError(
+				func(jobID uuid.UUID, jobName string, err error) {
+					errMes := fmt.Sprintf("[sui-indexer] job %s with id %s failed: %v", jobName, jobID, err)
+					logger.Errorf(errMes)
+					alert.AlertDiscord(ctx, errMes)
+				},
+			),
+		),
+	)
+	if err != nil {
+		logger.Errorf("failed to registered job %s: %v", j4.Name(), err)
+		return fmt.Errorf("failed to registered job %s: %v", j4.Name(), err)
+	}
+
+	// delete expired keys of emitted events stored in Postgres
+	j5, err := s.NewJob(
+		gocron.CronJob(
+			"15 * * * *",
+			false,
+		),
+		gocron.NewTask(
+			func() {
+				logger.Info("start delete expired event dedup keys...")
+				if err := c.eventDedupRepo.DeleteExpired(ctx); err != nil {
+					logger.Errorf("failed to delete expired event dedup keys: %v", err)
+					return
+				}
+				logger.Info("end delete expired event dedup keys!")
+			},
+		),
+		gocron.WithName("delete_expired_event_dedup"),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		gocron.WithEventListeners(
+			gocron.AfterJobRuns(
+				func(jobID uuid.UUID, jobName string) {
+					logger.Infof("job %s with id %s finished", jobName, jobID)
+				},
+			),
+			gocron.AfterJobRunsWithError(
+				func(jobID uuid.UUID, jobName string, err error) {
+					errMes := fmt.Sprintf("[sui-indexer] job %s with id %s failed: %v", jobName, jobID, err)
+					logger.Errorf(errMes)
+					alert.AlertDiscord(ctx, errMes)
+				},
+			),
+		),
+	)
+	if err != nil {
+		logger.Errorf("failed to registered job %s: %v", j5.Name(), err)
+		return fmt.Errorf("failed to registered job %s: %v", j5.Name(), err)
+	}
+
 	s.Start() // non-blocking
 	logger.Infof("start cronjob scheduler...")
 
@@ -183,6 +264,14 @@ func (c *cronjob) Start(ctx context.Context) error {
 			j3LastRun, _ := j3.LastRun()
 			j3NextRun, _ := j3.NextRun()
 			logger.Infof("job %s last run: %s, next run: %s", j3.Name(), j3LastRun, j3NextRun)
+
+			j4LastRun, _ := j4.LastRun()
+			j4NextRun, _ := j4.NextRun()
+			logger.Infof("job %s last run: %s, next run: %s", j4.Name(), j4LastRun, j4NextRun)
+
+			j5LastRun, _ := j5.LastRun()
+			j5NextRun, _ := j5.NextRun()
+			logger.Infof("job %s last run: %s, next run: %s", j5.Name(), j5LastRun, j5NextRun)
 		}
 	}
 }
@@ -15,6 +15,7 @@ var deps = wire.NewSet(
 	infra.GraphSet,
 	gorm_scope.GraphSet,
 	gorm.GraphSet,
+	gorm.NewEventDedupRepo,
 	service.GraphSet,
 	service.NewCompressionService,
 )
@@ -23,5 +24,6 @@ var GraphSet = wire.NewSet(
 	deps,
 	NewCronjob,
 	NewMaster,
+	NewLeaderElector,
 	NewApp,
 )
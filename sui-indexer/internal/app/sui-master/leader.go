@@ -0,0 +1,151 @@
+package sui_master
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/getnimbus/ultrago/u_logger"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/pkg/alert"
+)
+
+var leadershipLostErr = errors.New("leadership lost")
+
+func NewLeaderElector(
+	db *gorm.DB,
+) (LeaderElector, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+
+	return &leaderElector{
+		db:            sqlDB,
+		id:            fmt.Sprintf("%v-%v", hostname, uuid.NewString()),
+		lockKey:       conf.Config.MasterLockKey,
+		retryInterval: 10 * time.Second,
+		checkInterval: 5 * time.Second,
+	}, nil
+}
+
+// LeaderElector elects a single leader among the master replicas with a Postgres session advisory lock.
+// The lock is held by a dedicated connection, so it is released by Postgres as soon as the leader dies.
+// It does not work behind a pooler in transaction mode (e.g. pgbouncer) because the lock belongs to the session.
+type LeaderElector interface {
+	// RunAsLeader waits until this replica is the leader, then runs f with a context canceled when the leadership is lost.
+	// It campaigns again after losing the leadership until ctx is done.
+	RunAsLeader(ctx context.Context, f func(ctx context.Context) error) error
+}
+
+type leaderElector struct {
+	db            *sql.DB
+	id            string
+	lockKey       int64
+	retryInterval time.Duration
+	checkInterval time.Duration
+}
+
+func (l *leaderElector) RunAsLeader(ctx context.Context, f func(ctx context.Context) error) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	for {
+		conn, err := l.acquire(ctx)
+		if err != nil {
+			logger.Errorf("failed to acquire leader lock: %v", err)
+		} else if conn == nil {
+			logger.Debugf("%v is standby, leader lock %v is held by another master", l.id, l.lockKey)
+		} else {
+			logger.Infof("%v became leader", l.id)
+			alert.AlertDiscord(ctx, fmt.Sprintf("[sui-indexer] master %v became leader", l.id))
+
+			err := l.lead(ctx, conn, f)
+			l.release(conn)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if !errors.Is(err, leadershipLostErr) {
+				return err
+			}
+			logger.Errorf("%v lost leadership, campaigning again", l.id)
+			alert.AlertDiscord(ctx, fmt.Sprintf("[sui-indexer] master %v lost leadership", l.id))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(l.retryInterval):
+		}
+	}
+}
+
+// acquire returns the connection holding the lock, or nil when another replica is the leader
+func (l *leaderElector) acquire(ctx context.Context) (*sql.Conn, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.lockKey).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !locked {
+		conn.Close()
+		return nil, nil
+	}
+	return conn, nil
+}
+
+func (l *leaderElector) lead(ctx context.Context, conn *sql.Conn, f func(ctx context.Context) error) error {
+	leaderCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// the lock is lost together with the connection
+	go func() {
+		ticker := time.NewTicker(l.checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-leaderCtx.Done():
+				return
+			case <-ticker.C:
+				if err := conn.PingContext(leaderCtx); err != nil && leaderCtx.Err() == nil {
+					cancel(fmt.Errorf("%w: %v", leadershipLostErr, err))
+					return
+				}
+			}
+		}
+	}()
+
+	if err := f(leaderCtx); err != nil {
+		return err
+	}
+	if cause := context.Cause(leaderCtx); errors.Is(cause, leadershipLostErr) {
+		return cause
+	}
+	return nil
+}
+
+func (l *leaderElector) release(conn *sql.Conn) {
+	logger := u_logger.NewLogger()
+
+	// use a fresh context because the leader context may be canceled already
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.lockKey); err != nil {
+		logger.Errorf("failed to release leader lock: %v", err)
+	}
+	if err := conn.Close(); err != nil {
+		logger.Errorf("failed to close leader connection: %v", err)
+	}
+}
@@ -3,12 +3,10 @@ package sui_master
 import (
 	"context"
 	"fmt"
-	"net/http"
-	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
-	sui_client "github.com/coming-chat/go-sui/v2/client"
 	"github.com/getnimbus/ultrago/u_logger"
 	"github.com/samber/lo"
 	"golang.org/x/sync/errgroup"
@@ -17,38 +15,23 @@ import (
 	"feng-sui-core/internal/entity"
 	"feng-sui-core/internal/repo"
 	"feng-sui-core/internal/service"
+	"feng-sui-core/internal/sink"
 	"feng-sui-core/pkg/alert"
 )
 
+const maxRangeSize = 1000
+
 func NewMaster(
 	blockStatusRepo repo.BlockStatusRepo,
 ) (Master, error) {
-	var transport *http.Transport
-	if conf.Config.IsUseProxy() {
-		proxyUrl, err := url.Parse(conf.Config.HttpProxy)
-		if err != nil {
-			return nil, err
-		}
-		transport = &http.Transport{Proxy: http.ProxyURL(proxyUrl)}
-	} else {
-		transport = http.DefaultTransport.(*http.Transport)
-	}
-
-	client, err := sui_client.DialWithClient(conf.Config.SuiRpc, &http.Client{
-		Transport: transport.Clone(),
-		Timeout:   2 * 60 * time.Second, // 2 mins
-	})
+	pool, err := service.NewSuiRpcPool()
 	if err != nil {
 		return nil, err
 	}
-	fallbackClient, _ := sui_client.DialWithClient(conf.Config.FallbackSuiRpc, &http.Client{
-		Transport: transport.Clone(),
-		Timeout:   2 * 60 * time.Second, // 2 mins
-	})
 
 	return &master{
 		blockStatusRepo: blockStatusRepo,
-		suiIndexer:      service.NewSuiIndexer(client, fallbackClient),
+		suiIndexer:      service.NewSuiIndexer(pool),
 		cooldown:        5 * time.Second,
 		numWorkers:      5,
 	}, nil
@@ -65,6 +48,7 @@ type Master interface {
 	FetchCheckpoint(ctx context.Context) error
 	SnapshotCheckpoint(ctx context.Context, from int64, to int64) error
 	Monitor(ctx context.Context) error
+	HealGaps(ctx context.Context) error
 }
 
 func (m *master) FetchCheckpoint(ctx context.Context) error {
@@ -87,15 +71,7 @@ func (m *master) FetchCheckpoint(ctx context.Context) error {
 func (m *master) SnapshotCheckpoint(ctx context.Context, from int64, to int64) error {
 	ctx, logger := u_logger.GetLogger(ctx)
 
-	var blocks = make([]*entity.BlockStatus, 0)
-	for i := to; i >= from; i-- {
-		blocks = append(blocks, &entity.BlockStatus{
-			Chain:       "SUI",
-			BlockNumber: i,
-			Status:      entity.BlockStatus_NOT_READY,
-			Type:        entity.BlockStatusType_BACKFILL,
-		})
-	}
+	var blocks = newBlockRanges(from, to, entity.BlockStatusType_BACKFILL)
 	if len(blocks) == 0 {
 		return nil
 	}
@@ -110,7 +86,10 @@ func (m *master) SnapshotCheckpoint(ctx context.Context, from int64, to int64) e
 			if err := m.blockStatusRepo.CreateMany(childCtx, chunk...); err != nil {
 				logger.Errorf("failed to snapshot checkpoints: %v", err)
 				for _, item := range chunk {
-					_ = m.blockStatusRepo.Save(childCtx, item)
+					// job heal_block_gaps does not heal backfill ranges, snapshot the range again to insert the missing blocks
+					if err := m.blockStatusRepo.Save(childCtx, item); err != nil {
+						logger.Errorf("failed to snapshot checkpoints %v-%v: %v", item.BlockNumber, item.ToBlockNumber, err)
+					}
 				}
 			}
 			return nil
@@ -142,19 +121,11 @@ func (m *master) fetchCheckpoint(ctx context.Context) error {
 	if len(savedCheckpoints) == 0 {
 		savedCheckpointSeq = 0
 	} else {
-		savedCheckpointSeq = savedCheckpoints[0].BlockNumber
+		savedCheckpointSeq = savedCheckpoints[0].ToBlockNumber
 	}
 
-	var blocks = make([]*entity.BlockStatus, 0)
 	// we delay 10 blocks from latest checkpoint
-	for i := savedCheckpointSeq + 1; i <= latestCheckpointSeq-10; i++ {
-		blocks = append(blocks, &entity.BlockStatus{
-			Chain:       "SUI",
-			BlockNumber: i,
-			Status:      entity.BlockStatus_NOT_READY,
-			Type:        entity.BlockStatusType_REALTIME,
-		})
-	}
+	var blocks = newBlockRanges(savedCheckpointSeq+1, latestCheckpointSeq-10, entity.BlockStatusType_REALTIME)
 	if len(blocks) == 0 {
 		return nil
 	}
@@ -169,7 +140,10 @@ func (m *master) fetchCheckpoint(ctx context.Context) error {
 			if err := m.blockStatusRepo.CreateMany(childCtx, chunk...); err != nil {
 				logger.Errorf("failed to save checkpoints: %v", err)
 				for _, item := range chunk {
-					_ = m.blockStatusRepo.Save(childCtx, item)
+					// the missing blocks will be re-inserted by job heal_block_gaps
+					if err := m.blockStatusRepo.Save(childCtx, item); err != nil {
+						logger.Errorf("failed to save checkpoints %v-%v: %v", item.BlockNumber, item.ToBlockNumber, err)
+					}
 				}
 			}
 			return nil
@@ -201,6 +175,100 @@ func (m *master) Monitor(ctx context.Context) error {
 	return nil
 }
 
+func (m *master) HealGaps(ctx context.Context) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	// only the realtime ranges are contiguous, the holes between backfill ranges are the blocks nobody asked to backfill
+	var reports = make([]string, 0)
+	gaps, err := m.blockStatusRepo.GetGaps(ctx, entity.BlockStatusType_REALTIME)
+	if err != nil {
+		logger.Errorf("failed to get gaps of realtime blocks: %v", err)
+		return err
+	}
+
+	var missingBlocks int64
+	for _, gap := range gaps {
+		var blocks = newBlockRanges(gap.From, gap.To, entity.BlockStatusType_REALTIME)
+		for _, chunk := range lo.Chunk(blocks, 1000) {
+			if err := m.blockStatusRepo.CreateMany(ctx, chunk...); err != nil {
+				logger.Errorf("failed to re-insert gap %v-%v: %v", gap.From, gap.To, err)
+				return err
+			}
+		}
+		missingBlocks += gap.Size()
+		logger.Infof("re-inserted gap %v-%v of realtime blocks", gap.From, gap.To)
+	}
+	if len(gaps) > 0 {
+		reports = append(reports, fmt.Sprintf("fixed %v realtime gaps (%v blocks) %v", len(gaps), missingBlocks, formatRanges(gaps, 10)))
+	}
+
+	// wait for kafka connect to sink sui index before checking downstream data,
+	// only the block types whose sinks fill sui_index are checked
+	var (
+		now        = time.Now()
+		indexTypes = make([]int, 0)
+	)
+	if sink.WritesIndexTable(conf.Config.WorkerSinks) {
+		indexTypes = append(indexTypes, entity.BlockStatusType_REALTIME)
+	}
+	if sink.WritesIndexTable(conf.Config.BackfillSinks) {
+		indexTypes = append(indexTypes, entity.BlockStatusType_BACKFILL)
+	}
+	doneBlocks, err := m.blockStatusRepo.GetDoneWithoutIndex(ctx, now.Add(-24*time.Hour), now.Add(-30*time.Minute), indexTypes...)
+	if err != nil {
+		logger.Errorf("failed to get done blocks without sui index: %v", err)
+		return err
+	}
+	if len(doneBlocks) > 0 {
+		// their events are still in the dedup store, so they are reprocessed without deduplication
+		if err := m.blockStatusRepo.Reprocess(ctx, lo.Map(doneBlocks, func(item *entity.BlockStatus, _ int) string {
+			return item.ID
+		})...); err != nil {
+			logger.Errorf("failed to requeue done blocks without sui index: %v", err)
+			return err
+		}
+		reports = append(reports, fmt.Sprintf("requeued %v done block ranges without sui index %v", len(doneBlocks), formatRanges(lo.Map(doneBlocks, func(item *entity.BlockStatus, _ int) *entity.BlockRange {
+			return item.Range()
+		}), 10)))
+	}
+
+	if len(reports) == 0 {
+		logger.Info("not found any gaps in block status")
+		return nil
+	}
+	var message = fmt.Sprintf("[sui-indexer] heal block gaps:\n%v", strings.Join(reports, "\n"))
+	logger.Info(message)
+	alert.AlertDiscord(ctx, message)
+
+	return nil
+}
+
+// newBlockRanges splits the blocks from..to (inclusive) into NOT_READY ranges of at most maxRangeSize blocks,
+// workers split them again when claiming
+func newBlockRanges(from int64, to int64, blockType int) []*entity.BlockStatus {
+	var blocks = make([]*entity.BlockStatus, 0)
+	for i := from; i <= to; i += maxRangeSize {
+		blocks = append(blocks, &entity.BlockStatus{
+			Chain:         "SUI",
+			BlockNumber:   i,
+			ToBlockNumber: min(i+maxRangeSize-1, to),
+			Status:        entity.BlockStatus_NOT_READY,
+			Type:          blockType,
+		})
+	}
+	return blocks
+}
+
+func formatRanges(ranges []*entity.BlockRange, limit int) string {
+	var items = lo.Map(lo.Slice(ranges, 0, limit), func(item *entity.BlockRange, _ int) string {
+		return fmt.Sprintf("%v-%v", item.From, item.To)
+	})
+	if len(ranges) > limit {
+		items = append(items, "...")
+	}
+	return fmt.Sprintf("[%v]", strings.Join(items, ", "))
+}
+
 func (m *master) getLatestCheckpoint(ctx context.Context) (int64, error) {
 	latestCheckpoint, err := m.suiIndexer.FetchLatestCheckpoint(ctx)
 	if err != nil {
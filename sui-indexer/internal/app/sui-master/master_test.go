@@ -0,0 +1,98 @@
+package sui_master
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/repo"
+)
+
+// memoryBlockStatusRepo keeps the block ranges in memory, only the methods used by HealGaps are implemented
+type memoryBlockStatusRepo struct {
+	repo.BlockStatusRepo
+	blocks []*entity.BlockStatus
+}
+
+func (r *memoryBlockStatusRepo) CreateMany(ctx context.Context, entities ...*entity.BlockStatus) error {
+	r.blocks = append(r.blocks, entities...)
+	return nil
+}
+
+func (r *memoryBlockStatusRepo) GetGaps(ctx context.Context, blockType int) ([]*entity.BlockRange, error) {
+	var blocks = make([]*entity.BlockStatus, 0)
+	for _, item := range r.blocks {
+		if item.Type == blockType {
+			blocks = append(blocks, item)
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].BlockNumber < blocks[j].BlockNumber
+	})
+
+	var gaps = make([]*entity.BlockRange, 0)
+	for i := 1; i < len(blocks); i++ {
+		if blocks[i].BlockNumber > blocks[i-1].ToBlockNumber+1 {
+			gaps = append(gaps, &entity.BlockRange{From: blocks[i-1].ToBlockNumber + 1, To: blocks[i].BlockNumber - 1})
+		}
+	}
+	return gaps, nil
+}
+
+func (r *memoryBlockStatusRepo) GetDoneWithoutIndex(ctx context.Context, from time.Time, to time.Time, blockTypes ...int) ([]*entity.BlockStatus, error) {
+	return nil, nil
+}
+
+func (r *memoryBlockStatusRepo) ranges(blockType int) []*entity.BlockRange {
+	var ranges = make([]*entity.BlockRange, 0)
+	for _, item := range r.blocks {
+		if item.Type == blockType {
+			ranges = append(ranges, item.Range())
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].From < ranges[j].From
+	})
+	return ranges
+}
+
+func TestHealGaps(t *testing.T) {
+	convey.Convey("TestHealGaps", t, func() {
+		ctx := context.Background()
+		blockStatusRepo := &memoryBlockStatusRepo{}
+		m := &master{blockStatusRepo: blockStatusRepo}
+
+		convey.Convey("The gaps of realtime ranges are re-inserted", func() {
+			_ = blockStatusRepo.CreateMany(ctx,
+				&entity.BlockStatus{Chain: "SUI", BlockNumber: 0, ToBlockNumber: 999, Type: entity.BlockStatusType_REALTIME, Status: entity.BlockStatus_DONE},
+				&entity.BlockStatus{Chain: "SUI", BlockNumber: 3000, ToBlockNumber: 3999, Type: entity.BlockStatusType_REALTIME, Status: entity.BlockStatus_NOT_READY},
+			)
+			convey.So(m.HealGaps(ctx), convey.ShouldBeNil)
+			convey.So(blockStatusRepo.ranges(entity.BlockStatusType_REALTIME), convey.ShouldResemble, []*entity.BlockRange{
+				{From: 0, To: 999},
+				{From: 1000, To: 1999},
+				{From: 2000, To: 2999},
+				{From: 3000, To: 3999},
+			})
+			gaps, _ := blockStatusRepo.GetGaps(ctx, entity.BlockStatusType_REALTIME)
+			convey.So(gaps, convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("Separate backfill ranges are left alone", func() {
+			_ = blockStatusRepo.CreateMany(ctx,
+				&entity.BlockStatus{Chain: "SUI", BlockNumber: 100, ToBlockNumber: 199, Type: entity.BlockStatusType_BACKFILL, Status: entity.BlockStatus_DONE},
+				&entity.BlockStatus{Chain: "SUI", BlockNumber: 5_000_000, ToBlockNumber: 5_000_999, Type: entity.BlockStatusType_BACKFILL, Status: entity.BlockStatus_NOT_READY},
+			)
+			convey.So(m.HealGaps(ctx), convey.ShouldBeNil)
+			convey.So(blockStatusRepo.blocks, convey.ShouldHaveLength, 2)
+			convey.So(blockStatusRepo.ranges(entity.BlockStatusType_BACKFILL), convey.ShouldResemble, []*entity.BlockRange{
+				{From: 100, To: 199},
+				{From: 5_000_000, To: 5_000_999},
+			})
+		})
+	})
+}
@@ -7,20 +7,36 @@ import (
 	"feng-sui-core/internal/infra"
 	"feng-sui-core/internal/repo/gorm"
 	"feng-sui-core/internal/repo/gorm_scope"
+	"feng-sui-core/internal/repo/memory"
 	"feng-sui-core/internal/service"
 )
 
 var deps = wire.NewSet(
 	u_http_client.NewHttpExecutor,
 	infra.GraphSet,
-	infra.NewKafkaSyncProducer,
 	gorm_scope.GraphSet,
 	gorm.GraphSet,
 	service.GraphSet,
+	service.NewBlockRangeService,
+	service.NewCheckpointVerifier,
 )
 
 var GraphSet = wire.NewSet(
 	deps,
+	NewEventDedupRepo,
+	NewSink,
 	NewWorker,
 	NewApp,
 )
+
+// OfflineGraphSet runs the worker without Postgres, Kafka nor Redis
+var OfflineGraphSet = wire.NewSet(
+	NewLocalBlockStore,
+	wire.Bind(new(service.BaseService), new(service.LocalBlockStore)),
+	wire.Bind(new(service.BlockRangeService), new(service.LocalBlockStore)),
+	wire.Bind(new(service.CheckpointVerifier), new(service.LocalBlockStore)),
+	memory.NewEventDedupRepo,
+	NewOfflineSink,
+	NewWorker,
+	NewOfflineApp,
+)
@@ -0,0 +1,34 @@
+package sui_worker
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/infra"
+	"feng-sui-core/internal/repo"
+	gorm_repo "feng-sui-core/internal/repo/gorm"
+	memory_repo "feng-sui-core/internal/repo/memory"
+	redis_repo "feng-sui-core/internal/repo/redis"
+)
+
+// NewEventDedupRepo returns the store of emitted events chosen by EVENT_DEDUP_STORE,
+// it is shared by all worker replicas and survives restarts
+func NewEventDedupRepo(db *gorm.DB) (repo.EventDedupRepo, func(), error) {
+	switch strings.ToLower(conf.Config.EventDedupStore) {
+	case "redis":
+		rd, cleanup, err := infra.NewRedisClient()
+		if err != nil {
+			return nil, nil, err
+		}
+		return redis_repo.NewEventDedupRepo(rd), cleanup, nil
+	case "postgres":
+		return gorm_repo.NewEventDedupRepo(gorm_repo.NewBaseRepo(db)), func() {}, nil
+	case "memory":
+		return memory_repo.NewEventDedupRepo(), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported event dedup store %v", conf.Config.EventDedupStore)
+	}
+}
@@ -0,0 +1,92 @@
+package sui_worker
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/getnimbus/ultrago/u_logger"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/service"
+	"feng-sui-core/internal/sink"
+)
+
+// NewLocalBlockStore opens the block store of the offline mode in SINK_LOCAL_DIR
+// and adds the checkpoints OFFLINE_FROM_CHECKPOINT..OFFLINE_TO_CHECKPOINT to it
+func NewLocalBlockStore(ctx context.Context) (service.LocalBlockStore, error) {
+	var (
+		from = conf.Config.OfflineFromCheckpoint
+		to   = conf.Config.OfflineToCheckpoint
+	)
+	if from < 0 || to < from {
+		return nil, fmt.Errorf("invalid offline checkpoint range %v-%v", from, to)
+	}
+
+	store, err := service.NewLocalBlockStore(filepath.Join(conf.Config.SinkLocalDir, "block_status.json"))
+	if err != nil {
+		return nil, err
+	}
+	if err := store.AddRange(ctx, entity.BlockStatusType_REALTIME, from, to); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewOfflineSink returns the sinks of the offline mode chosen by OFFLINE_SINKS
+func NewOfflineSink(ctx context.Context) (sink.Sink, func(), error) {
+	return sink.New(ctx, conf.Config.OfflineSinks, nil, sink.RollupConfig{})
+}
+
+// NewOfflineApp runs the worker until all checkpoints of the local block store are DONE or DEAD
+func NewOfflineApp(
+	worker Worker,
+	blockStore service.LocalBlockStore,
+) App {
+	return &offlineApp{
+		worker:     worker,
+		blockStore: blockStore,
+	}
+}
+
+type offlineApp struct {
+	worker     Worker
+	blockStore service.LocalBlockStore
+}
+
+func (a *offlineApp) Start(ctx context.Context) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	childCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(conf.Config.WorkerCooldown)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-childCtx.Done():
+				return
+			case <-ticker.C:
+				if remaining := a.blockStore.Remaining(childCtx); remaining > 0 {
+					logger.Infof("%v checkpoints remaining", remaining)
+					continue
+				}
+				logger.Infof("indexed checkpoints %v-%v", conf.Config.OfflineFromCheckpoint, conf.Config.OfflineToCheckpoint)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	logger.Info("offline worker started!")
+	return a.worker.FetchTxs(childCtx)
+}
+
+func (a *offlineApp) Stop(ctx context.Context) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+	logger.Infof("offline worker stopped!")
+	return nil
+}
@@ -0,0 +1,407 @@
+package sui_worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/getnimbus/ultrago/u_logger"
+	"github.com/samber/lo"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/entity_dto/sui_model"
+	"feng-sui-core/internal/infra"
+	"feng-sui-core/internal/sink"
+	"feng-sui-core/pkg/alert"
+	"feng-sui-core/pkg/sui_rpc"
+)
+
+// rangeTask is a claimed block range, it is acknowledged once all its checkpoints are finished
+type rangeTask struct {
+	blockStatus *entity.BlockStatus
+	leaseCtx    context.Context
+	cancel      context.CancelFunc
+	checkpoints []*sui_model.Checkpoint
+	results     []*entity.BlockResult
+	pending     sync.WaitGroup
+}
+
+// checkpointTask is a checkpoint of a range flowing through the fetch txs, transform and emit stages
+type checkpointTask struct {
+	rangeTask  *rangeTask
+	checkpoint *sui_model.Checkpoint
+	result     *entity.BlockResult
+	txs        []*sui_model.Transaction
+	batch      *sink.Batch
+	eventKeys  []string
+	eventCount int
+}
+
+// finish records the outcome of the checkpoint, the checkpoint must not be used by any stage afterward
+func (t *checkpointTask) finish(err error) {
+	if err != nil {
+		t.result.Err = err
+	} else {
+		t.result.Digest = t.checkpoint.Digest
+		t.result.PreviousDigest = t.checkpoint.PreviousDigest
+		t.result.EventCount = t.eventCount
+	}
+	t.rangeTask.pending.Done()
+}
+
+// runStage starts n workers consuming the input of a stage and calls done once all of them returned
+func runStage(n int, work func(), done func()) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		done()
+	}()
+}
+
+// claimStage claims block ranges until ctx is done.
+// acks is bounded by the ranges in flight, so claiming waits for the oldest range to be acknowledged.
+func (w *worker) claimStage(ctx context.Context, claimed chan<- *rangeTask, acks chan<- *rangeTask) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	defer close(claimed)
+	defer close(acks)
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Infof("stop claiming block ranges!")
+			return
+		default:
+		}
+
+		blockStatus, err := w.blockRangeSvc.Claim(ctx, entity.BlockStatusType_REALTIME, w.owner, w.rangeSize)
+		if err != nil || blockStatus == nil {
+			if err != nil {
+				logger.Errorf("failed to claim block range from db: %v", err)
+			} else {
+				logger.Warnf("not found any new blocks in db")
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.cooldown): // cooldown db
+			}
+			continue
+		}
+
+		task := &rangeTask{
+			blockStatus: blockStatus,
+			results:     make([]*entity.BlockResult, blockStatus.Size()),
+		}
+		for i := range task.results {
+			task.results[i] = &entity.BlockResult{BlockNumber: blockStatus.BlockNumber + int64(i)}
+		}
+		task.pending.Add(len(task.results))
+
+		// keep the lease while processing, stop processing when the lease is lost
+		task.leaseCtx, task.cancel = context.WithCancel(ctx)
+		go func() {
+			if err := w.blockRangeSvc.KeepAlive(task.leaseCtx, blockStatus, w.owner); err != nil {
+				logger.Errorf("stop processing block range: %v", err)
+				task.cancel()
+			}
+		}()
+
+		acks <- task
+		claimed <- task
+	}
+}
+
+// fetchCheckpointStage fetches and verifies the checkpoints of a range, then dispatches them one by one
+func (w *worker) fetchCheckpointStage(ctx context.Context, task *rangeTask, out chan<- *checkpointTask) {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	// dispatched counts the checkpoints already sent to the next stage or finished,
+	// the remaining ones are finished here on panic so that the range is still acknowledged
+	var dispatched int
+	defer func() {
+		if re := recover(); re != nil {
+			logger.Errorf("panic: %v", re)
+			for _, result := range task.results[dispatched:] {
+				if result.Err == nil {
+					result.Err = fmt.Errorf("panic: %v", re)
+				}
+				task.pending.Done()
+			}
+		}
+	}()
+
+	previous, checkpoints := w.fetchCheckpoints(task.leaseCtx, task.results)
+	task.checkpoints = checkpoints
+	for i, c := range task.checkpoints {
+		if c == nil {
+			continue
+		}
+		checkpoint := c.WithDateKey()
+		if err := checkpoint.Validate(); err != nil {
+			logger.Errorf("invalid checkpoint: %v", err)
+			task.results[i].Err = err
+			continue
+		}
+		if checkpoint.SequenceNumber != strconv.FormatInt(task.results[i].BlockNumber, 10) {
+			logger.Warnf("not found sequence number of checkpoint in block status")
+			task.results[i].Err = fmt.Errorf("unexpected checkpoint %v for block %v", checkpoint.SequenceNumber, task.results[i].BlockNumber)
+			continue
+		}
+		task.checkpoints[i] = checkpoint
+	}
+
+	// verify checkpoints against each other and the stored digests before emitting
+	if err := w.verifyChain(task.leaseCtx, task.checkpoints, task.results); err != nil {
+		logger.Errorf("failed to verify checkpoint chain: %v", err)
+		for _, result := range task.results {
+			if result.Err == nil {
+				result.Err = err
+			}
+		}
+	}
+
+	// the checkpoints must be the ones certified by the validators
+	if err := w.verifySignatures(task.leaseCtx, task.checkpoints, task.results); err != nil {
+		logger.Errorf("failed to verify checkpoint signatures: %v", err)
+		for _, result := range task.results {
+			if result.Err == nil {
+				result.Err = err
+			}
+		}
+	}
+
+	// the network totals of consecutive checkpoints must add up with their txs, from the checkpoint before the range
+	for i, err := range sui_model.VerifyTotals(previous, task.checkpoints) {
+		logger.Errorf("invalid checkpoint: %v", err)
+		if task.results[i].Err == nil {
+			task.results[i].Err = err
+		}
+	}
+
+	for i, checkpoint := range task.checkpoints {
+		cpTask := &checkpointTask{
+			rangeTask:  task,
+			checkpoint: checkpoint,
+			result:     task.results[i],
+		}
+		if checkpoint == nil || cpTask.result.Err != nil {
+			if cpTask.result.Err == nil {
+				cpTask.result.Err = fmt.Errorf("missing checkpoint %v", cpTask.result.BlockNumber)
+			}
+			task.pending.Done()
+			dispatched++
+			continue
+		}
+		out <- cpTask
+		dispatched++
+	}
+}
+
+// fetchTxsStage fetches all txs of a checkpoint
+func (w *worker) fetchTxsStage(ctx context.Context, task *checkpointTask, out chan<- *checkpointTask) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	if err := w.runTask(task, func() error {
+		uniqueTxs := lo.Uniq(task.checkpoint.Transactions)
+		for _, txDigests := range lo.Chunk(uniqueTxs, 20) {
+			txs, err := retry.DoWithData(
+				func() ([]*sui_model.Transaction, error) {
+					return w.suiIndexer.FetchTxs(task.rangeTask.leaseCtx, txDigests...)
+				},
+				// retry configs
+				[]retry.Option{
+					retry.Attempts(uint(5)),
+					retry.OnRetry(func(n uint, err error) {
+						logger.Errorf("Retry invoke function FetchTxs %d to and get error: %v", n+1, err)
+					}),
+					sui_rpc.RetryDelay(3 * time.Second),
+					retry.Context(task.rangeTask.leaseCtx),
+				}...,
+			)
+			if err != nil {
+				if !errors.Is(err, context.Canceled) {
+					alert.AlertDiscord(ctx, fmt.Sprintf("[sui-indexer] failed to fetch txs: %v", err))
+				}
+				return err
+			}
+			task.txs = append(task.txs, txs...)
+		}
+		// never emit a partial checkpoint
+		if err := task.checkpoint.VerifyTxs(task.txs); err != nil {
+			alert.AlertDiscord(ctx, fmt.Sprintf("[sui-indexer] incomplete checkpoint: %v", err))
+			return err
+		}
+		return nil
+	}); err != nil {
+		logger.Errorf("failed to fetch txs of checkpoint %v: %v", task.checkpoint.SequenceNumber, err)
+		return
+	}
+	out <- task
+}
+
+// transformStage builds the messages of a checkpoint, skipping the events already emitted
+func (w *worker) transformStage(ctx context.Context, task *checkpointTask, out chan<- *checkpointTask) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	if err := w.runTask(task, func() error {
+		checkpoint := task.checkpoint
+		if err := checkpoint.SetBloomFilter(task.txs); err != nil {
+			return err
+		}
+
+		// skip the events already emitted by a previous attempt or another worker,
+		// unless the range is reprocessed because its data is missing downstream
+		var emitted = make(map[string]bool)
+		if !task.rangeTask.blockStatus.SkipDedup {
+			var keys = make([]string, 0)
+			for _, tx := range task.txs {
+				for _, event := range tx.Events {
+					keys = append(keys, eventKey(checkpoint.SequenceNumber, tx.Digest, event.Id.EventSeq.Int64()))
+				}
+			}
+			var err error
+			if emitted, err = w.eventDedupRepo.Exists(task.rangeTask.leaseCtx, keys...); err != nil {
+				logger.Errorf("failed to check emitted events: %v", err)
+				return err
+			}
+		}
+
+		var (
+			parsedTxs      = make([]*sui_model.Transaction, 0, len(task.txs))
+			parsedEvents   = make([]*sui_model.Event, 0)
+			routedEvents   = make(map[string][]infra.KafkaMsg)
+			indices        = make([]*entity.SuiIndex, 0)
+			objectChanges  = make([]*sui_model.ObjectChange, 0)
+			balanceChanges = make([]*sui_model.BalanceChange, 0)
+			checkpointSeq  = task.result.BlockNumber
+		)
+		for _, tx := range task.txs {
+			if err := tx.Validate(); err != nil {
+				logger.Errorf("invalid tx: %v", err)
+				return fmt.Errorf("invalid tx: %v", err)
+			}
+			parsedTxs = append(parsedTxs, tx.WithDateKey())
+
+			objectChanges = append(objectChanges, tx.ParseObjectChanges()...)
+			balanceChanges = append(balanceChanges, tx.ParseBalanceChanges()...)
+
+			events, err := tx.ParseEvents()
+			if err != nil {
+				logger.Errorf("failed to parse events: %v", err)
+				return err
+			}
+			// the event count is the number of index rows of the checkpoint, emitted now or before
+			task.eventCount += len(events)
+			for _, event := range events {
+				key := eventKey(checkpoint.SequenceNumber, tx.Digest, event.Id.EventSeq.Int64())
+				if emitted[key] {
+					continue
+				}
+				task.eventKeys = append(task.eventKeys, key)
+
+				if w.eventFilter.Accept(event.SuiEvent) {
+					parsedEvents = append(parsedEvents, event)
+				}
+				for _, topic := range w.eventFilter.Topics(event.SuiEvent) {
+					routedEvents[topic] = append(routedEvents[topic], event)
+				}
+				indices = append(indices, entity.NewSuiIndex(checkpoint, checkpointSeq, event))
+			}
+		}
+
+		// the checkpoint is sent with its bloom filters built from all txs
+		task.batch = &sink.Batch{
+			DateKey:    checkpoint.DateKey,
+			Checkpoint: checkpoint.SequenceNumber,
+		}
+		task.batch.Add(sink.Dataset_TXS, w.txsTopic, lo.Map(parsedTxs, func(item *sui_model.Transaction, _ int) infra.KafkaMsg {
+			return item
+		})...)
+		task.batch.Add(sink.Dataset_EVENTS, w.eventsTopic, lo.Map(parsedEvents, func(item *sui_model.Event, _ int) infra.KafkaMsg {
+			return item
+		})...)
+		task.batch.Add(sink.Dataset_INDEX, w.indexTopic, lo.Map(indices, func(item *entity.SuiIndex, _ int) infra.KafkaMsg {
+			return item.ToKafka()
+		})...)
+		task.batch.Add(sink.Dataset_OBJECT_CHANGES, w.objectChangesTopic, lo.Map(objectChanges, func(item *sui_model.ObjectChange, _ int) infra.KafkaMsg {
+			return item
+		})...)
+		task.batch.Add(sink.Dataset_BALANCE_CHANGES, w.balanceChangesTopic, lo.Map(balanceChanges, func(item *sui_model.BalanceChange, _ int) infra.KafkaMsg {
+			return item
+		})...)
+		task.batch.Add(sink.Dataset_CHECKPOINTS, w.checkpointsTopic, checkpoint)
+		for topic, events := range routedEvents {
+			task.batch.Add(sink.Dataset_EVENTS, topic, events...)
+		}
+		task.txs = nil
+		return nil
+	}); err != nil {
+		logger.Errorf("failed to transform checkpoint %v: %v", task.checkpoint.SequenceNumber, err)
+		return
+	}
+	out <- task
+}
+
+// emitStage writes all records of a checkpoint to the sinks, in one kafka transaction for the kafka sink
+func (w *worker) emitStage(ctx context.Context, task *checkpointTask) {
+	ctx, logger := u_logger.GetLogger(ctx)
+	if err := w.runTask(task, func() error {
+		if err := w.sink.Write(task.rangeTask.leaseCtx, task.batch); err != nil {
+			logger.Errorf("failed to write checkpoint to sinks: %v", err)
+			return err
+		}
+		task.batch = nil
+
+		// events are marked emitted only once the batch is written,
+		// the records are already in the sinks so a failure here must not fail the checkpoint
+		if err := w.eventDedupRepo.Add(task.rangeTask.leaseCtx, conf.Config.EventDedupTtl, task.eventKeys...); err != nil {
+			logger.Errorf("failed to mark events of checkpoint %v emitted: %v", task.checkpoint.SequenceNumber, err)
+		}
+		return nil
+	}); err != nil {
+		logger.Errorf("failed to emit checkpoint %v: %v", task.checkpoint.SequenceNumber, err)
+		return
+	}
+	task.finish(nil)
+}
+
+// ackStage completes the ranges in the order they were claimed
+func (w *worker) ackStage(ctx context.Context, acks <-chan *rangeTask) {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	for task := range acks {
+		task.pending.Wait()
+
+		// release the lease by completing the range, even when the worker is stopping
+		task.cancel()
+		if err := w.completeRange(context.WithoutCancel(ctx), task.blockStatus, task.checkpoints, task.results); err != nil {
+			logger.Errorf("failed to complete block range %v-%v: %v", task.blockStatus.BlockNumber, task.blockStatus.ToBlockNumber, err)
+		}
+	}
+}
+
+// runTask runs f for task and finishes task with the error of f, panics are recovered as errors
+func (w *worker) runTask(task *checkpointTask, f func() error) (err error) {
+	defer func() {
+		if re := recover(); re != nil {
+			err = fmt.Errorf("panic: %v", re)
+		}
+		if err != nil {
+			task.finish(err)
+		}
+	}()
+
+	if err := task.rangeTask.leaseCtx.Err(); err != nil {
+		return err
+	}
+	return f()
+}
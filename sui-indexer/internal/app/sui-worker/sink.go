@@ -0,0 +1,15 @@
+package sui_worker
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/sink"
+)
+
+// NewSink returns the sinks of the worker chosen by WORKER_SINKS
+func NewSink(ctx context.Context, db *gorm.DB) (sink.Sink, func(), error) {
+	return sink.New(ctx, conf.Config.WorkerSinks, db, sink.RollupConfig{})
+}
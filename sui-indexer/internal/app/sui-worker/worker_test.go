@@ -0,0 +1,127 @@
+package sui_worker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/entity_dto/sui_model"
+	memory_repo "feng-sui-core/internal/repo/memory"
+	"feng-sui-core/internal/service"
+	"feng-sui-core/internal/setting"
+	"feng-sui-core/internal/sink"
+)
+
+// countLines returns the number of json lines of the local sink files of dataset and topic in dir
+func countLines(dir string, dataset string, topic string) int {
+	paths, _ := filepath.Glob(filepath.Join(dir, dataset, topic, "*.jsonl"))
+	var lines int
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return -1
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			lines++
+		}
+		f.Close()
+	}
+	return lines
+}
+
+// failingSignatureVerifier fails checkpoint seq the first times times
+type failingSignatureVerifier struct {
+	seq   int64
+	times atomic.Int64
+}
+
+func (v *failingSignatureVerifier) VerifySignatures(ctx context.Context, checkpoints ...*sui_model.Checkpoint) (map[int64]error, error) {
+	var failures = make(map[int64]error)
+	for _, checkpoint := range checkpoints {
+		if checkpoint.SequenceNumber == strconv.FormatInt(v.seq, 10) && v.times.Add(-1) >= 0 {
+			failures[v.seq] = fmt.Errorf("%w: checkpoint %v", setting.CheckpointCertificateErr, v.seq)
+		}
+	}
+	return failures, nil
+}
+
+func TestWorker(t *testing.T) {
+	convey.Convey("TestWorker", t, func() {
+		ctx := context.Background()
+
+		// the rpc fixtures of the checkpoints 999..1011, 2 txs per checkpoint and 1 event per checkpoint
+		handler, err := service.NewRpcReplayHandler(filepath.Join("..", "..", "service", "testdata", "rpc"))
+		convey.So(err, convey.ShouldBeNil)
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		saved := conf.Config
+		defer func() {
+			conf.Config = saved
+		}()
+		dir := t.TempDir()
+		conf.Config.SuiRpcs = server.URL
+		conf.Config.SinkLocalDir = dir
+		conf.Config.SinkLocalFormat = sink.LocalFormat_JSONL
+		conf.Config.OfflineFromCheckpoint = 1000
+		conf.Config.OfflineToCheckpoint = 1011
+		conf.Config.OfflineSinks = sink.Name_LOCAL
+		conf.Config.WorkerRangeSize = 5
+		conf.Config.WorkerCooldown = 50 * time.Millisecond
+
+		convey.Convey("Offline worker indexes the checkpoints served by the rpc", func() {
+			blockStore, err := NewLocalBlockStore(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			localSink, cleanup, err := NewOfflineSink(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			worker, err := NewWorker(localSink, blockStore, blockStore, blockStore, memory_repo.NewEventDedupRepo())
+			convey.So(err, convey.ShouldBeNil)
+
+			timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			convey.So(NewOfflineApp(worker, blockStore).Start(timeoutCtx), convey.ShouldBeNil)
+			cleanup()
+			convey.So(timeoutCtx.Err(), convey.ShouldBeNil)
+			convey.So(blockStore.Remaining(ctx), convey.ShouldEqual, 0)
+
+			convey.So(countLines(dir, sink.Dataset_CHECKPOINTS, conf.Config.SuiCheckpointsTopic), convey.ShouldEqual, 12)
+			convey.So(countLines(dir, sink.Dataset_TXS, conf.Config.SuiTxsTopic), convey.ShouldEqual, 24)
+			convey.So(countLines(dir, sink.Dataset_EVENTS, conf.Config.SuiEventsTopic), convey.ShouldEqual, 12)
+			convey.So(countLines(dir, sink.Dataset_OBJECT_CHANGES, conf.Config.SuiObjectChangesTopic), convey.ShouldEqual, 48)
+			convey.So(countLines(dir, sink.Dataset_BALANCE_CHANGES, conf.Config.SuiBalanceChangesTopic), convey.ShouldEqual, 24)
+		})
+
+		convey.Convey("Checkpoints failing their certificate are only emitted once verified", func() {
+			blockStore, err := NewLocalBlockStore(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			localSink, cleanup, err := NewOfflineSink(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			w, err := NewWorker(localSink, blockStore, blockStore, blockStore, memory_repo.NewEventDedupRepo())
+			convey.So(err, convey.ShouldBeNil)
+			verifier := &failingSignatureVerifier{seq: 1003}
+			verifier.times.Store(2)
+			w.(*worker).signatureVerifier = verifier
+
+			timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			convey.So(NewOfflineApp(w, blockStore).Start(timeoutCtx), convey.ShouldBeNil)
+			cleanup()
+			convey.So(timeoutCtx.Err(), convey.ShouldBeNil)
+			convey.So(verifier.times.Load(), convey.ShouldBeLessThan, 0)
+			convey.So(countLines(dir, sink.Dataset_CHECKPOINTS, conf.Config.SuiCheckpointsTopic), convey.ShouldEqual, 12)
+			convey.So(countLines(dir, sink.Dataset_TXS, conf.Config.SuiTxsTopic), convey.ShouldEqual, 24)
+		})
+	})
+}
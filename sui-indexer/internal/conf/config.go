@@ -3,6 +3,7 @@ package conf
 import (
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/creasty/defaults"
 	"github.com/getnimbus/ultrago/u_logger"
@@ -39,24 +40,83 @@ type config struct {
 	AthenaQueryResult  string `mapstructure:"ATHENA_QUERY_RESULT" default:"s3://nimbus-result/athena-query/"`
 
 	// kafka
-	KafkaBrokers        string `mapstructure:"KAFKA_BROKERS" default:"localhost:9092"`
-	KafkaConsumerGroup  string `mapstructure:"KAFKA_CONSUMER_GROUP" default:"feng-sui-consumer"`
-	KafkaUsername       string `mapstructure:"KAFKA_USERNAME" default:"-"`
-	KafkaPassword       string `mapstructure:"KAFKA_PASSWORD" default:"-"`
-	SuiCheckpointsTopic string `mapstructure:"SUI_CHECKPOINT_TOPIC" default:"sui-checkpoints"`
-	SuiTxsTopic         string `mapstructure:"SUI_TXS_TOPIC" default:"sui-txs"`
-	SuiEventsTopic      string `mapstructure:"SUI_EVENTS_TOPIC" default:"sui-events"`
-	SuiIndexTopic       string `mapstructure:"SUI_INDEX_TOPIC" default:"sui-index"`
+	KafkaBrokers           string `mapstructure:"KAFKA_BROKERS" default:"localhost:9092"`
+	KafkaConsumerGroup     string `mapstructure:"KAFKA_CONSUMER_GROUP" default:"feng-sui-consumer"`
+	KafkaUsername          string `mapstructure:"KAFKA_USERNAME" default:"-"`
+	KafkaPassword          string `mapstructure:"KAFKA_PASSWORD" default:"-"`
+	KafkaTransactionId     string `mapstructure:"KAFKA_TRANSACTION_ID" default:"txn_sui_indexer_producer"`
+	SuiCheckpointsTopic    string `mapstructure:"SUI_CHECKPOINT_TOPIC" default:"sui-checkpoints"`
+	SuiTxsTopic            string `mapstructure:"SUI_TXS_TOPIC" default:"sui-txs"`
+	SuiEventsTopic         string `mapstructure:"SUI_EVENTS_TOPIC" default:"sui-events"`
+	SuiIndexTopic          string `mapstructure:"SUI_INDEX_TOPIC" default:"sui-index"`
+	SuiObjectChangesTopic  string `mapstructure:"SUI_OBJECT_CHANGES_TOPIC" default:"sui-object-changes"`
+	SuiBalanceChangesTopic string `mapstructure:"SUI_BALANCE_CHANGES_TOPIC" default:"sui-balance-changes"`
 
 	// redis
 	RedisAddress  string `mapstructure:"REDIS_ADDRESS" default:"localhost:6379"`
 	RedisPassword string `mapstructure:"REDIS_PASSWORD" default:"-"`
 	RedisDB       int    `mapstructure:"REDIS_DB" default:"0"`
 
+	// indexer
+	MaxBlockAttempts     int           `mapstructure:"MAX_BLOCK_ATTEMPTS" default:"10"`
+	BlockRetryBackoff    time.Duration `mapstructure:"BLOCK_RETRY_BACKOFF" default:"30s"`
+	BlockMaxRetryBackoff time.Duration `mapstructure:"BLOCK_MAX_RETRY_BACKOFF" default:"30m"`
+	BlockLeaseDuration   time.Duration `mapstructure:"BLOCK_LEASE_DURATION" default:"2m"`
+	MasterLockKey        int64         `mapstructure:"MASTER_LOCK_KEY" default:"784512001"`
+	EventDedupStore      string        `mapstructure:"EVENT_DEDUP_STORE" default:"postgres"`
+	EventDedupTtl        time.Duration `mapstructure:"EVENT_DEDUP_TTL" default:"24h"`
+
+	// worker pipeline
+	WorkerRangeSize                  int64         `mapstructure:"WORKER_RANGE_SIZE" default:"10"`
+	WorkerInFlightRanges             int           `mapstructure:"WORKER_IN_FLIGHT_RANGES" default:"10"`
+	WorkerFetchCheckpointConcurrency int           `mapstructure:"WORKER_FETCH_CHECKPOINT_CONCURRENCY" default:"4"`
+	WorkerFetchTxsConcurrency        int           `mapstructure:"WORKER_FETCH_TXS_CONCURRENCY" default:"20"`
+	WorkerTransformConcurrency       int           `mapstructure:"WORKER_TRANSFORM_CONCURRENCY" default:"4"`
+	WorkerEmitConcurrency            int           `mapstructure:"WORKER_EMIT_CONCURRENCY" default:"8"`
+	WorkerCooldown                   time.Duration `mapstructure:"WORKER_COOLDOWN" default:"3s"`
+	EventFilterFile                  string        `mapstructure:"EVENT_FILTER_FILE" default:"-"`
+
+	// sinks
+	WorkerSinks               string        `mapstructure:"WORKER_SINKS" default:"kafka"`
+	BackfillSinks             string        `mapstructure:"BACKFILL_SINKS" default:"s3"`
+	S3ParquetPrefix           string        `mapstructure:"S3_PARQUET_PREFIX" default:"compressed"`
+	BackfillRollupCheckpoints int64         `mapstructure:"BACKFILL_ROLLUP_CHECKPOINTS" default:"100"`
+	BackfillRollupMaxRecords  int           `mapstructure:"BACKFILL_ROLLUP_MAX_RECORDS" default:"200000"`
+	BackfillRollupMaxWait     time.Duration `mapstructure:"BACKFILL_ROLLUP_MAX_WAIT" default:"1m"`
+	BackfillSkipExisting      string        `mapstructure:"BACKFILL_SKIP_EXISTING" default:"no"`
+	BackfillProgressInterval  time.Duration `mapstructure:"BACKFILL_PROGRESS_INTERVAL" default:"10m"`
+	SinkLocalDir              string        `mapstructure:"SINK_LOCAL_DIR" default:"./data"`
+	SinkLocalFormat           string        `mapstructure:"SINK_LOCAL_FORMAT" default:"gzip"`
+	SinkLocalMaxFileSize      int64         `mapstructure:"SINK_LOCAL_MAX_FILE_SIZE" default:"134217728"`
+
+	// offline mode
+	Offline               string `mapstructure:"OFFLINE" default:"no"`
+	OfflineFromCheckpoint int64  `mapstructure:"OFFLINE_FROM_CHECKPOINT" default:"0"`
+	OfflineToCheckpoint   int64  `mapstructure:"OFFLINE_TO_CHECKPOINT" default:"0"`
+	OfflineSinks          string `mapstructure:"OFFLINE_SINKS" default:"local"`
+
 	// external service
 	SuiRpc         string `mapstructure:"SUI_RPC" default:"https://sui-mainnet-endpoint.blockvision.org"`
 	FallbackSuiRpc string `mapstructure:"FALLBACK_SUI_RPC" default:"http://nimbus-srv.ddns.net:9000"`
 	DiscordWebhook string `mapstructure:"DISCORD_WEBHOOK" default:"-"`
+
+	// rpc pool, SUI_RPCS is a comma separated list of endpoints replacing SUI_RPC and FALLBACK_SUI_RPC
+	SuiRpcs             string        `mapstructure:"SUI_RPCS" default:"-"`
+	RpcFailureThreshold int           `mapstructure:"RPC_FAILURE_THRESHOLD" default:"5"`
+	RpcCooldown         time.Duration `mapstructure:"RPC_COOLDOWN" default:"30s"`
+	RpcMaxLag           int64         `mapstructure:"RPC_MAX_LAG" default:"50"`
+	RpcTipInterval      time.Duration `mapstructure:"RPC_TIP_INTERVAL" default:"10s"`
+	RpcMinRate          float64       `mapstructure:"RPC_MIN_RATE" default:"1"`
+	RpcMaxRate          float64       `mapstructure:"RPC_MAX_RATE" default:"50"`
+	RpcMinConcurrency   int           `mapstructure:"RPC_MIN_CONCURRENCY" default:"1"`
+	RpcMaxConcurrency   int           `mapstructure:"RPC_MAX_CONCURRENCY" default:"32"`
+	RpcRecordDir        string        `mapstructure:"RPC_RECORD_DIR" default:"-"`
+
+	// checkpoint certificates, see docs/checkpoint_verification.md. {seq} in CHECKPOINT_SOURCE_URL is replaced by the sequence number
+	CheckpointVerifySignatures  string `mapstructure:"CHECKPOINT_VERIFY_SIGNATURES" default:"no"`
+	CheckpointSourceUrl         string `mapstructure:"CHECKPOINT_SOURCE_URL" default:"https://checkpoints.mainnet.sui.io/{seq}.chk"`
+	CheckpointTrustedEpoch      int64  `mapstructure:"CHECKPOINT_TRUSTED_EPOCH" default:"0"`
+	CheckpointVerifyConcurrency int    `mapstructure:"CHECKPOINT_VERIFY_CONCURRENCY" default:"8"`
 }
 
 func (c *config) IsLocal() bool {
@@ -79,6 +139,18 @@ func (c *config) IsMigration() bool {
 	return strings.ToLower(c.Migration) == "yes"
 }
 
+func (c *config) IsOffline() bool {
+	return strings.ToLower(c.Offline) == "yes"
+}
+
+func (c *config) IsBackfillSkipExisting() bool {
+	return strings.ToLower(c.BackfillSkipExisting) == "yes"
+}
+
+func (c *config) IsCheckpointVerifySignatures() bool {
+	return strings.ToLower(c.CheckpointVerifySignatures) == "yes"
+}
+
 func (c *config) IsUseProxy() bool {
 	return c.HttpProxy != ""
 }
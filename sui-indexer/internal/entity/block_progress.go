@@ -0,0 +1,64 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// BlockProgress sums the blocks of the ranges of a block type by status
+type BlockProgress struct {
+	Type       int
+	From       int64
+	To         int64
+	NotReady   int64
+	Processing int64
+	Done       int64
+	Fail       int64
+	Dead       int64
+	// DoneRecently is the number of blocks completed within Window, it measures the throughput of all workers
+	DoneRecently int64
+	Window       time.Duration
+}
+
+func (p *BlockProgress) Total() int64 {
+	return p.NotReady + p.Processing + p.Done + p.Fail + p.Dead
+}
+
+// Remaining returns the number of blocks still to be processed, DEAD blocks are only retried manually
+func (p *BlockProgress) Remaining() int64 {
+	return p.NotReady + p.Processing + p.Fail
+}
+
+// Rate returns the number of blocks completed per second within Window
+func (p *BlockProgress) Rate() float64 {
+	if p.Window <= 0 {
+		return 0
+	}
+	return float64(p.DoneRecently) / p.Window.Seconds()
+}
+
+// Eta returns the time left to process the remaining blocks at the current rate, 0 when nothing is processed
+func (p *BlockProgress) Eta() time.Duration {
+	rate := p.Rate()
+	if rate == 0 {
+		return 0
+	}
+	return time.Duration(float64(p.Remaining()) / rate * float64(time.Second)).Round(time.Minute)
+}
+
+func (p *BlockProgress) String() string {
+	var (
+		percent float64
+		eta     = "unknown"
+	)
+	if total := p.Total(); total > 0 {
+		percent = float64(p.Done) * 100 / float64(total)
+	}
+	if p.Remaining() == 0 {
+		eta = "done"
+	} else if d := p.Eta(); d > 0 {
+		eta = d.String()
+	}
+	return fmt.Sprintf("blocks %v-%v: %v done (%.2f%%), %v failing, %v dead, %v processing, %v remaining, %.2f blocks/s over %v, ETA %v",
+		p.From, p.To, p.Done, percent, p.Fail, p.Dead, p.Processing, p.Remaining(), p.Rate(), p.Window, eta)
+}
@@ -2,6 +2,7 @@ package entity
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/getnimbus/ultrago/u_validator"
 )
@@ -16,14 +17,65 @@ const (
 	BlockStatus_PROCESSING
 	BlockStatus_DONE
 	BlockStatus_FAIL
+	BlockStatus_DEAD // exceeded max attempts, only requeued manually
 )
 
+// BlockStatus is a work unit covering the checkpoints from BlockNumber to ToBlockNumber (inclusive)
 type BlockStatus struct {
 	Base
-	Chain       string `json:"chain" validate:"required"`
-	BlockNumber int64  `json:"block_number" validate:"required"`
-	Status      int    `json:"status" validate:"required"`
-	Type        int    `json:"type" validate:"-"`
+	Chain         string `json:"chain" validate:"required"`
+	BlockNumber   int64  `json:"block_number" validate:"required"`
+	ToBlockNumber int64  `json:"to_block_number" validate:"required"`
+	Status        int    `json:"status" validate:"required"`
+	Type          int    `json:"type" validate:"-"`
+	// Digest of the last checkpoint and PreviousDigest of the first checkpoint are stored once the range is DONE,
+	// they are used to verify the chain continuity with the neighbour ranges
+	Digest         string `json:"digest" validate:"-"`
+	PreviousDigest string `json:"previous_digest" validate:"-"`
+	// EventCount is the number of sui_index rows emitted for the range
+	EventCount int    `json:"event_count" validate:"-"`
+	Attempts   int    `json:"attempts" validate:"-"`
+	LastError  string `json:"last_error" validate:"-"`
+	// SkipDedup is set on DONE ranges requeued because their data is missing downstream,
+	// their events are emitted again even though the dedup store remembers them
+	SkipDedup bool `json:"skip_dedup" validate:"-"`
+	// Owner holds the lease of a PROCESSING range until LeaseExpiresAt, it is extended by heartbeats
+	Owner          string     `json:"owner" validate:"-"`
+	HeartbeatAt    *time.Time `json:"heartbeat_at" validate:"-"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at" validate:"-"`
+}
+
+// BlockResult is the outcome of processing a block of a claimed range
+type BlockResult struct {
+	BlockNumber    int64
+	Digest         string
+	PreviousDigest string
+	EventCount     int
+	Err            error
+}
+
+// BlockRange is an inclusive range of block numbers
+type BlockRange struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+func (r *BlockRange) Size() int64 {
+	return r.To - r.From + 1
+}
+
+func (b *BlockStatus) Range() *BlockRange {
+	return &BlockRange{From: b.BlockNumber, To: b.ToBlockNumber}
+}
+
+func (b *BlockStatus) Size() int64 {
+	return b.Range().Size()
+}
+
+// ClaimEnd returns the last block of a claim of at most maxSize blocks of the range, claims stop at the multiples
+// of maxSize so that the ranges of the same size always cover the same blocks
+func (b *BlockStatus) ClaimEnd(maxSize int64) int64 {
+	return min(b.ToBlockNumber, b.BlockNumber-b.BlockNumber%maxSize+maxSize-1)
 }
 
 func (b *BlockStatus) Validate() error {
@@ -31,11 +83,16 @@ func (b *BlockStatus) Validate() error {
 		return err
 	}
 
+	if b.ToBlockNumber < b.BlockNumber {
+		return fmt.Errorf("invalid range %v-%v", b.BlockNumber, b.ToBlockNumber)
+	}
+
 	switch b.Status {
 	case BlockStatus_NOT_READY,
 		BlockStatus_PROCESSING,
 		BlockStatus_DONE,
-		BlockStatus_FAIL:
+		BlockStatus_FAIL,
+		BlockStatus_DEAD:
 	default:
 		return fmt.Errorf("invalid status %v", b.Status)
 	}
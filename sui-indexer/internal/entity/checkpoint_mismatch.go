@@ -0,0 +1,14 @@
+package entity
+
+// CheckpointMismatch records a broken link in the checkpoint chain,
+// it happens when the previous digest of a checkpoint is not equal to the stored digest of its predecessor.
+type CheckpointMismatch struct {
+	Base
+	Chain                   string `json:"chain"`
+	BlockNumber             int64  `json:"block_number"`
+	Digest                  string `json:"digest"`
+	PreviousDigest          string `json:"previous_digest"`
+	NeighbourBlockNumber    int64  `json:"neighbour_block_number"`
+	NeighbourDigest         string `json:"neighbour_digest"`
+	NeighbourPreviousDigest string `json:"neighbour_previous_digest"`
+}
@@ -0,0 +1,10 @@
+package entity
+
+// SinkRecord is a record emitted to a topic and stored in Postgres by the postgres sink
+type SinkRecord struct {
+	Topic        string `json:"topic"`
+	Checkpoint   int64  `json:"checkpoint"`
+	Index        int    `json:"index"`
+	PartitionKey string `json:"partition_key"`
+	Data         string `json:"data"`
+}
@@ -2,6 +2,8 @@ package entity
 
 import (
 	"strconv"
+
+	"feng-sui-core/internal/entity_dto/sui_model"
 )
 
 type SuiIndex struct {
@@ -14,10 +16,25 @@ type SuiIndex struct {
 	EventType        string `json:"event_type"`
 }
 
+// NewSuiIndex returns the index row of an event of the checkpoint checkpointSeq
+func NewSuiIndex(checkpoint *sui_model.Checkpoint, checkpointSeq int64, event *sui_model.Event) *SuiIndex {
+	return &SuiIndex{
+		DateKey:          checkpoint.DateKey,
+		CheckpointDigest: checkpoint.Digest,
+		CheckpointSeq:    checkpointSeq,
+		TxDigest:         event.Id.TxDigest.String(),
+		EventSeq:         event.Id.EventSeq.Int64(),
+		PackageId:        event.PackageId.String(),
+		EventType:        event.Type,
+	}
+}
+
 type SuiIndexKafka struct {
 	Schema        map[string]interface{} `json:"schema"`
 	Payload       map[string]interface{} `json:"payload"`
 	CheckpointSeq string                 `json:"-"`
+	// Index is the row of the message, for the sinks which do not write kafka connect messages
+	Index *SuiIndex `json:"-"`
 }
 
 func (i *SuiIndexKafka) PartitionKey() string {
@@ -76,5 +93,6 @@ func (i *SuiIndex) ToKafka() *SuiIndexKafka {
 			"event_type":        i.EventType,
 		},
 		CheckpointSeq: strconv.FormatInt(i.CheckpointSeq, 10),
+		Index:         i,
 	}
 }
@@ -1,7 +1,9 @@
 package sui_model
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/coming-chat/go-sui/v2/types"
 	"github.com/getnimbus/ultrago/u_validator"
@@ -16,24 +18,19 @@ import (
  * https://www.jvillella.com/ethereum-bloom-filter
  */
 type Checkpoint struct {
-	DateKey                    string `json:"dateKey" validate:"-"`
-	Epoch                      string `json:"epoch" validate:"required"`
-	SequenceNumber             string `json:"sequenceNumber" validate:"required"`
-	Digest                     string `json:"digest" validate:"required"`
-	TimestampMs                string `json:"timestampMs" validate:"required"`
-	PreviousDigest             string `json:"previousDigest" validate:"-"`
-	NetworkTotalTransactions   string `json:"networkTotalTransactions" validate:"-"`
-	EpochRollingGasCostSummary struct {
-		ComputationCost         string `json:"computationCost" validate:"-"`
-		StorageCost             string `json:"storageCost" validate:"-"`
-		StorageRebate           string `json:"storageRebate" validate:"-"`
-		NonRefundableStorageFee string `json:"nonRefundableStorageFee" validate:"-"`
-	} `json:"epochRollingGasCostSummary" validate:"-"`
-	Transactions          []string `json:"transactions" validate:"-"`
-	CheckpointCommitments []string `json:"checkpointCommitments" validate:"-"`
-	ValidatorSignature    string   `json:"validatorSignature" validate:"-"`
-	EventsBloom           string   `json:"eventsBloom" validate:"-"`   // 256 byte bloom filter, max is 683 events
-	PackagesBloom         string   `json:"packagesBloom" validate:"-"` // 256 byte bloom filter, max is 683 events
+	DateKey                    string         `json:"dateKey" validate:"-"`
+	Epoch                      string         `json:"epoch" validate:"required"`
+	SequenceNumber             string         `json:"sequenceNumber" validate:"required"`
+	Digest                     string         `json:"digest" validate:"required"`
+	TimestampMs                string         `json:"timestampMs" validate:"required"`
+	PreviousDigest             string         `json:"previousDigest" validate:"-"`
+	NetworkTotalTransactions   string         `json:"networkTotalTransactions" validate:"-"`
+	EpochRollingGasCostSummary GasCostSummary `json:"epochRollingGasCostSummary" validate:"-"`
+	Transactions               []string       `json:"transactions" validate:"-"`
+	CheckpointCommitments      []string       `json:"checkpointCommitments" validate:"-"`
+	ValidatorSignature         string         `json:"validatorSignature" validate:"-"`
+	EventsBloom                string         `json:"eventsBloom" validate:"-"`   // 256 byte bloom filter, max is 683 events
+	PackagesBloom              string         `json:"packagesBloom" validate:"-"` // 256 byte bloom filter, max is 683 events
 }
 
 func (c *Checkpoint) Validate() error {
@@ -56,6 +53,73 @@ func (c *Checkpoint) WithDateKey() *Checkpoint {
 	return c
 }
 
+// VerifyTxs checks that txs are exactly the transactions of the checkpoint, rpc nodes may leave out
+// or return null for some of the digests asked
+func (c *Checkpoint) VerifyTxs(txs []*Transaction) error {
+	var (
+		expected = lo.Uniq(c.Transactions)
+		found    = make(map[string]bool, len(txs))
+	)
+	for idx, tx := range txs {
+		if tx == nil {
+			return fmt.Errorf("checkpoint %v: tx %v of %v is null", c.SequenceNumber, idx, len(txs))
+		}
+		if tx.Checkpoint != c.SequenceNumber {
+			return fmt.Errorf("checkpoint %v: tx %v belongs to checkpoint %v", c.SequenceNumber, tx.Digest, tx.Checkpoint)
+		}
+		if found[tx.Digest] {
+			return fmt.Errorf("checkpoint %v: tx %v is duplicated", c.SequenceNumber, tx.Digest)
+		}
+		found[tx.Digest] = true
+	}
+
+	if missing := lo.Filter(expected, func(digest string, _ int) bool {
+		return !found[digest]
+	}); len(missing) > 0 {
+		return fmt.Errorf("checkpoint %v: %v of %v txs are missing: %v", c.SequenceNumber, len(missing), len(expected), strings.Join(lo.Subset(missing, 0, 10), ","))
+	}
+	if len(found) != len(expected) {
+		unexpected := lo.Without(lo.Keys(found), expected...)
+		return fmt.Errorf("checkpoint %v: %v txs are not in the checkpoint: %v", c.SequenceNumber, len(unexpected), strings.Join(lo.Subset(unexpected, 0, 10), ","))
+	}
+	return nil
+}
+
+// VerifyTotal checks that NetworkTotalTransactions is the total of the previous checkpoint plus the transactions of the checkpoint
+func (c *Checkpoint) VerifyTotal(previous *Checkpoint) error {
+	total, err := strconv.ParseInt(c.NetworkTotalTransactions, 10, 64)
+	if err != nil {
+		return fmt.Errorf("checkpoint %v: invalid network total transactions %v", c.SequenceNumber, c.NetworkTotalTransactions)
+	}
+	previousTotal, err := strconv.ParseInt(previous.NetworkTotalTransactions, 10, 64)
+	if err != nil {
+		return fmt.Errorf("checkpoint %v: invalid network total transactions %v", previous.SequenceNumber, previous.NetworkTotalTransactions)
+	}
+	if total != previousTotal+int64(len(c.Transactions)) {
+		return fmt.Errorf("checkpoint %v: network total transactions %v is not %v of checkpoint %v plus %v txs",
+			c.SequenceNumber, total, previousTotal, previous.SequenceNumber, len(c.Transactions))
+	}
+	return nil
+}
+
+// VerifyTotals checks the network total transactions of the consecutive checkpoints, checkpoints may be nil.
+// previous is the checkpoint before the first one, nil when unknown. It returns the errors keyed by the index of the checkpoints.
+func VerifyTotals(previous *Checkpoint, checkpoints []*Checkpoint) map[int]error {
+	var failures = make(map[int]error)
+	for i, checkpoint := range checkpoints {
+		if i > 0 {
+			previous = checkpoints[i-1]
+		}
+		if previous == nil || checkpoint == nil {
+			continue
+		}
+		if err := checkpoint.VerifyTotal(previous); err != nil {
+			failures[i] = err
+		}
+	}
+	return failures
+}
+
 func (c *Checkpoint) SetBloomFilter(txs []*Transaction) error {
 	if err := c.setEventsBloom(txs); err != nil {
 		return err
@@ -73,7 +137,7 @@ func (c *Checkpoint) setEventsBloom(txs []*Transaction) error {
 
 	f := bloom.CreateBloom(lo.FlatMap(txs, func(tx *Transaction, _ int) [][]byte {
 		return lo.Map(tx.Events, func(event types.SuiEvent, _ int) []byte {
-			return []byte(event.Type)
+			return EventTypeKey(event)
 		})
 	}))
 	eventsBloom, err := f.MarshalText()
@@ -92,7 +156,7 @@ func (c *Checkpoint) setPackagesBloom(txs []*Transaction) error {
 
 	f := bloom.CreateBloom(lo.FlatMap(txs, func(tx *Transaction, _ int) [][]byte {
 		return lo.Map(tx.Events, func(event types.SuiEvent, _ int) []byte {
-			return event.PackageId.Data()
+			return EventPackageKey(event)
 		})
 	}))
 	packagesBloom, err := f.MarshalText()
@@ -0,0 +1,47 @@
+package sui_model
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestCheckpointCompleteness(t *testing.T) {
+	convey.Convey("TestCheckpointCompleteness", t, func() {
+		checkpoint := &Checkpoint{
+			SequenceNumber:           "100",
+			NetworkTotalTransactions: "1003",
+			Transactions:             []string{"tx-1", "tx-2", "tx-3"},
+		}
+		tx := func(digest string) *Transaction {
+			return &Transaction{Checkpoint: "100", Digest: digest}
+		}
+
+		convey.Convey("Txs must be exactly the transactions of the checkpoint", func() {
+			convey.So(checkpoint.VerifyTxs([]*Transaction{tx("tx-3"), tx("tx-1"), tx("tx-2")}), convey.ShouldBeNil)
+
+			convey.So(checkpoint.VerifyTxs([]*Transaction{tx("tx-1"), tx("tx-2")}), convey.ShouldNotBeNil)
+			convey.So(checkpoint.VerifyTxs([]*Transaction{tx("tx-1"), nil, tx("tx-3")}), convey.ShouldNotBeNil)
+			convey.So(checkpoint.VerifyTxs([]*Transaction{tx("tx-1"), tx("tx-2"), tx("tx-2"), tx("tx-3")}), convey.ShouldNotBeNil)
+			convey.So(checkpoint.VerifyTxs([]*Transaction{tx("tx-1"), tx("tx-2"), tx("tx-3"), tx("tx-4")}), convey.ShouldNotBeNil)
+			convey.So(checkpoint.VerifyTxs([]*Transaction{tx("tx-1"), tx("tx-2"), {Checkpoint: "101", Digest: "tx-3"}}), convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Network totals add up with the txs", func() {
+			previous := &Checkpoint{SequenceNumber: "99", NetworkTotalTransactions: "1000"}
+			convey.So(checkpoint.VerifyTotal(previous), convey.ShouldBeNil)
+
+			previous.NetworkTotalTransactions = "999"
+			convey.So(checkpoint.VerifyTotal(previous), convey.ShouldNotBeNil)
+
+			failures := VerifyTotals(nil, []*Checkpoint{previous, checkpoint, nil, checkpoint})
+			convey.So(failures, convey.ShouldHaveLength, 1)
+			convey.So(failures[1], convey.ShouldNotBeNil)
+
+			// the first checkpoint is checked against the previous one
+			failures = VerifyTotals(previous, []*Checkpoint{checkpoint})
+			convey.So(failures, convey.ShouldHaveLength, 1)
+			convey.So(failures[0], convey.ShouldNotBeNil)
+		})
+	})
+}
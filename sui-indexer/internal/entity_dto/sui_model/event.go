@@ -2,6 +2,7 @@ package sui_model
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/coming-chat/go-sui/v2/types"
 	"github.com/golang-module/carbon/v2"
@@ -9,9 +10,9 @@ import (
 
 type Event struct {
 	types.SuiEvent
-	DateKey    string      `json:"dateKey"`
-	Checkpoint string      `json:"checkpoint"`
-	GasUsed    interface{} `json:"gasUsed"`
+	DateKey    string          `json:"dateKey"`
+	Checkpoint string          `json:"checkpoint"`
+	GasUsed    *GasCostSummary `json:"gasUsed"`
 }
 
 func (e *Event) PartitionKey() string {
@@ -31,7 +32,33 @@ func (e *Event) WithCheckpoint(checkpoint string) *Event {
 	return e
 }
 
-func (e *Event) WithGasUsed(gasUsed interface{}) *Event {
+func (e *Event) WithGasUsed(gasUsed *GasCostSummary) *Event {
 	e.GasUsed = gasUsed
 	return e
 }
+
+// ParseEvents converts the events of the tx into records carrying the checkpoint and gas used of the tx,
+// events without timestamp get the timestamp of the tx
+func (tx *Transaction) ParseEvents() ([]*Event, error) {
+	var (
+		gasUsed = tx.GasUsed()
+		res     = make([]*Event, 0, len(tx.Events))
+	)
+	for _, event := range tx.Events {
+		if event.TimestampMs == nil || event.TimestampMs.Int64() == 0 {
+			parsedTs, err := strconv.ParseUint(tx.TimestampMs, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp of tx %v: %v", tx.Digest, err)
+			}
+			ts := types.NewSafeSuiBigInt[uint64](parsedTs)
+			event.TimestampMs = &ts
+		}
+		res = append(res, (&Event{
+			SuiEvent: event,
+		}).
+			WithDateKey().
+			WithCheckpoint(tx.Checkpoint).
+			WithGasUsed(gasUsed))
+	}
+	return res, nil
+}
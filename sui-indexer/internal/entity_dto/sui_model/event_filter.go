@@ -0,0 +1,297 @@
+package sui_model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/coming-chat/go-sui/v2/sui_types"
+	"github.com/coming-chat/go-sui/v2/types"
+)
+
+// EventTypeKey is the value of an event added to Checkpoint.EventsBloom and matched by EventFilter.EventTypes
+func EventTypeKey(event types.SuiEvent) []byte {
+	return []byte(event.Type)
+}
+
+// EventPackageKey is the value of an event added to Checkpoint.PackagesBloom and matched by EventFilter.PackageIds
+func EventPackageKey(event types.SuiEvent) []byte {
+	return event.PackageId.Data()
+}
+
+// EventFilterConfig decides which events are emitted to the events topic and which topics they are routed to
+type EventFilterConfig struct {
+	// Filters keeps only the events matching one of them in the events topic, all events are kept when it is empty
+	Filters []*EventFilter `json:"filters"`
+	// Routes emits the events matching a route to its topic as well
+	Routes []*EventRoute `json:"routes"`
+}
+
+type EventRoute struct {
+	EventFilter
+	Topic string `json:"topic"`
+}
+
+// EventFilter matches the events satisfying all of its non-empty conditions.
+// Event types are patterns like 0x2::coin::CoinEvent<0x2::sui::SUI>, every part can be *
+// and a pattern without type arguments matches any type arguments.
+type EventFilter struct {
+	PackageIds []string `json:"package_ids"`
+	Modules    []string `json:"modules"`
+	EventTypes []string `json:"event_types"`
+
+	packageKeys [][]byte
+	modules     []*moveType
+	eventTypes  []*moveType
+}
+
+// ReadEventFilterConfig parses the event filter file path, there is no filter when path is empty
+func ReadEventFilterConfig(path string) (*EventFilterConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event filter file: %v", err)
+	}
+	c, err := ParseEventFilterConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse event filter file: %v", err)
+	}
+	return c, nil
+}
+
+func ParseEventFilterConfig(data []byte) (*EventFilterConfig, error) {
+	var c EventFilterConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	for _, filter := range c.Filters {
+		if err := filter.compile(); err != nil {
+			return nil, err
+		}
+	}
+	for _, route := range c.Routes {
+		if route.Topic == "" {
+			return nil, fmt.Errorf("missing topic of event route")
+		}
+		if err := route.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+// Accept reports whether event is emitted to the events topic
+func (c *EventFilterConfig) Accept(event types.SuiEvent) bool {
+	if c == nil || len(c.Filters) == 0 {
+		return true
+	}
+	for _, filter := range c.Filters {
+		if filter.Match(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Topics returns the topics event is routed to
+func (c *EventFilterConfig) Topics(event types.SuiEvent) []string {
+	if c == nil {
+		return nil
+	}
+	var topics []string
+	for _, route := range c.Routes {
+		if route.Match(event) {
+			topics = append(topics, route.Topic)
+		}
+	}
+	return topics
+}
+
+func (f *EventFilter) compile() error {
+	f.packageKeys = make([][]byte, 0, len(f.PackageIds))
+	for _, packageId := range f.PackageIds {
+		id, err := sui_types.NewObjectIdFromHex(packageId)
+		if err != nil {
+			return fmt.Errorf("invalid package id %v: %v", packageId, err)
+		}
+		f.packageKeys = append(f.packageKeys, id.Data())
+	}
+
+	f.modules = make([]*moveType, 0, len(f.Modules))
+	for _, module := range f.Modules {
+		// a module is either name or address::name
+		if !strings.Contains(module, "::") {
+			module = "*::" + module
+		}
+		f.modules = append(f.modules, &moveType{head: module + "::*"})
+	}
+
+	f.eventTypes = make([]*moveType, 0, len(f.EventTypes))
+	for _, eventType := range f.EventTypes {
+		pattern, err := parseMoveType(eventType)
+		if err != nil {
+			return fmt.Errorf("invalid event type %v: %v", eventType, err)
+		}
+		f.eventTypes = append(f.eventTypes, pattern)
+	}
+	return nil
+}
+
+func (f *EventFilter) Match(event types.SuiEvent) bool {
+	if len(f.packageKeys) > 0 {
+		packageKey := EventPackageKey(event)
+		matched := false
+		for _, key := range f.packageKeys {
+			if bytes.Equal(key, packageKey) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(f.modules) == 0 && len(f.eventTypes) == 0 {
+		return true
+	}
+	eventType, err := parseMoveType(string(EventTypeKey(event)))
+	if err != nil {
+		return false
+	}
+	if len(f.modules) > 0 && !matchAny(f.modules, eventType) {
+		return false
+	}
+	if len(f.eventTypes) > 0 && !matchAny(f.eventTypes, eventType) {
+		return false
+	}
+	return true
+}
+
+func matchAny(patterns []*moveType, t *moveType) bool {
+	for _, pattern := range patterns {
+		if pattern.match(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// moveType is a parsed move type like 0x2::coin::Coin<0x2::sui::SUI> or vector<u8>
+type moveType struct {
+	head    string
+	args    []*moveType
+	hasArgs bool
+}
+
+func parseMoveType(s string) (*moveType, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("empty type")
+	}
+
+	open := strings.Index(s, "<")
+	if open < 0 {
+		return &moveType{head: s}, nil
+	}
+	if !strings.HasSuffix(s, ">") {
+		return nil, fmt.Errorf("unclosed type arguments")
+	}
+
+	t := &moveType{head: s[:open], hasArgs: true}
+	var (
+		inner = s[open+1 : len(s)-1]
+		depth int
+		start int
+	)
+	for i, c := range inner {
+		switch c {
+		case '<':
+			depth++
+		case '>':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced type arguments")
+			}
+		case ',':
+			if depth == 0 {
+				arg, err := parseMoveType(inner[start:i])
+				if err != nil {
+					return nil, err
+				}
+				t.args = append(t.args, arg)
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced type arguments")
+	}
+	arg, err := parseMoveType(inner[start:])
+	if err != nil {
+		return nil, err
+	}
+	t.args = append(t.args, arg)
+	return t, nil
+}
+
+// match reports whether t matches the pattern p
+func (p *moveType) match(t *moveType) bool {
+	if p.head == "*" && !p.hasArgs {
+		return true
+	}
+	if !matchHead(p.head, t.head) {
+		return false
+	}
+	if !p.hasArgs {
+		return true
+	}
+	if len(p.args) != len(t.args) {
+		return false
+	}
+	for i, arg := range p.args {
+		if !arg.match(t.args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchHead matches address::module::name or a primitive type, addresses are compared normalized
+func matchHead(pattern string, head string) bool {
+	var (
+		patternParts = strings.Split(pattern, "::")
+		headParts    = strings.Split(head, "::")
+	)
+	if len(patternParts) != len(headParts) {
+		return false
+	}
+	for i, part := range patternParts {
+		if part == "*" {
+			continue
+		}
+		if i == 0 && len(patternParts) == 3 {
+			if !sameAddress(part, headParts[i]) {
+				return false
+			}
+			continue
+		}
+		if part != headParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameAddress(a string, b string) bool {
+	addrA, errA := sui_types.NewAddressFromHex(a)
+	addrB, errB := sui_types.NewAddressFromHex(b)
+	if errA != nil || errB != nil {
+		return strings.EqualFold(a, b)
+	}
+	return bytes.Equal(addrA.Data(), addrB.Data())
+}
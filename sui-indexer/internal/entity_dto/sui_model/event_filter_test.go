@@ -0,0 +1,88 @@
+package sui_model
+
+import (
+	"testing"
+
+	"github.com/coming-chat/go-sui/v2/sui_types"
+	"github.com/coming-chat/go-sui/v2/types"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestEventFilter(t *testing.T) {
+	convey.Convey("TestEventFilter", t, func() {
+		newEvent := func(packageId string, eventType string) types.SuiEvent {
+			id, err := sui_types.NewObjectIdFromHex(packageId)
+			convey.So(err, convey.ShouldBeNil)
+			return types.SuiEvent{PackageId: *id, Type: eventType}
+		}
+		var (
+			coinEvent = newEvent("0x2", "0x0000000000000000000000000000000000000000000000000000000000000002::coin::CoinEvent<0x2::sui::SUI>")
+			swapEvent = newEvent("0x1eab", "0x1eab::pool::SwapEvent<0x2::sui::SUI, 0xdba3::usdc::USDC>")
+		)
+
+		convey.Convey("Empty config accepts all events", func() {
+			c, err := ParseEventFilterConfig([]byte(`{}`))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(c.Accept(coinEvent), convey.ShouldBeTrue)
+			convey.So(c.Topics(coinEvent), convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("Package ids are normalized", func() {
+			c, err := ParseEventFilterConfig([]byte(`{"filters": [{"package_ids": ["0x00002"]}]}`))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(c.Accept(coinEvent), convey.ShouldBeTrue)
+			convey.So(c.Accept(swapEvent), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Modules match with or without address", func() {
+			c, err := ParseEventFilterConfig([]byte(`{"filters": [{"modules": ["pool"]}, {"modules": ["0x2::coin"]}]}`))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(c.Accept(coinEvent), convey.ShouldBeTrue)
+			convey.So(c.Accept(swapEvent), convey.ShouldBeTrue)
+			convey.So(c.Accept(newEvent("0x2", "0x2::pay::PayEvent")), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Event types match generic type arguments", func() {
+			for pattern, expected := range map[string]bool{
+				"0x1eab::pool::SwapEvent":                                       true,
+				"0x1eab::pool::SwapEvent<*, *>":                                 true,
+				"0x1eab::pool::SwapEvent<0x2::sui::SUI, *>":                     true,
+				"0x1eab::pool::SwapEvent<*, 0x2::sui::SUI>":                     false,
+				"0x1eab::pool::SwapEvent<*>":                                    false,
+				"*::pool::*<0x02::sui::SUI,0xdba3::usdc::USDC>":                 true,
+				"0x1eab::pool::SwapEvent<0x2::sui::SUI, 0xdba3::usdc::USDC, *>": false,
+			} {
+				c, err := ParseEventFilterConfig([]byte(`{"filters": [{"event_types": ["` + pattern + `"]}]}`))
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(c.Accept(swapEvent), convey.ShouldEqual, expected)
+			}
+		})
+
+		convey.Convey("Conditions of a filter are all required", func() {
+			c, err := ParseEventFilterConfig([]byte(`{"filters": [{"package_ids": ["0x2"], "modules": ["pool"]}]}`))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(c.Accept(coinEvent), convey.ShouldBeFalse)
+			convey.So(c.Accept(swapEvent), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Routes return the topics of matched events", func() {
+			c, err := ParseEventFilterConfig([]byte(`{"routes": [
+				{"topic": "sui-events-coin", "event_types": ["0x2::coin::CoinEvent<*>"]},
+				{"topic": "sui-events-sui", "event_types": ["*::*::*<0x2::sui::SUI>", "*::*::*<0x2::sui::SUI, *>"]}
+			]}`))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(c.Accept(swapEvent), convey.ShouldBeTrue)
+			convey.So(c.Topics(coinEvent), convey.ShouldResemble, []string{"sui-events-coin", "sui-events-sui"})
+			convey.So(c.Topics(swapEvent), convey.ShouldResemble, []string{"sui-events-sui"})
+		})
+
+		convey.Convey("Invalid config is rejected", func() {
+			_, err := ParseEventFilterConfig([]byte(`{"filters": [{"event_types": ["0x2::coin::Coin<0x2::sui::SUI"]}]}`))
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = ParseEventFilterConfig([]byte(`{"filters": [{"package_ids": ["0xzz"]}]}`))
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = ParseEventFilterConfig([]byte(`{"routes": [{"modules": ["pool"]}]}`))
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
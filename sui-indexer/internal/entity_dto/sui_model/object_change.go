@@ -0,0 +1,185 @@
+package sui_model
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ObjectChange is an object created, mutated, transferred, wrapped, deleted or published by a tx
+type ObjectChange struct {
+	DateKey         string `json:"dateKey"`
+	Checkpoint      string `json:"checkpoint"`
+	TxDigest        string `json:"txDigest"`
+	TimestampMs     string `json:"timestampMs"`
+	Index           int    `json:"index"`
+	Type            string `json:"type"`
+	Sender          string `json:"sender"`
+	Owner           string `json:"owner"`
+	OwnerType       string `json:"ownerType"`
+	ObjectId        string `json:"objectId"`
+	ObjectType      string `json:"objectType"`
+	CoinType        string `json:"coinType"`
+	Version         string `json:"version"`
+	PreviousVersion string `json:"previousVersion"`
+	Digest          string `json:"digest"`
+}
+
+func (c *ObjectChange) PartitionKey() string {
+	if c.Owner != "" {
+		return c.Owner
+	}
+	return c.ObjectId
+}
+
+// BalanceChange is the change of the balance of a coin type of an owner by a tx, Amount is negative when spent
+type BalanceChange struct {
+	DateKey     string `json:"dateKey"`
+	Checkpoint  string `json:"checkpoint"`
+	TxDigest    string `json:"txDigest"`
+	TimestampMs string `json:"timestampMs"`
+	Index       int    `json:"index"`
+	Owner       string `json:"owner"`
+	OwnerType   string `json:"ownerType"`
+	CoinType    string `json:"coinType"`
+	Amount      string `json:"amount"`
+}
+
+func (c *BalanceChange) PartitionKey() string {
+	return c.Owner
+}
+
+// SuiObjectChange is an item of the objectChanges of a tx.
+// It is emitted with the json received from the rpc, so the fields which are not modeled are kept.
+type SuiObjectChange struct {
+	Type            string      `json:"type"`
+	Sender          string      `json:"sender"`
+	Owner           *Owner      `json:"owner"`
+	Recipient       *Owner      `json:"recipient"`
+	ObjectId        string      `json:"objectId"`
+	PackageId       string      `json:"packageId"`
+	ObjectType      string      `json:"objectType"`
+	Version         json.Number `json:"version"`
+	PreviousVersion json.Number `json:"previousVersion"`
+	Digest          string      `json:"digest"`
+
+	raw json.RawMessage
+}
+
+type suiObjectChangeJson SuiObjectChange
+
+func (c *SuiObjectChange) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*suiObjectChangeJson)(c)); err != nil {
+		return err
+	}
+	c.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (c SuiObjectChange) MarshalJSON() ([]byte, error) {
+	if c.raw != nil {
+		return c.raw, nil
+	}
+	return json.Marshal(suiObjectChangeJson(c))
+}
+
+// SuiBalanceChange is an item of the balanceChanges of a tx.
+// It is emitted with the json received from the rpc, so the fields which are not modeled are kept.
+type SuiBalanceChange struct {
+	Owner    *Owner `json:"owner"`
+	CoinType string `json:"coinType"`
+	Amount   string `json:"amount"`
+
+	raw json.RawMessage
+}
+
+type suiBalanceChangeJson SuiBalanceChange
+
+func (c *SuiBalanceChange) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*suiBalanceChangeJson)(c)); err != nil {
+		return err
+	}
+	c.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (c SuiBalanceChange) MarshalJSON() ([]byte, error) {
+	if c.raw != nil {
+		return c.raw, nil
+	}
+	return json.Marshal(suiBalanceChangeJson(c))
+}
+
+// ParseObjectChanges converts the objectChanges of the tx into records
+func (tx *Transaction) ParseObjectChanges() []*ObjectChange {
+	var res = make([]*ObjectChange, 0, len(tx.ObjectChanges))
+	for i, change := range tx.ObjectChanges {
+		if change == nil {
+			continue
+		}
+		owner := change.Owner
+		if change.Type == "transferred" {
+			owner = change.Recipient
+		}
+		if owner == nil {
+			owner = &Owner{}
+		}
+
+		objectId := change.ObjectId
+		if change.Type == "published" {
+			objectId = change.PackageId
+		}
+		res = append(res, &ObjectChange{
+			DateKey:         tx.DateKey,
+			Checkpoint:      tx.Checkpoint,
+			TxDigest:        tx.Digest,
+			TimestampMs:     tx.TimestampMs,
+			Index:           i,
+			Type:            change.Type,
+			Sender:          change.Sender,
+			Owner:           owner.Address,
+			OwnerType:       owner.Type,
+			ObjectId:        objectId,
+			ObjectType:      change.ObjectType,
+			CoinType:        coinType(change.ObjectType),
+			Version:         change.Version.String(),
+			PreviousVersion: change.PreviousVersion.String(),
+			Digest:          change.Digest,
+		})
+	}
+	return res
+}
+
+// ParseBalanceChanges converts the balanceChanges of the tx into records
+func (tx *Transaction) ParseBalanceChanges() []*BalanceChange {
+	var res = make([]*BalanceChange, 0, len(tx.BalanceChanges))
+	for i, change := range tx.BalanceChanges {
+		if change == nil {
+			continue
+		}
+		owner := change.Owner
+		if owner == nil {
+			owner = &Owner{}
+		}
+		res = append(res, &BalanceChange{
+			DateKey:     tx.DateKey,
+			Checkpoint:  tx.Checkpoint,
+			TxDigest:    tx.Digest,
+			TimestampMs: tx.TimestampMs,
+			Index:       i,
+			Owner:       owner.Address,
+			OwnerType:   owner.Type,
+			CoinType:    change.CoinType,
+			Amount:      change.Amount,
+		})
+	}
+	return res
+}
+
+// coinType returns T of 0x2::coin::Coin<T>, or empty for the other object types
+func coinType(objectType string) string {
+	t, err := parseMoveType(objectType)
+	if err != nil || len(t.args) != 1 || !matchHead("0x2::coin::Coin", t.head) {
+		return ""
+	}
+	return strings.TrimSpace(objectType[strings.Index(objectType, "<")+1 : len(objectType)-1])
+}
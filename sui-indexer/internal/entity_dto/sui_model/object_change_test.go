@@ -0,0 +1,99 @@
+package sui_model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseChanges(t *testing.T) {
+	convey.Convey("TestParseChanges", t, func() {
+		var tx Transaction
+		err := json.Unmarshal([]byte(`{
+			"checkpoint": "100",
+			"digest": "tx-digest",
+			"timestampMs": "1700000000000",
+			"objectChanges": [
+				{"type": "mutated", "sender": "0xa", "owner": {"AddressOwner": "0xa"}, "objectType": "0x2::coin::Coin<0x2::sui::SUI>", "objectId": "0x1", "version": "11", "previousVersion": "10", "digest": "obj-digest"},
+				{"type": "transferred", "sender": "0xa", "recipient": {"AddressOwner": "0xb"}, "objectType": "0x2::coin::Coin<0xdba3::usdc::USDC>", "objectId": "0x2", "version": "11", "digest": "obj-digest-2"},
+				{"type": "mutated", "sender": "0xa", "owner": {"Shared": {"initial_shared_version": 5}}, "objectType": "0x1eab::pool::Pool<0x2::sui::SUI, 0xdba3::usdc::USDC>", "objectId": "0x3", "version": "11", "previousVersion": "9", "digest": "obj-digest-3"},
+				{"type": "published", "packageId": "0x4", "version": "1", "digest": "pkg-digest", "modules": ["pool"]},
+				{"type": "deleted", "sender": "0xa", "objectType": "0x2::coin::Coin<0x2::sui::SUI>", "objectId": "0x5", "version": "11"}
+			],
+			"balanceChanges": [
+				{"owner": {"AddressOwner": "0xa"}, "coinType": "0x2::sui::SUI", "amount": "-1000"},
+				{"owner": {"AddressOwner": "0xb"}, "coinType": "0xdba3::usdc::USDC", "amount": "25"}
+			]
+		}`), &tx)
+		convey.So(err, convey.ShouldBeNil)
+		tx.WithDateKey()
+
+		convey.Convey("Object changes", func() {
+			changes := tx.ParseObjectChanges()
+			convey.So(changes, convey.ShouldHaveLength, 5)
+
+			convey.So(changes[0].Checkpoint, convey.ShouldEqual, "100")
+			convey.So(changes[0].TxDigest, convey.ShouldEqual, "tx-digest")
+			convey.So(changes[0].DateKey, convey.ShouldEqual, "2023-11-14")
+			convey.So(changes[0].OwnerType, convey.ShouldEqual, OwnerType_ADDRESS)
+			convey.So(changes[0].Owner, convey.ShouldEqual, "0xa")
+			convey.So(changes[0].CoinType, convey.ShouldEqual, "0x2::sui::SUI")
+			convey.So(changes[0].Version, convey.ShouldEqual, "11")
+			convey.So(changes[0].PreviousVersion, convey.ShouldEqual, "10")
+
+			convey.So(changes[1].Owner, convey.ShouldEqual, "0xb")
+			convey.So(changes[1].CoinType, convey.ShouldEqual, "0xdba3::usdc::USDC")
+			convey.So(changes[1].PartitionKey(), convey.ShouldEqual, "0xb")
+
+			convey.So(changes[2].OwnerType, convey.ShouldEqual, OwnerType_SHARED)
+			convey.So(changes[2].Owner, convey.ShouldBeEmpty)
+			convey.So(changes[2].CoinType, convey.ShouldBeEmpty)
+			convey.So(changes[2].PartitionKey(), convey.ShouldEqual, "0x3")
+
+			convey.So(changes[3].ObjectId, convey.ShouldEqual, "0x4")
+			convey.So(changes[4].Index, convey.ShouldEqual, 4)
+			convey.So(changes[4].OwnerType, convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("Balance changes", func() {
+			changes := tx.ParseBalanceChanges()
+			convey.So(changes, convey.ShouldHaveLength, 2)
+			convey.So(changes[0].Owner, convey.ShouldEqual, "0xa")
+			convey.So(changes[0].CoinType, convey.ShouldEqual, "0x2::sui::SUI")
+			convey.So(changes[0].Amount, convey.ShouldEqual, "-1000")
+			convey.So(changes[1].Index, convey.ShouldEqual, 1)
+			convey.So(changes[1].Amount, convey.ShouldEqual, "25")
+		})
+
+		convey.Convey("Changes are emitted as received", func() {
+			data, err := json.Marshal(tx.ObjectChanges[3])
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(string(data), convey.ShouldEqual, `{"type":"published","packageId":"0x4","version":"1","digest":"pkg-digest","modules":["pool"]}`)
+		})
+
+		convey.Convey("Immutable owner", func() {
+			var owner Owner
+			convey.So(json.Unmarshal([]byte(`"Immutable"`), &owner), convey.ShouldBeNil)
+			convey.So(owner.Type, convey.ShouldEqual, OwnerType_IMMUTABLE)
+			convey.So(owner.Address, convey.ShouldBeEmpty)
+
+			data, err := json.Marshal(owner)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(string(data), convey.ShouldEqual, `"Immutable"`)
+		})
+
+		convey.Convey("Unknown owner", func() {
+			raw := `{"ConsensusAddressOwner":{"owner":"0xc","start_version":7}}`
+			var change SuiObjectChange
+			err := json.Unmarshal([]byte(`{"type": "mutated", "sender": "0xa", "owner": `+raw+`, "objectId": "0x6", "version": "8", "digest": "obj-digest-6"}`), &change)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(change.Owner.Type, convey.ShouldEqual, "ConsensusAddressOwner")
+			convey.So(change.Owner.Address, convey.ShouldBeEmpty)
+
+			data, err := json.Marshal(change.Owner)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(string(data), convey.ShouldEqual, raw)
+		})
+	})
+}
@@ -0,0 +1,80 @@
+package sui_model
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	OwnerType_ADDRESS   = "AddressOwner"
+	OwnerType_OBJECT    = "ObjectOwner"
+	OwnerType_SHARED    = "Shared"
+	OwnerType_IMMUTABLE = "Immutable"
+)
+
+// Owner is the owner of an object like {"AddressOwner": "0x1"}, {"Shared": {...}} or "Immutable",
+// Address is the owner address or object id and is empty for shared and immutable objects.
+// The owners of the other types only have a Type, they are emitted with the json received from the rpc.
+type Owner struct {
+	Type                 string
+	Address              string
+	InitialSharedVersion json.Number
+
+	raw json.RawMessage
+}
+
+func (o *Owner) UnmarshalJSON(data []byte) error {
+	var immutable string
+	if err := json.Unmarshal(data, &immutable); err == nil {
+		*o = Owner{Type: immutable}
+		return nil
+	}
+
+	var owner map[string]json.RawMessage
+	if err := json.Unmarshal(data, &owner); err != nil {
+		return err
+	}
+	for _, ownerType := range []string{OwnerType_ADDRESS, OwnerType_OBJECT} {
+		if value, ok := owner[ownerType]; ok {
+			var address string
+			if err := json.Unmarshal(value, &address); err != nil {
+				return err
+			}
+			*o = Owner{Type: ownerType, Address: address}
+			return nil
+		}
+	}
+	if value, ok := owner[OwnerType_SHARED]; ok {
+		var shared struct {
+			InitialSharedVersion json.Number `json:"initial_shared_version"`
+		}
+		if err := json.Unmarshal(value, &shared); err != nil {
+			return err
+		}
+		*o = Owner{Type: OwnerType_SHARED, InitialSharedVersion: shared.InitialSharedVersion}
+		return nil
+	}
+	if len(owner) != 1 {
+		return fmt.Errorf("invalid owner %s", data)
+	}
+	for ownerType := range owner {
+		*o = Owner{Type: ownerType, raw: append(json.RawMessage(nil), data...)}
+	}
+	return nil
+}
+
+func (o Owner) MarshalJSON() ([]byte, error) {
+	if o.raw != nil {
+		return o.raw, nil
+	}
+	switch o.Type {
+	case OwnerType_ADDRESS, OwnerType_OBJECT:
+		return json.Marshal(map[string]string{o.Type: o.Address})
+	case OwnerType_SHARED:
+		return json.Marshal(map[string]interface{}{
+			o.Type: map[string]json.Number{"initial_shared_version": o.InitialSharedVersion},
+		})
+	default:
+		return json.Marshal(o.Type)
+	}
+}
@@ -9,15 +9,15 @@ import (
 )
 
 type Transaction struct {
-	DateKey        string                 `json:"dateKey" validate:"-"`
-	Checkpoint     string                 `json:"checkpoint" validate:"required"`
-	Digest         string                 `json:"digest" validate:"required"`
-	TimestampMs    string                 `json:"timestampMs" validate:"required"`
-	Transaction    map[string]interface{} `json:"transaction" validate:"-"`
-	Effects        map[string]interface{} `json:"effects" validate:"-"`
-	Events         []types.SuiEvent       `json:"events" validate:"-"`
-	ObjectChanges  []interface{}          `json:"objectChanges" validate:"-"`
-	BalanceChanges []interface{}          `json:"balanceChanges" validate:"-"`
+	DateKey        string              `json:"dateKey" validate:"-"`
+	Checkpoint     string              `json:"checkpoint" validate:"required"`
+	Digest         string              `json:"digest" validate:"required"`
+	TimestampMs    string              `json:"timestampMs" validate:"required"`
+	Transaction    *TransactionBlock   `json:"transaction" validate:"-"`
+	Effects        *TransactionEffects `json:"effects" validate:"-"`
+	Events         []types.SuiEvent    `json:"events" validate:"-"`
+	ObjectChanges  []*SuiObjectChange  `json:"objectChanges" validate:"-"`
+	BalanceChanges []*SuiBalanceChange `json:"balanceChanges" validate:"-"`
 }
 
 func (tx *Transaction) Validate() error {
@@ -28,6 +28,14 @@ func (tx *Transaction) PartitionKey() string {
 	return tx.Checkpoint
 }
 
+// GasUsed returns the gas cost summary of the tx, or nil when the effects are missing
+func (tx *Transaction) GasUsed() *GasCostSummary {
+	if tx.Effects == nil {
+		return nil
+	}
+	return tx.Effects.GasUsed
+}
+
 func (tx *Transaction) WithDateKey() *Transaction {
 	if tx.DateKey != "" {
 		return tx
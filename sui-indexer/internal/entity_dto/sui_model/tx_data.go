@@ -0,0 +1,235 @@
+package sui_model
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	TransactionKind_PROGRAMMABLE = "ProgrammableTransaction"
+
+	CommandType_MOVE_CALL        = "MoveCall"
+	CommandType_TRANSFER_OBJECTS = "TransferObjects"
+	CommandType_SPLIT_COINS      = "SplitCoins"
+	CommandType_MERGE_COINS      = "MergeCoins"
+	CommandType_PUBLISH          = "Publish"
+	CommandType_UPGRADE          = "Upgrade"
+	CommandType_MAKE_MOVE_VEC    = "MakeMoveVec"
+
+	ArgumentKind_GAS_COIN      = "GasCoin"
+	ArgumentKind_INPUT         = "Input"
+	ArgumentKind_RESULT        = "Result"
+	ArgumentKind_NESTED_RESULT = "NestedResult"
+)
+
+// TransactionBlock is the signed transaction of a tx.
+// It is emitted with the json received from the rpc, so the fields which are not modeled are kept.
+type TransactionBlock struct {
+	Data         TransactionData `json:"data"`
+	TxSignatures []string        `json:"txSignatures"`
+
+	raw json.RawMessage
+}
+
+type transactionBlockJson TransactionBlock
+
+func (b *TransactionBlock) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*transactionBlockJson)(b)); err != nil {
+		return err
+	}
+	b.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (b TransactionBlock) MarshalJSON() ([]byte, error) {
+	if b.raw != nil {
+		return b.raw, nil
+	}
+	return json.Marshal(transactionBlockJson(b))
+}
+
+type TransactionData struct {
+	MessageVersion string          `json:"messageVersion"`
+	Transaction    TransactionKind `json:"transaction"`
+	Sender         string          `json:"sender"`
+	GasData        GasData         `json:"gasData"`
+}
+
+type GasData struct {
+	Payment []*ObjectRef `json:"payment"`
+	Owner   string       `json:"owner"`
+	Price   json.Number  `json:"price"`
+	Budget  json.Number  `json:"budget"`
+}
+
+type ObjectRef struct {
+	ObjectId string      `json:"objectId"`
+	Version  json.Number `json:"version"`
+	Digest   string      `json:"digest"`
+}
+
+// TransactionKind is the kind of a tx, only programmable txs have inputs and commands
+type TransactionKind struct {
+	Kind         string              `json:"kind"`
+	Inputs       []*TransactionInput `json:"inputs,omitempty"`
+	Transactions []*Command          `json:"transactions,omitempty"`
+}
+
+func (k *TransactionKind) IsProgrammable() bool {
+	return k.Kind == TransactionKind_PROGRAMMABLE
+}
+
+// TransactionInput is either a pure value or an object used by the commands
+type TransactionInput struct {
+	Type                 string      `json:"type"`
+	ValueType            string      `json:"valueType,omitempty"`
+	Value                interface{} `json:"value,omitempty"`
+	ObjectType           string      `json:"objectType,omitempty"`
+	ObjectId             string      `json:"objectId,omitempty"`
+	Version              json.Number `json:"version,omitempty"`
+	Digest               string      `json:"digest,omitempty"`
+	InitialSharedVersion json.Number `json:"initialSharedVersion,omitempty"`
+	Mutable              *bool       `json:"mutable,omitempty"`
+}
+
+// Command is a command of a programmable tx like {"MoveCall": {...}} or {"SplitCoins": [...]},
+// the arguments of Publish, Upgrade and MakeMoveVec are not modeled.
+type Command struct {
+	Type            string
+	MoveCall        *MoveCall
+	TransferObjects *TransferObjects
+	SplitCoins      *SplitCoins
+	MergeCoins      *MergeCoins
+
+	raw json.RawMessage
+}
+
+func (c *Command) UnmarshalJSON(data []byte) error {
+	var command map[string]json.RawMessage
+	if err := json.Unmarshal(data, &command); err != nil {
+		return err
+	}
+	if len(command) != 1 {
+		return fmt.Errorf("invalid command %s", data)
+	}
+
+	*c = Command{raw: append(json.RawMessage(nil), data...)}
+	for commandType, value := range command {
+		c.Type = commandType
+		switch commandType {
+		case CommandType_MOVE_CALL:
+			c.MoveCall = new(MoveCall)
+			return json.Unmarshal(value, c.MoveCall)
+		case CommandType_TRANSFER_OBJECTS:
+			c.TransferObjects = new(TransferObjects)
+			return unmarshalTuple(value, &c.TransferObjects.Objects, &c.TransferObjects.Address)
+		case CommandType_SPLIT_COINS:
+			c.SplitCoins = new(SplitCoins)
+			return unmarshalTuple(value, &c.SplitCoins.Coin, &c.SplitCoins.Amounts)
+		case CommandType_MERGE_COINS:
+			c.MergeCoins = new(MergeCoins)
+			return unmarshalTuple(value, &c.MergeCoins.Destination, &c.MergeCoins.Sources)
+		}
+	}
+	return nil
+}
+
+func (c Command) MarshalJSON() ([]byte, error) {
+	if c.raw != nil {
+		return c.raw, nil
+	}
+	var value interface{}
+	switch c.Type {
+	case CommandType_MOVE_CALL:
+		value = c.MoveCall
+	case CommandType_TRANSFER_OBJECTS:
+		value = []interface{}{c.TransferObjects.Objects, c.TransferObjects.Address}
+	case CommandType_SPLIT_COINS:
+		value = []interface{}{c.SplitCoins.Coin, c.SplitCoins.Amounts}
+	case CommandType_MERGE_COINS:
+		value = []interface{}{c.MergeCoins.Destination, c.MergeCoins.Sources}
+	}
+	return json.Marshal(map[string]interface{}{c.Type: value})
+}
+
+type MoveCall struct {
+	Package       string      `json:"package"`
+	Module        string      `json:"module"`
+	Function      string      `json:"function"`
+	TypeArguments []string    `json:"type_arguments,omitempty"`
+	Arguments     []*Argument `json:"arguments,omitempty"`
+}
+
+type TransferObjects struct {
+	Objects []*Argument
+	Address *Argument
+}
+
+type SplitCoins struct {
+	Coin    *Argument
+	Amounts []*Argument
+}
+
+type MergeCoins struct {
+	Destination *Argument
+	Sources     []*Argument
+}
+
+// Argument is an argument of a command like "GasCoin", {"Input": 0}, {"Result": 1} or {"NestedResult": [1, 0]}
+type Argument struct {
+	Kind        string
+	Index       int
+	ResultIndex int
+}
+
+func (a *Argument) UnmarshalJSON(data []byte) error {
+	var gasCoin string
+	if err := json.Unmarshal(data, &gasCoin); err == nil {
+		*a = Argument{Kind: gasCoin}
+		return nil
+	}
+
+	var argument map[string]json.RawMessage
+	if err := json.Unmarshal(data, &argument); err != nil {
+		return err
+	}
+	if len(argument) != 1 {
+		return fmt.Errorf("invalid argument %s", data)
+	}
+	for kind, value := range argument {
+		*a = Argument{Kind: kind}
+		if kind == ArgumentKind_NESTED_RESULT {
+			return unmarshalTuple(value, &a.Index, &a.ResultIndex)
+		}
+		return json.Unmarshal(value, &a.Index)
+	}
+	return nil
+}
+
+func (a Argument) MarshalJSON() ([]byte, error) {
+	switch a.Kind {
+	case ArgumentKind_GAS_COIN:
+		return json.Marshal(a.Kind)
+	case ArgumentKind_NESTED_RESULT:
+		return json.Marshal(map[string][]int{a.Kind: {a.Index, a.ResultIndex}})
+	default:
+		return json.Marshal(map[string]int{a.Kind: a.Index})
+	}
+}
+
+// unmarshalTuple unmarshals the json array data into the elements of out
+func unmarshalTuple(data []byte, out ...interface{}) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	if len(items) != len(out) {
+		return fmt.Errorf("expected %v items, got %s", len(out), data)
+	}
+	for i, item := range items {
+		if err := json.Unmarshal(item, out[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package sui_model
+
+import (
+	"encoding/json"
+	"math/big"
+)
+
+const (
+	ExecutionStatus_SUCCESS = "success"
+	ExecutionStatus_FAILURE = "failure"
+)
+
+// TransactionEffects is the outcome of a tx.
+// It is emitted with the json received from the rpc, so the fields which are not modeled are kept.
+type TransactionEffects struct {
+	MessageVersion       string            `json:"messageVersion"`
+	Status               ExecutionStatus   `json:"status"`
+	ExecutedEpoch        string            `json:"executedEpoch"`
+	GasUsed              *GasCostSummary   `json:"gasUsed"`
+	TransactionDigest    string            `json:"transactionDigest"`
+	Created              []*OwnedObjectRef `json:"created,omitempty"`
+	Mutated              []*OwnedObjectRef `json:"mutated,omitempty"`
+	Unwrapped            []*OwnedObjectRef `json:"unwrapped,omitempty"`
+	Deleted              []*ObjectRef      `json:"deleted,omitempty"`
+	UnwrappedThenDeleted []*ObjectRef      `json:"unwrappedThenDeleted,omitempty"`
+	Wrapped              []*ObjectRef      `json:"wrapped,omitempty"`
+	GasObject            *OwnedObjectRef   `json:"gasObject"`
+	EventsDigest         string            `json:"eventsDigest,omitempty"`
+	Dependencies         []string          `json:"dependencies,omitempty"`
+
+	raw json.RawMessage
+}
+
+type transactionEffectsJson TransactionEffects
+
+func (e *TransactionEffects) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*transactionEffectsJson)(e)); err != nil {
+		return err
+	}
+	e.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func (e TransactionEffects) MarshalJSON() ([]byte, error) {
+	if e.raw != nil {
+		return e.raw, nil
+	}
+	return json.Marshal(transactionEffectsJson(e))
+}
+
+func (e *TransactionEffects) IsSuccess() bool {
+	return e.Status.Status == ExecutionStatus_SUCCESS
+}
+
+type ExecutionStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type GasCostSummary struct {
+	ComputationCost         string `json:"computationCost" validate:"-"`
+	StorageCost             string `json:"storageCost" validate:"-"`
+	StorageRebate           string `json:"storageRebate" validate:"-"`
+	NonRefundableStorageFee string `json:"nonRefundableStorageFee" validate:"-"`
+}
+
+// GasFee is the gas paid by the sender: computation cost + storage cost - storage rebate
+func (g *GasCostSummary) GasFee() *big.Int {
+	var (
+		computationCost, _ = new(big.Int).SetString(g.ComputationCost, 10)
+		storageCost, _     = new(big.Int).SetString(g.StorageCost, 10)
+		storageRebate, _   = new(big.Int).SetString(g.StorageRebate, 10)
+		fee                = new(big.Int)
+	)
+	for _, cost := range []*big.Int{computationCost, storageCost} {
+		if cost != nil {
+			fee.Add(fee, cost)
+		}
+	}
+	if storageRebate != nil {
+		fee.Sub(fee, storageRebate)
+	}
+	return fee
+}
+
+type OwnedObjectRef struct {
+	Owner     *Owner     `json:"owner"`
+	Reference *ObjectRef `json:"reference"`
+}
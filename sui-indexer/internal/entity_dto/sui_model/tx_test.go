@@ -0,0 +1,155 @@
+package sui_model
+
+import (
+	"encoding/json"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+const rpcTx = `{
+	"digest": "8Hvr1ZJ9u5KaZ5Rda1bQqBHeNb8tsSyUcAcvVgxQ9bZm",
+	"checkpoint": "30000000",
+	"timestampMs": "1712000000000",
+	"transaction": {
+		"data": {
+			"messageVersion": "v1",
+			"transaction": {
+				"kind": "ProgrammableTransaction",
+				"inputs": [
+					{"type": "pure", "valueType": "u64", "value": "1000"},
+					{"type": "object", "objectType": "sharedObject", "objectId": "0x6", "initialSharedVersion": "1", "mutable": false},
+					{"type": "pure", "valueType": "address", "value": "0xb"}
+				],
+				"transactions": [
+					{"SplitCoins": ["GasCoin", [{"Input": 0}]]},
+					{"MoveCall": {"package": "0x1eab", "module": "pool", "function": "swap", "type_arguments": ["0x2::sui::SUI"], "arguments": [{"NestedResult": [0, 0]}, {"Input": 1}]}},
+					{"TransferObjects": [[{"Result": 1}], {"Input": 2}]},
+					{"MergeCoins": ["GasCoin", [{"Result": 0}]]},
+					{"MakeMoveVec": [null, [{"Input": 0}]]}
+				]
+			},
+			"sender": "0xa",
+			"gasData": {
+				"payment": [{"objectId": "0x9", "version": 77, "digest": "gas-digest"}],
+				"owner": "0xa",
+				"price": "750",
+				"budget": "5000000"
+			}
+		},
+		"txSignatures": ["sig"]
+	},
+	"effects": {
+		"messageVersion": "v1",
+		"status": {"status": "success"},
+		"executedEpoch": "370",
+		"gasUsed": {"computationCost": "750000", "storageCost": "2000000", "storageRebate": "1500000", "nonRefundableStorageFee": "15000"},
+		"modifiedAtVersions": [{"objectId": "0x9", "sequenceNumber": "77"}],
+		"transactionDigest": "8Hvr1ZJ9u5KaZ5Rda1bQqBHeNb8tsSyUcAcvVgxQ9bZm",
+		"created": [{"owner": {"AddressOwner": "0xb"}, "reference": {"objectId": "0x10", "version": 78, "digest": "created-digest"}}],
+		"mutated": [{"owner": {"Shared": {"initial_shared_version": 5}}, "reference": {"objectId": "0x11", "version": 78, "digest": "mutated-digest"}}],
+		"deleted": [{"objectId": "0x12", "version": 78, "digest": "7gyGAp71YXQRoxmFBaHxofQXAipvgHyBKPyxmdSJxyvz"}],
+		"gasObject": {"owner": {"AddressOwner": "0xa"}, "reference": {"objectId": "0x9", "version": 78, "digest": "gas-digest-2"}},
+		"eventsDigest": "events-digest",
+		"dependencies": ["dep-1", "dep-2"]
+	},
+	"events": []
+}`
+
+func TestTransaction(t *testing.T) {
+	convey.Convey("TestTransaction", t, func() {
+		var tx Transaction
+		convey.So(json.Unmarshal([]byte(rpcTx), &tx), convey.ShouldBeNil)
+
+		convey.Convey("Transaction data is typed", func() {
+			data := tx.Transaction.Data
+			convey.So(data.Sender, convey.ShouldEqual, "0xa")
+			convey.So(data.GasData.Budget.String(), convey.ShouldEqual, "5000000")
+			convey.So(data.GasData.Payment[0].Version.String(), convey.ShouldEqual, "77")
+			convey.So(data.Transaction.IsProgrammable(), convey.ShouldBeTrue)
+
+			inputs := data.Transaction.Inputs
+			convey.So(inputs, convey.ShouldHaveLength, 3)
+			convey.So(inputs[0].Value, convey.ShouldEqual, "1000")
+			convey.So(inputs[1].ObjectId, convey.ShouldEqual, "0x6")
+			convey.So(*inputs[1].Mutable, convey.ShouldBeFalse)
+
+			commands := data.Transaction.Transactions
+			convey.So(commands, convey.ShouldHaveLength, 5)
+			convey.So(commands[0].SplitCoins.Coin, convey.ShouldResemble, &Argument{Kind: ArgumentKind_GAS_COIN})
+			convey.So(commands[0].SplitCoins.Amounts, convey.ShouldResemble, []*Argument{{Kind: ArgumentKind_INPUT}})
+			convey.So(commands[1].MoveCall.Function, convey.ShouldEqual, "swap")
+			convey.So(commands[1].MoveCall.Arguments[0], convey.ShouldResemble, &Argument{Kind: ArgumentKind_NESTED_RESULT})
+			convey.So(commands[1].MoveCall.Arguments[1], convey.ShouldResemble, &Argument{Kind: ArgumentKind_INPUT, Index: 1})
+			convey.So(commands[2].TransferObjects.Address, convey.ShouldResemble, &Argument{Kind: ArgumentKind_INPUT, Index: 2})
+			convey.So(commands[3].MergeCoins.Sources, convey.ShouldResemble, []*Argument{{Kind: ArgumentKind_RESULT}})
+			convey.So(commands[4].Type, convey.ShouldEqual, CommandType_MAKE_MOVE_VEC)
+		})
+
+		convey.Convey("Effects are typed", func() {
+			effects := tx.Effects
+			convey.So(effects.IsSuccess(), convey.ShouldBeTrue)
+			convey.So(tx.GasUsed().GasFee().String(), convey.ShouldEqual, "1250000")
+			convey.So(effects.Created[0].Owner, convey.ShouldResemble, &Owner{Type: OwnerType_ADDRESS, Address: "0xb"})
+			convey.So(effects.Mutated[0].Owner.Type, convey.ShouldEqual, OwnerType_SHARED)
+			convey.So(effects.Mutated[0].Owner.InitialSharedVersion.String(), convey.ShouldEqual, "5")
+			convey.So(effects.Deleted[0].ObjectId, convey.ShouldEqual, "0x12")
+			convey.So(effects.GasObject.Reference.Version.String(), convey.ShouldEqual, "78")
+			convey.So(effects.Dependencies, convey.ShouldResemble, []string{"dep-1", "dep-2"})
+		})
+
+		convey.Convey("Effects with an unknown owner are decoded", func() {
+			var effects TransactionEffects
+			convey.So(json.Unmarshal([]byte(`{
+				"status": {"status": "success"},
+				"created": [{"owner": {"ConsensusAddressOwner": {"owner": "0xc", "start_version": 7}}, "reference": {"objectId": "0x13", "version": 7, "digest": "obj-digest"}}]
+			}`), &effects), convey.ShouldBeNil)
+			convey.So(effects.Created[0].Owner.Type, convey.ShouldEqual, "ConsensusAddressOwner")
+		})
+
+		convey.Convey("Events carry the checkpoint, timestamp and gas used of the tx", func() {
+			convey.So(json.Unmarshal([]byte(`[{
+				"id": {"txDigest": "8Hvr1ZJ9u5KaZ5Rda1bQqBHeNb8tsSyUcAcvVgxQ9bZm", "eventSeq": "1"},
+				"packageId": "0x2",
+				"transactionModule": "coin",
+				"sender": "0xa",
+				"type": "0x2::coin::CoinEvent",
+				"parsedJson": {"amount": "10"},
+				"bcs": ""
+			}]`), &tx.Events), convey.ShouldBeNil)
+
+			events, err := tx.ParseEvents()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(events, convey.ShouldHaveLength, 1)
+			convey.So(events[0].DateKey, convey.ShouldEqual, "2024-04-01")
+			convey.So(events[0].Checkpoint, convey.ShouldEqual, "30000000")
+			convey.So(events[0].TimestampMs.Int64(), convey.ShouldEqual, 1712000000000)
+			convey.So(events[0].GasUsed, convey.ShouldEqual, tx.GasUsed())
+			convey.So(events[0].PartitionKey(), convey.ShouldEqual, "30000000-8Hvr1ZJ9u5KaZ5Rda1bQqBHeNb8tsSyUcAcvVgxQ9bZm")
+		})
+
+		convey.Convey("Emitted json keeps the rpc fields", func() {
+			data, err := jsoniter.Marshal(&tx)
+			convey.So(err, convey.ShouldBeNil)
+
+			var emitted, received map[string]interface{}
+			convey.So(json.Unmarshal(data, &emitted), convey.ShouldBeNil)
+			convey.So(json.Unmarshal([]byte(rpcTx), &received), convey.ShouldBeNil)
+			convey.So(emitted["transaction"], convey.ShouldResemble, received["transaction"])
+			convey.So(emitted["effects"], convey.ShouldResemble, received["effects"])
+		})
+
+		convey.Convey("Commands built in code are marshaled like the rpc", func() {
+			data, err := json.Marshal(&Command{
+				Type: CommandType_SPLIT_COINS,
+				SplitCoins: &SplitCoins{
+					Coin:    &Argument{Kind: ArgumentKind_GAS_COIN},
+					Amounts: []*Argument{{Kind: ArgumentKind_NESTED_RESULT, Index: 1, ResultIndex: 2}},
+				},
+			})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(string(data), convey.ShouldEqual, `{"SplitCoins":["GasCoin",[{"NestedResult":[1,2]}]]}`)
+		})
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -132,6 +133,11 @@ func NewKafkaTxProducer(ctx context.Context) (KafkaTxProducer, func(), error) {
 		return nil, nil, fmt.Errorf("missing env KAFKA_BROKERS")
 	}
 
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	logger.Info("[KafkaTxProducer] start tx producer")
 	producer := newProducerProvider(brokers, func() *sarama.Config {
 		kafkaConfig := sarama.NewConfig()
@@ -143,7 +149,8 @@ func NewKafkaTxProducer(ctx context.Context) (KafkaTxProducer, func(), error) {
 		kafkaConfig.Producer.RequiredAcks = sarama.WaitForAll
 		kafkaConfig.Producer.MaxMessageBytes = 20971520 // 20MB
 		kafkaConfig.Producer.Transaction.Retry.Backoff = 10
-		kafkaConfig.Producer.Transaction.ID = "txn_nimbus_eth_producer"
+		// transactional id must be unique per instance, otherwise the producers fence each other
+		kafkaConfig.Producer.Transaction.ID = fmt.Sprintf("%v-%v", conf.Config.KafkaTransactionId, hostname)
 		kafkaConfig.Net.MaxOpenRequests = 1
 		// for authentication in prod
 		if conf.Config.KafkaUsername != "" && conf.Config.KafkaPassword != "" {
@@ -304,7 +311,8 @@ func (p *kafkaTxProducer) clear() {
 	p.producers = p.producers[:0]
 }
 
-// TODO: test produce messages in transaction later
+// SendJson produces all messages in one transaction, consumers reading with isolation.level=read_committed
+// see either all of them or none of them.
 // https://viblo.asia/p/008-kafka-producer-transaction-va-delivery-semantics-voi-java-maGK76GO5j2
 func (p *kafkaTxProducer) SendJson(ctx context.Context, data map[string][]KafkaMsg) error {
 	if len(data) == 0 {
@@ -349,25 +357,25 @@ func (p *kafkaTxProducer) SendJson(ctx context.Context, data map[string][]KafkaM
 			if producer.TxnStatus()&sarama.ProducerTxnFlagFatalError != 0 {
 				// fatal error. need to recreate producer.
 				logger.Errorf("[KafkaTxProducer] producer is in a fatal state, need to recreate it")
-				break
+				return err
 			}
 			// If producer is in abortable state, try to abort current transaction.
 			if producer.TxnStatus()&sarama.ProducerTxnFlagAbortableError != 0 {
-				if err := producer.AbortTxn(); err != nil {
+				if abortErr := producer.AbortTxn(); abortErr != nil {
 					// If an error occurred just retry it.
-					logger.Errorf("[KafkaTxProducer] unable to abort transaction: %v", err)
+					logger.Errorf("[KafkaTxProducer] unable to abort transaction: %v", abortErr)
 					continue
 				}
-				break
+				// the messages are discarded, the caller has to send them again
+				return err
 			}
 			// if not you can retry
-			err = producer.CommitTxn()
-			if err != nil {
+			if err = producer.CommitTxn(); err != nil {
 				logger.Errorf("[KafkaTxProducer] unable to commit txn %v", err)
 				continue
 			}
+			return nil
 		}
-		return err
 	}
 
 	return nil
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -17,6 +18,16 @@ type BlockStatusRepo interface {
 	Save(ctx context.Context, entity *entity.BlockStatus) error
 	UpdateOne(ctx context.Context, entity *entity.BlockStatus) error
 	UpdateStatus(ctx context.Context, status int, ids ...string) error
-	UpdateFailedStatus(ctx context.Context) error
+	Claim(ctx context.Context, id string, owner string, toBlockNumber int64, leaseExpiresAt time.Time) error
+	Heartbeat(ctx context.Context, id string, owner string, leaseExpiresAt time.Time) error
+	ReplaceRange(ctx context.Context, owner string, ranges ...*entity.BlockStatus) error
+	UpdateFail(ctx context.Context, lastError string, maxAttempts int, ids ...string) error
+	Requeue(ctx context.Context, ids ...string) error
+	Reprocess(ctx context.Context, ids ...string) error
+	ExpireLeases(ctx context.Context, maxAttempts int) error
 	GetCurrentBlock(ctx context.Context) (int64, error)
+	GetGaps(ctx context.Context, blockType int) ([]*entity.BlockRange, error)
+	GetDoneWithoutIndex(ctx context.Context, from time.Time, to time.Time, blockTypes ...int) ([]*entity.BlockStatus, error)
+	// GetProgress sums the blocks of blockType by status and the blocks completed within the last window
+	GetProgress(ctx context.Context, blockType int, window time.Duration) (*entity.BlockProgress, error)
 }
@@ -0,0 +1,16 @@
+package repo
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/repo/gorm_scope"
+)
+
+type CheckpointMismatchRepo interface {
+	S() *gorm_scope.CheckpointMismatchScope
+	GetList(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) ([]*entity.CheckpointMismatch, error)
+	CreateMany(ctx context.Context, entities ...*entity.CheckpointMismatch) error
+}
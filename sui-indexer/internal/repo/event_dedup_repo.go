@@ -0,0 +1,16 @@
+package repo
+
+import (
+	"context"
+	"time"
+)
+
+// EventDedupRepo remembers the events emitted by the workers, so a retried checkpoint does not emit them again
+type EventDedupRepo interface {
+	// Exists returns which of keys were added and are not expired yet
+	Exists(ctx context.Context, keys ...string) (map[string]bool, error)
+	// Add remembers keys for ttl
+	Add(ctx context.Context, ttl time.Duration, keys ...string) error
+	// DeleteExpired removes the expired keys if the store does not expire them by itself
+	DeleteExpired(ctx context.Context) error
+}
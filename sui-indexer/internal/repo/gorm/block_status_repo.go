@@ -4,7 +4,6 @@ import (
 	"context"
 	"time"
 
-	"github.com/golang-module/carbon/v2"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
@@ -72,6 +71,8 @@ func (repo *blockStatusRepo) GetList(ctx context.Context, scopes ...func(db *gor
 	return res, nil
 }
 
+// CreateMany inserts the ranges, a range starting at the same block as an existing range of its type is skipped
+// so that concurrent inserts of the same blocks (fetch_checkpoint and heal_block_gaps) do not duplicate them
 func (repo *blockStatusRepo) CreateMany(ctx context.Context, entities ...*entity.BlockStatus) error {
 	if len(entities) == 0 {
 		return nil
@@ -86,7 +87,9 @@ func (repo *blockStatusRepo) CreateMany(ctx context.Context, entities ...*entity
 		rows = append(rows, row)
 	}
 
-	q := repo.getDB(ctx).CreateInBatches(rows, 200)
+	q := repo.getDB(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(rows, 200)
 	return q.Error
 }
 
@@ -123,10 +126,78 @@ func (repo *blockStatusRepo) UpdateStatus(ctx context.Context, status int, ids .
 	return q.Error
 }
 
+// Claim leases the range to owner, the range is shrunk to toBlockNumber when it is split
+func (repo *blockStatusRepo) Claim(ctx context.Context, id string, owner string, toBlockNumber int64, leaseExpiresAt time.Time) error {
+	var now = time.Now()
+	q := repo.getDB(ctx).
+		Model(&BlockStatusDao{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":           entity.BlockStatus_PROCESSING,
+			"to_block_number":  toBlockNumber,
+			"owner":            owner,
+			"heartbeat_at":     now,
+			"lease_expires_at": leaseExpiresAt,
+			"updated_at":       now,
+		})
+	return q.Error
+}
+
+// Heartbeat extends the lease of a PROCESSING range, it returns setting.LeaseLostErr when owner does not hold the lease anymore
+func (repo *blockStatusRepo) Heartbeat(ctx context.Context, id string, owner string, leaseExpiresAt time.Time) error {
+	q := repo.getDB(ctx).
+		Model(&BlockStatusDao{}).
+		Where("id = ? AND owner = ? AND status = ?", id, owner, entity.BlockStatus_PROCESSING).
+		Updates(map[string]interface{}{
+			"heartbeat_at":     time.Now(),
+			"lease_expires_at": leaseExpiresAt,
+		})
+	if err := q.Error; err != nil {
+		return err
+	}
+	if q.RowsAffected == 0 {
+		return setting.LeaseLostErr
+	}
+	return nil
+}
+
+// ReplaceRange releases the lease of owner and replaces the range by ranges,
+// the first range must keep the ID of the leased range and the others are inserted.
+func (repo *blockStatusRepo) ReplaceRange(ctx context.Context, owner string, ranges ...*entity.BlockStatus) error {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	var first = ranges[0]
+	q := repo.getDB(ctx).
+		Model(&BlockStatusDao{}).
+		Where("id = ? AND owner = ? AND status = ?", first.ID, owner, entity.BlockStatus_PROCESSING).
+		Updates(map[string]interface{}{
+			"status":           first.Status,
+			"to_block_number":  first.ToBlockNumber,
+			"digest":           first.Digest,
+			"previous_digest":  first.PreviousDigest,
+			"event_count":      first.EventCount,
+			"attempts":         first.Attempts,
+			"last_error":       first.LastError,
+			"skip_dedup":       first.SkipDedup,
+			"owner":            "",
+			"lease_expires_at": nil,
+			"updated_at":       time.Now(),
+		})
+	if err := q.Error; err != nil {
+		return err
+	}
+	if q.RowsAffected == 0 {
+		return setting.LeaseLostErr
+	}
+	return repo.CreateMany(ctx, ranges[1:]...)
+}
+
 func (repo *blockStatusRepo) GetCurrentBlock(ctx context.Context) (int64, error) {
 	var currentBlock int64
 	if err := repo.getDB(ctx).Raw(`SELECT
-		MAX(block_number) AS current_block
+		MAX(to_block_number) AS current_block
 	FROM block_status
 	WHERE type = ? AND status = ?`, entity.BlockStatusType_REALTIME, entity.BlockStatus_DONE).Scan(&currentBlock).Error; err != nil {
 		return 0, err
@@ -134,20 +205,187 @@ func (repo *blockStatusRepo) GetCurrentBlock(ctx context.Context) (int64, error)
 	return currentBlock, nil
 }
 
-func (repo *blockStatusRepo) UpdateFailedStatus(ctx context.Context) error {
+// GetGaps returns the ranges of block numbers which are missing between the lowest and the highest block of a type
+func (repo *blockStatusRepo) GetGaps(ctx context.Context, blockType int) ([]*entity.BlockRange, error) {
+	var gaps []*entity.BlockRange
+	if err := repo.getDB(ctx).Raw(`SELECT
+		to_block_number + 1 AS "from",
+		next_block_number - 1 AS "to"
+	FROM (
+		SELECT
+			block_number,
+			to_block_number,
+			LEAD(block_number) OVER (ORDER BY block_number) AS next_block_number
+		FROM block_status
+		WHERE chain = ? AND type = ?
+	) AS t
+	WHERE next_block_number > to_block_number + 1
+	ORDER BY block_number`, "SUI", blockType).Scan(&gaps).Error; err != nil {
+		return nil, err
+	}
+	return gaps, nil
+}
+
+// GetDoneWithoutIndex returns DONE blocks of blockTypes which emitted events but have no rows in sui_index
+func (repo *blockStatusRepo) GetDoneWithoutIndex(ctx context.Context, from time.Time, to time.Time, blockTypes ...int) ([]*entity.BlockStatus, error) {
+	if len(blockTypes) == 0 {
+		return nil, nil
+	}
+
+	var rows []*BlockStatusDao
+	if err := repo.getDB(ctx).Raw(`SELECT
+		b.*
+	FROM block_status b
+	WHERE b.chain = ? AND b.status = ? AND b.type IN ? AND b.event_count > 0
+		AND b.updated_at BETWEEN ? AND ?
+		AND NOT EXISTS (
+			SELECT 1 FROM sui_index i WHERE i.checkpoint_seq BETWEEN b.block_number AND b.to_block_number
+		)
+	ORDER BY b.block_number`, "SUI", entity.BlockStatus_DONE, blockTypes, from, to).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]*entity.BlockStatus, 0, len(rows))
+	for _, row := range rows {
+		item, err := row.toStruct()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+func (repo *blockStatusRepo) GetProgress(ctx context.Context, blockType int, window time.Duration) (*entity.BlockProgress, error) {
+	var row struct {
+		FromBlock    int64
+		ToBlock      int64
+		NotReady     int64
+		Processing   int64
+		Done         int64
+		Fail         int64
+		Dead         int64
+		DoneRecently int64
+	}
+	if err := repo.getDB(ctx).Raw(`SELECT
+		COALESCE(MIN(block_number), 0) AS from_block,
+		COALESCE(MAX(to_block_number), 0) AS to_block,
+		COALESCE(SUM(CASE WHEN status = @notReady THEN to_block_number - block_number + 1 END), 0) AS not_ready,
+		COALESCE(SUM(CASE WHEN status = @processing THEN to_block_number - block_number + 1 END), 0) AS processing,
+		COALESCE(SUM(CASE WHEN status = @done THEN to_block_number - block_number + 1 END), 0) AS done,
+		COALESCE(SUM(CASE WHEN status = @fail THEN to_block_number - block_number + 1 END), 0) AS fail,
+		COALESCE(SUM(CASE WHEN status = @dead THEN to_block_number - block_number + 1 END), 0) AS dead,
+		COALESCE(SUM(CASE WHEN status = @done AND updated_at >= @since THEN to_block_number - block_number + 1 END), 0) AS done_recently
+	FROM block_status
+	WHERE chain = @chain AND type = @type`, map[string]interface{}{
+		"notReady":   entity.BlockStatus_NOT_READY,
+		"processing": entity.BlockStatus_PROCESSING,
+		"done":       entity.BlockStatus_DONE,
+		"fail":       entity.BlockStatus_FAIL,
+		"dead":       entity.BlockStatus_DEAD,
+		"since":      time.Now().Add(-window),
+		"chain":      "SUI",
+		"type":       blockType,
+	}).Scan(&row).Error; err != nil {
+		return nil, err
+	}
+
+	return &entity.BlockProgress{
+		Type:         blockType,
+		From:         row.FromBlock,
+		To:           row.ToBlock,
+		NotReady:     row.NotReady,
+		Processing:   row.Processing,
+		Done:         row.Done,
+		Fail:         row.Fail,
+		Dead:         row.Dead,
+		DoneRecently: row.DoneRecently,
+		Window:       window,
+	}, nil
+}
+
+// UpdateFail increases the attempts of ranges and releases their lease, they become DEAD when the attempts reach maxAttempts
+func (repo *blockStatusRepo) UpdateFail(ctx context.Context, lastError string, maxAttempts int, ids ...string) error {
+	q := repo.getDB(ctx).
+		Model(&BlockStatusDao{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"status":           failStatusExpr(maxAttempts),
+			"attempts":         gorm.Expr("attempts + 1"),
+			"last_error":       lastError,
+			"owner":            "",
+			"lease_expires_at": nil,
+			"updated_at":       time.Now(),
+		})
+	return q.Error
+}
+
+// Requeue resets blocks to NOT_READY with a fresh attempts counter
+func (repo *blockStatusRepo) Requeue(ctx context.Context, ids ...string) error {
 	q := repo.getDB(ctx).
 		Model(&BlockStatusDao{}).
-		Where("status = ? AND updated_at < ?", entity.BlockStatus_PROCESSING, carbon.Now().AddHours(-1).ToDateTimeString(carbon.UTC)).
-		Update("status", entity.BlockStatus_FAIL)
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"status":     entity.BlockStatus_NOT_READY,
+			"attempts":   0,
+			"last_error": "",
+			"updated_at": time.Now(),
+		})
 	return q.Error
 }
 
+// Reprocess resets DONE ranges whose data is missing downstream to NOT_READY,
+// their events are emitted again without deduplication
+func (repo *blockStatusRepo) Reprocess(ctx context.Context, ids ...string) error {
+	q := repo.getDB(ctx).
+		Model(&BlockStatusDao{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"status":     entity.BlockStatus_NOT_READY,
+			"skip_dedup": true,
+			"updated_at": time.Now(),
+		})
+	return q.Error
+}
+
+// ExpireLeases marks PROCESSING ranges whose lease expired as FAIL (or DEAD),
+// ranges processed before leases were introduced expire 1 hour after their last update.
+func (repo *blockStatusRepo) ExpireLeases(ctx context.Context, maxAttempts int) error {
+	var now = time.Now()
+	q := repo.getDB(ctx).
+		Model(&BlockStatusDao{}).
+		Where("status = ? AND COALESCE(lease_expires_at, updated_at + INTERVAL '1 hour') < ?", entity.BlockStatus_PROCESSING, now).
+		Updates(map[string]interface{}{
+			"status":           failStatusExpr(maxAttempts),
+			"attempts":         gorm.Expr("attempts + 1"),
+			"last_error":       gorm.Expr("'lease of ' || COALESCE(owner, '') || ' expired'"),
+			"owner":            "",
+			"lease_expires_at": nil,
+			"updated_at":       now,
+		})
+	return q.Error
+}
+
+func failStatusExpr(maxAttempts int) clause.Expr {
+	return gorm.Expr("CASE WHEN attempts + 1 >= ? THEN ? ELSE ? END", maxAttempts, entity.BlockStatus_DEAD, entity.BlockStatus_FAIL)
+}
+
 type BlockStatusDao struct {
 	BaseDao
-	Chain       string `gorm:"column:chain;type:varchar(25);not null;uniqueIndex:idx_block_status_chain;<-create"`
-	BlockNumber int64  `gorm:"column:block_number;type:bigint;not null;<-create"`
-	Status      int    `gorm:"column:status;type:int;not null;default:0"`
-	Type        int    `gorm:"column:type;type:int;not null;default:0;<-create"`
+	Chain          string     `gorm:"column:chain;type:varchar(25);not null;uniqueIndex:idx_block_status_chain;<-create"`
+	BlockNumber    int64      `gorm:"column:block_number;type:bigint;not null;<-create"`
+	ToBlockNumber  int64      `gorm:"column:to_block_number;type:bigint;not null"`
+	Status         int        `gorm:"column:status;type:int;not null;default:0"`
+	Type           int        `gorm:"column:type;type:int;not null;default:0;<-create"`
+	Digest         string     `gorm:"column:digest;type:varchar(64)"`
+	PreviousDigest string     `gorm:"column:previous_digest;type:varchar(64)"`
+	EventCount     int        `gorm:"column:event_count;type:int;not null;default:0"`
+	Attempts       int        `gorm:"column:attempts;type:int;not null;default:0"`
+	LastError      string     `gorm:"column:last_error;type:text"`
+	SkipDedup      bool       `gorm:"column:skip_dedup;type:boolean;not null;default:false"`
+	Owner          string     `gorm:"column:owner;type:varchar(100)"`
+	HeartbeatAt    *time.Time `gorm:"column:heartbeat_at"`
+	LeaseExpiresAt *time.Time `gorm:"column:lease_expires_at"`
 }
 
 func (dao *BlockStatusDao) TableName() string {
@@ -158,18 +396,38 @@ func (dao *BlockStatusDao) fromStruct(item *entity.BlockStatus) (*BlockStatusDao
 	dao.BaseDao = *new(BaseDao).fromEntity(&item.Base)
 	dao.Chain = item.Chain
 	dao.BlockNumber = item.BlockNumber
+	dao.ToBlockNumber = item.ToBlockNumber
 	dao.Status = item.Status
 	dao.Type = item.Type
+	dao.Digest = item.Digest
+	dao.PreviousDigest = item.PreviousDigest
+	dao.EventCount = item.EventCount
+	dao.Attempts = item.Attempts
+	dao.LastError = item.LastError
+	dao.SkipDedup = item.SkipDedup
+	dao.Owner = item.Owner
+	dao.HeartbeatAt = item.HeartbeatAt
+	dao.LeaseExpiresAt = item.LeaseExpiresAt
 
 	return dao, nil
 }
 
 func (dao *BlockStatusDao) toStruct() (*entity.BlockStatus, error) {
 	return &entity.BlockStatus{
-		Base:        *dao.BaseDao.toEntity(),
-		Chain:       dao.Chain,
-		BlockNumber: dao.BlockNumber,
-		Status:      dao.Status,
-		Type:        dao.Type,
+		Base:           *dao.BaseDao.toEntity(),
+		Chain:          dao.Chain,
+		BlockNumber:    dao.BlockNumber,
+		ToBlockNumber:  dao.ToBlockNumber,
+		Status:         dao.Status,
+		Type:           dao.Type,
+		Digest:         dao.Digest,
+		PreviousDigest: dao.PreviousDigest,
+		EventCount:     dao.EventCount,
+		Attempts:       dao.Attempts,
+		LastError:      dao.LastError,
+		SkipDedup:      dao.SkipDedup,
+		Owner:          dao.Owner,
+		HeartbeatAt:    dao.HeartbeatAt,
+		LeaseExpiresAt: dao.LeaseExpiresAt,
 	}, nil
 }
@@ -0,0 +1,114 @@
+package gorm
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/repo"
+	"feng-sui-core/internal/repo/gorm_scope"
+	"feng-sui-core/internal/setting"
+)
+
+func NewCheckpointMismatchRepo(
+	baseRepo *baseRepo,
+	s *gorm_scope.CheckpointMismatchScope,
+) repo.CheckpointMismatchRepo {
+	return &checkpointMismatchRepo{
+		baseRepo: baseRepo,
+		s:        s,
+	}
+}
+
+type checkpointMismatchRepo struct {
+	*baseRepo
+	s *gorm_scope.CheckpointMismatchScope
+}
+
+func (repo *checkpointMismatchRepo) S() *gorm_scope.CheckpointMismatchScope {
+	return repo.s
+}
+
+func (repo *checkpointMismatchRepo) GetList(ctx context.Context, scopes ...func(db *gorm.DB) *gorm.DB) ([]*entity.CheckpointMismatch, error) {
+	if len(scopes) == 0 {
+		return nil, setting.MissingConditionErr
+	}
+
+	var rows []*CheckpointMismatchDao
+	q := repo.getDB(ctx).Model(&CheckpointMismatchDao{}).
+		Scopes(scopes...).
+		Find(&rows)
+	if err := q.Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]*entity.CheckpointMismatch, 0, q.RowsAffected)
+	for _, row := range rows {
+		item, err := row.toStruct()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+func (repo *checkpointMismatchRepo) CreateMany(ctx context.Context, entities ...*entity.CheckpointMismatch) error {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	var rows = make([]*CheckpointMismatchDao, 0, len(entities))
+	for _, item := range entities {
+		row, err := new(CheckpointMismatchDao).fromStruct(item)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+
+	q := repo.getDB(ctx).CreateInBatches(rows, 200)
+	return q.Error
+}
+
+type CheckpointMismatchDao struct {
+	BaseDao
+	Chain                   string `gorm:"column:chain;type:varchar(25);not null;<-create"`
+	BlockNumber             int64  `gorm:"column:block_number;type:bigint;not null;<-create"`
+	Digest                  string `gorm:"column:digest;type:varchar(64);<-create"`
+	PreviousDigest          string `gorm:"column:previous_digest;type:varchar(64);<-create"`
+	NeighbourBlockNumber    int64  `gorm:"column:neighbour_block_number;type:bigint;not null;<-create"`
+	NeighbourDigest         string `gorm:"column:neighbour_digest;type:varchar(64);<-create"`
+	NeighbourPreviousDigest string `gorm:"column:neighbour_previous_digest;type:varchar(64);<-create"`
+}
+
+func (dao *CheckpointMismatchDao) TableName() string {
+	return "checkpoint_mismatch"
+}
+
+func (dao *CheckpointMismatchDao) fromStruct(item *entity.CheckpointMismatch) (*CheckpointMismatchDao, error) {
+	dao.BaseDao = *new(BaseDao).fromEntity(&item.Base)
+	dao.Chain = item.Chain
+	dao.BlockNumber = item.BlockNumber
+	dao.Digest = item.Digest
+	dao.PreviousDigest = item.PreviousDigest
+	dao.NeighbourBlockNumber = item.NeighbourBlockNumber
+	dao.NeighbourDigest = item.NeighbourDigest
+	dao.NeighbourPreviousDigest = item.NeighbourPreviousDigest
+
+	return dao, nil
+}
+
+func (dao *CheckpointMismatchDao) toStruct() (*entity.CheckpointMismatch, error) {
+	return &entity.CheckpointMismatch{
+		Base:                    *dao.BaseDao.toEntity(),
+		Chain:                   dao.Chain,
+		BlockNumber:             dao.BlockNumber,
+		Digest:                  dao.Digest,
+		PreviousDigest:          dao.PreviousDigest,
+		NeighbourBlockNumber:    dao.NeighbourBlockNumber,
+		NeighbourDigest:         dao.NeighbourDigest,
+		NeighbourPreviousDigest: dao.NeighbourPreviousDigest,
+	}, nil
+}
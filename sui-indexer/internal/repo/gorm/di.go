@@ -10,4 +10,5 @@ var GraphSet = wire.NewSet(
 	NewBlockStatusRepo,
 	NewTradeRepo,
 	NewTokenRepo,
+	NewCheckpointMismatchRepo,
 )
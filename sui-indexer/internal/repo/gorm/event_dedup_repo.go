@@ -0,0 +1,88 @@
+package gorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm/clause"
+
+	"feng-sui-core/internal/repo"
+)
+
+func NewEventDedupRepo(
+	baseRepo *baseRepo,
+) repo.EventDedupRepo {
+	return &eventDedupRepo{
+		baseRepo: baseRepo,
+	}
+}
+
+type eventDedupRepo struct {
+	*baseRepo
+}
+
+func (repo *eventDedupRepo) Exists(ctx context.Context, keys ...string) (map[string]bool, error) {
+	var res = make(map[string]bool, len(keys))
+	if len(keys) == 0 {
+		return res, nil
+	}
+
+	var existedKeys []string
+	for _, chunk := range lo.Chunk(keys, 1000) {
+		var rows []string
+		q := repo.getDB(ctx).Model(&EventDedupDao{}).
+			Where("key IN ? AND expires_at > ?", chunk, time.Now()).
+			Pluck("key", &rows)
+		if err := q.Error; err != nil {
+			return nil, err
+		}
+		existedKeys = append(existedKeys, rows...)
+	}
+
+	for _, key := range keys {
+		res[key] = false
+	}
+	for _, key := range existedKeys {
+		res[key] = true
+	}
+	return res, nil
+}
+
+func (repo *eventDedupRepo) Add(ctx context.Context, ttl time.Duration, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	rows := lo.Map(lo.Uniq(keys), func(key string, _ int) *EventDedupDao {
+		return &EventDedupDao{
+			Key:       key,
+			ExpiresAt: expiresAt,
+		}
+	})
+
+	q := repo.getDB(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+		}).
+		CreateInBatches(rows, 500)
+	return q.Error
+}
+
+func (repo *eventDedupRepo) DeleteExpired(ctx context.Context) error {
+	q := repo.getDB(ctx).
+		Where("expires_at <= ?", time.Now()).
+		Delete(&EventDedupDao{})
+	return q.Error
+}
+
+type EventDedupDao struct {
+	Key       string    `gorm:"column:key;type:varchar(128);primaryKey"`
+	ExpiresAt time.Time `gorm:"column:expires_at;type:timestamptz;not null"`
+}
+
+func (dao *EventDedupDao) TableName() string {
+	return "event_dedup"
+}
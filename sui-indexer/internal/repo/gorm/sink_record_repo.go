@@ -0,0 +1,64 @@
+package gorm
+
+import (
+	"context"
+
+	"gorm.io/gorm/clause"
+
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/repo"
+)
+
+func NewSinkRecordRepo(
+	baseRepo *baseRepo,
+) repo.SinkRecordRepo {
+	return &sinkRecordRepo{
+		baseRepo: baseRepo,
+	}
+}
+
+type sinkRecordRepo struct {
+	*baseRepo
+}
+
+func (repo *sinkRecordRepo) CreateMany(ctx context.Context, entities ...*entity.SinkRecord) error {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	var rows = make([]*SinkRecordDao, 0, len(entities))
+	for _, item := range entities {
+		row, err := new(SinkRecordDao).fromStruct(item)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+
+	q := repo.getDB(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(rows, 500)
+	return q.Error
+}
+
+type SinkRecordDao struct {
+	Topic        string `gorm:"column:topic;type:varchar(255);primaryKey"`
+	Checkpoint   int64  `gorm:"column:checkpoint;type:bigint;primaryKey"`
+	Index        int    `gorm:"column:idx;type:int;primaryKey"`
+	PartitionKey string `gorm:"column:partition_key;type:varchar(255)"`
+	Data         string `gorm:"column:data;type:jsonb;not null"`
+}
+
+func (dao *SinkRecordDao) TableName() string {
+	return "sink_record"
+}
+
+func (dao *SinkRecordDao) fromStruct(item *entity.SinkRecord) (*SinkRecordDao, error) {
+	dao.Topic = item.Topic
+	dao.Checkpoint = item.Checkpoint
+	dao.Index = item.Index
+	dao.PartitionKey = item.PartitionKey
+	dao.Data = item.Data
+
+	return dao, nil
+}
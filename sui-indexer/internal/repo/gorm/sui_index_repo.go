@@ -0,0 +1,68 @@
+package gorm
+
+import (
+	"context"
+
+	"gorm.io/gorm/clause"
+
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/repo"
+)
+
+func NewSuiIndexRepo(
+	baseRepo *baseRepo,
+) repo.SuiIndexRepo {
+	return &suiIndexRepo{
+		baseRepo: baseRepo,
+	}
+}
+
+type suiIndexRepo struct {
+	*baseRepo
+}
+
+func (repo *suiIndexRepo) CreateMany(ctx context.Context, entities ...*entity.SuiIndex) error {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	var rows = make([]*SuiIndexDao, 0, len(entities))
+	for _, item := range entities {
+		row, err := new(SuiIndexDao).fromStruct(item)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+
+	q := repo.getDB(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(rows, 500)
+	return q.Error
+}
+
+type SuiIndexDao struct {
+	DateKey          string `gorm:"column:date_key;type:text"`
+	CheckpointDigest string `gorm:"column:checkpoint_digest;type:text"`
+	CheckpointSeq    int64  `gorm:"column:checkpoint_seq;type:bigint;primaryKey"`
+	TxDigest         string `gorm:"column:tx_digest;type:text;primaryKey"`
+	EventSeq         int64  `gorm:"column:event_seq;type:bigint;primaryKey"`
+	PackageId        string `gorm:"column:package_id;type:text"`
+	EventType        string `gorm:"column:event_type;type:text"`
+}
+
+func (dao *SuiIndexDao) TableName() string {
+	return "sui_index"
+}
+
+func (dao *SuiIndexDao) fromStruct(item *entity.SuiIndex) (*SuiIndexDao, error) {
+	dao.DateKey = item.DateKey
+	dao.CheckpointDigest = item.CheckpointDigest
+	dao.CheckpointSeq = item.CheckpointSeq
+	dao.TxDigest = item.TxDigest
+	dao.EventSeq = item.EventSeq
+	dao.PackageId = item.PackageId
+	dao.EventType = item.EventType
+
+	return dao, nil
+}
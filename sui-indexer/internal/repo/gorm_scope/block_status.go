@@ -1,8 +1,12 @@
 package gorm_scope
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+
+	"feng-sui-core/internal/entity"
 )
 
 type BlockStatusScope struct {
@@ -28,8 +32,45 @@ func (s *BlockStatusScope) FilterStatuses(statuses ...int) func(db *gorm.DB) *go
 	}
 }
 
+// FilterClaimable filters NOT_READY ranges and FAIL ranges whose retry backoff elapsed since their last failure,
+// the backoff doubles with each attempt from backoff up to maxBackoff
+func (s *BlockStatusScope) FilterClaimable(now time.Time, backoff time.Duration, maxBackoff time.Duration) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("status = ? OR (status = ? AND updated_at + LEAST(? * POWER(2, GREATEST(attempts - 1, 0)), ?) * INTERVAL '1 second' <= ?)",
+			entity.BlockStatus_NOT_READY, entity.BlockStatus_FAIL, backoff.Seconds(), maxBackoff.Seconds(), now)
+	}
+}
+
 func (s *BlockStatusScope) FilterType(queryType int) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
 		return db.Where("type = ?", queryType)
 	}
 }
+
+// LockingForUpdate waits for locked rows instead of skipping them,
+// use it when the selected rows must be read in a consistent order.
+func (s *BlockStatusScope) LockingForUpdate() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Clauses(clause.Locking{
+			Strength: "UPDATE",
+		})
+	}
+}
+
+// FilterOverlap filters ranges overlapping with the blocks from..to (inclusive)
+func (s *BlockStatusScope) FilterOverlap(from int64, to int64) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("block_number <= ? AND to_block_number >= ?", to, from)
+	}
+}
+
+// FilterContains filters ranges containing any of the blocks
+func (s *BlockStatusScope) FilterContains(blockNumbers ...int64) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		cond := db.Session(&gorm.Session{NewDB: true})
+		for _, blockNumber := range blockNumbers {
+			cond = cond.Or("block_number <= ? AND to_block_number >= ?", blockNumber, blockNumber)
+		}
+		return db.Where(cond)
+	}
+}
@@ -0,0 +1,9 @@
+package gorm_scope
+
+type CheckpointMismatchScope struct {
+	*base
+}
+
+func NewCheckpointMismatch(b *base) *CheckpointMismatchScope {
+	return &CheckpointMismatchScope{base: b}
+}
@@ -9,4 +9,5 @@ var GraphSet = wire.NewSet(
 	NewBlockStatus,
 	NewTrade,
 	NewToken,
+	NewCheckpointMismatch,
 )
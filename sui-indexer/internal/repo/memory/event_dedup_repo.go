@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"feng-sui-core/internal/repo"
+)
+
+// NewEventDedupRepo keeps the emitted events in memory, it is only shared by the goroutines of one process
+func NewEventDedupRepo() repo.EventDedupRepo {
+	return &eventDedupRepo{
+		keys: make(map[string]time.Time),
+	}
+}
+
+type eventDedupRepo struct {
+	mu   sync.RWMutex
+	keys map[string]time.Time
+}
+
+func (repo *eventDedupRepo) Exists(ctx context.Context, keys ...string) (map[string]bool, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var (
+		res = make(map[string]bool, len(keys))
+		now = time.Now()
+	)
+	for _, key := range keys {
+		expiresAt, ok := repo.keys[key]
+		res[key] = ok && expiresAt.After(now)
+	}
+	return res, nil
+}
+
+func (repo *eventDedupRepo) Add(ctx context.Context, ttl time.Duration, keys ...string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	for _, key := range keys {
+		repo.keys[key] = expiresAt
+	}
+	return nil
+}
+
+func (repo *eventDedupRepo) DeleteExpired(ctx context.Context) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := time.Now()
+	for key, expiresAt := range repo.keys {
+		if !expiresAt.After(now) {
+			delete(repo.keys, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"feng-sui-core/internal/infra"
+	"feng-sui-core/internal/repo"
+)
+
+const eventDedupPrefix = "sui-indexer:event"
+
+func NewEventDedupRepo(
+	rd *infra.RedisClient,
+) repo.EventDedupRepo {
+	return &eventDedupRepo{
+		rd: rd,
+	}
+}
+
+type eventDedupRepo struct {
+	rd *infra.RedisClient
+}
+
+func (repo *eventDedupRepo) Exists(ctx context.Context, keys ...string) (map[string]bool, error) {
+	var res = make(map[string]bool, len(keys))
+	if len(keys) == 0 {
+		return res, nil
+	}
+
+	pipe := repo.rd.Pipeline()
+	cmds := make([]*redis.IntCmd, 0, len(keys))
+	for _, key := range keys {
+		cmds = append(cmds, pipe.Exists(ctx, repo.redisKey(key)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	for i, cmd := range cmds {
+		res[keys[i]] = cmd.Val() == 1
+	}
+	return res, nil
+}
+
+func (repo *eventDedupRepo) Add(ctx context.Context, ttl time.Duration, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	pipe := repo.rd.Pipeline()
+	for _, key := range keys {
+		pipe.Set(ctx, repo.redisKey(key), 1, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeleteExpired does nothing because redis expires the keys by itself
+func (repo *eventDedupRepo) DeleteExpired(ctx context.Context) error {
+	return nil
+}
+
+func (repo *eventDedupRepo) redisKey(key string) string {
+	return fmt.Sprintf("%s:%s", eventDedupPrefix, key)
+}
@@ -0,0 +1,12 @@
+package repo
+
+import (
+	"context"
+
+	"feng-sui-core/internal/entity"
+)
+
+type SinkRecordRepo interface {
+	// CreateMany stores entities, the records already stored are kept as they are
+	CreateMany(ctx context.Context, entities ...*entity.SinkRecord) error
+}
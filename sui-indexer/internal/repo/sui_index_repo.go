@@ -0,0 +1,12 @@
+package repo
+
+import (
+	"context"
+
+	"feng-sui-core/internal/entity"
+)
+
+type SuiIndexRepo interface {
+	// CreateMany stores entities, the rows already stored are kept as they are
+	CreateMany(ctx context.Context, entities ...*entity.SuiIndex) error
+}
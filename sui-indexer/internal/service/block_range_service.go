@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/getnimbus/ultrago/u_logger"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/repo"
+)
+
+func NewBlockRangeService(
+	baseSvc BaseService,
+	blockStatusRepo repo.BlockStatusRepo,
+) BlockRangeService {
+	return &blockRangeService{
+		baseSvc:         baseSvc,
+		blockStatusRepo: blockStatusRepo,
+	}
+}
+
+type BlockRangeService interface {
+	// Claim leases the lowest range waiting to be processed to owner, ranges bigger than maxSize are split
+	// at the multiples of maxSize and the remaining blocks are left for the other workers.
+	// FAIL ranges are claimed again once their retry backoff elapsed.
+	// It returns nil when there is no range to process.
+	Claim(ctx context.Context, blockType int, owner string, maxSize int64) (*entity.BlockStatus, error)
+	// KeepAlive extends the lease of the range until ctx is done, it returns setting.LeaseLostErr when the lease is lost
+	KeepAlive(ctx context.Context, blockStatus *entity.BlockStatus, owner string) error
+	// Complete splits the range by the results of its blocks into DONE and FAIL ranges and releases the lease
+	Complete(ctx context.Context, blockStatus *entity.BlockStatus, owner string, results []*entity.BlockResult) error
+	// Fail marks the whole range FAIL and releases the lease
+	Fail(ctx context.Context, blockStatus *entity.BlockStatus, cause error) error
+}
+
+type blockRangeService struct {
+	baseSvc         BaseService
+	blockStatusRepo repo.BlockStatusRepo
+}
+
+func (svc *blockRangeService) Claim(ctx context.Context, blockType int, owner string, maxSize int64) (*entity.BlockStatus, error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	// recover the ranges of dead workers before claiming
+	if err := svc.blockStatusRepo.ExpireLeases(ctx, conf.Config.MaxBlockAttempts); err != nil {
+		logger.Errorf("failed to expire leases: %v", err)
+		return nil, err
+	}
+
+	var blockStatus *entity.BlockStatus
+	if err := svc.baseSvc.ExecTx(ctx, func(txCtx context.Context) error {
+		var err error
+		blockStatus, err = svc.blockStatusRepo.GetOne(txCtx,
+			svc.blockStatusRepo.S().Locking(),
+			svc.blockStatusRepo.S().FilterClaimable(time.Now(), conf.Config.BlockRetryBackoff, conf.Config.BlockMaxRetryBackoff),
+			svc.blockStatusRepo.S().FilterType(blockType),
+			svc.blockStatusRepo.S().ColumnEqual("chain", "SUI"),
+			svc.blockStatusRepo.S().SortBy("block_number", "ASC"),
+		)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			blockStatus = nil
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if end := blockStatus.ClaimEnd(maxSize); end < blockStatus.ToBlockNumber {
+			// leave the remaining blocks for the other workers
+			remaining := *blockStatus
+			// the remaining blocks of a FAIL range keep its retry backoff
+			remaining.Base = entity.Base{UpdatedAt: blockStatus.UpdatedAt}
+			remaining.BlockNumber = end + 1
+			if err := svc.blockStatusRepo.CreateMany(txCtx, &remaining); err != nil {
+				return err
+			}
+			blockStatus.ToBlockNumber = remaining.BlockNumber - 1
+		}
+
+		var (
+			now            = time.Now()
+			leaseExpiresAt = now.Add(conf.Config.BlockLeaseDuration)
+		)
+		if err := svc.blockStatusRepo.Claim(txCtx, blockStatus.ID, owner, blockStatus.ToBlockNumber, leaseExpiresAt); err != nil {
+			return err
+		}
+		blockStatus.Status = entity.BlockStatus_PROCESSING
+		blockStatus.Owner = owner
+		blockStatus.HeartbeatAt = &now
+		blockStatus.LeaseExpiresAt = &leaseExpiresAt
+		return nil
+	}); err != nil {
+		logger.Errorf("failed to claim block range: %v", err)
+		return nil, err
+	}
+	return blockStatus, nil
+}
+
+func (svc *blockRangeService) KeepAlive(ctx context.Context, blockStatus *entity.BlockStatus, owner string) error {
+	ticker := time.NewTicker(conf.Config.BlockLeaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			leaseExpiresAt := time.Now().Add(conf.Config.BlockLeaseDuration)
+			if err := svc.blockStatusRepo.Heartbeat(ctx, blockStatus.ID, owner, leaseExpiresAt); err != nil {
+				if errors.Is(err, context.Canceled) {
+					return nil
+				}
+				return fmt.Errorf("failed to heartbeat range %v-%v: %w", blockStatus.BlockNumber, blockStatus.ToBlockNumber, err)
+			}
+			blockStatus.LeaseExpiresAt = &leaseExpiresAt
+		}
+	}
+}
+
+func (svc *blockRangeService) Complete(ctx context.Context, blockStatus *entity.BlockStatus, owner string, results []*entity.BlockResult) error {
+	ranges := splitRange(blockStatus, results, conf.Config.MaxBlockAttempts)
+	return svc.baseSvc.ExecTx(ctx, func(txCtx context.Context) error {
+		return svc.blockStatusRepo.ReplaceRange(txCtx, owner, ranges...)
+	})
+}
+
+func (svc *blockRangeService) Fail(ctx context.Context, blockStatus *entity.BlockStatus, cause error) error {
+	if blockStatus == nil {
+		return nil
+	}
+	return svc.blockStatusRepo.UpdateFail(ctx, cause.Error(), conf.Config.MaxBlockAttempts, blockStatus.ID)
+}
+
+// splitRange groups consecutive blocks with the same outcome into ranges,
+// blocks without result are considered failed. The first range keeps the ID of blockStatus.
+func splitRange(blockStatus *entity.BlockStatus, results []*entity.BlockResult, maxAttempts int) []*entity.BlockStatus {
+	resultByBlock := lo.SliceToMap(results, func(item *entity.BlockResult) (int64, *entity.BlockResult) {
+		return item.BlockNumber, item
+	})
+
+	var (
+		ranges  = make([]*entity.BlockStatus, 0)
+		current *entity.BlockStatus
+		failed  bool
+	)
+	for blockNumber := blockStatus.BlockNumber; blockNumber <= blockStatus.ToBlockNumber; blockNumber++ {
+		result, ok := resultByBlock[blockNumber]
+		if !ok {
+			result = &entity.BlockResult{
+				BlockNumber: blockNumber,
+				Err:         fmt.Errorf("missing result of block %v", blockNumber),
+			}
+		}
+
+		if current == nil || failed != (result.Err != nil) {
+			failed = result.Err != nil
+			current = &entity.BlockStatus{
+				Chain:          blockStatus.Chain,
+				BlockNumber:    blockNumber,
+				Status:         entity.BlockStatus_DONE,
+				Type:           blockStatus.Type,
+				PreviousDigest: result.PreviousDigest,
+				Attempts:       blockStatus.Attempts,
+			}
+			if failed {
+				current.Status = entity.BlockStatus_FAIL
+				current.Attempts++
+				current.LastError = result.Err.Error()
+				current.PreviousDigest = ""
+				// a failed retry of a reprocessed range must not be deduplicated either
+				current.SkipDedup = blockStatus.SkipDedup
+				if current.Attempts >= maxAttempts {
+					current.Status = entity.BlockStatus_DEAD
+				}
+			}
+			ranges = append(ranges, current)
+		}
+		current.ToBlockNumber = blockNumber
+		if result.Err == nil {
+			current.Digest = result.Digest
+			current.EventCount += result.EventCount
+		}
+	}
+
+	if len(ranges) > 0 {
+		ranges[0].Base = blockStatus.Base
+	}
+	return ranges
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"feng-sui-core/internal/entity"
+)
+
+func TestSplitRange(t *testing.T) {
+	convey.Convey("TestSplitRange", t, func() {
+		blockStatus := &entity.BlockStatus{
+			Base:          entity.Base{ID: "range-id"},
+			Chain:         "SUI",
+			BlockNumber:   100,
+			ToBlockNumber: 104,
+			Status:        entity.BlockStatus_PROCESSING,
+			Attempts:      1,
+		}
+		done := func(blockNumber int64, eventCount int) *entity.BlockResult {
+			return &entity.BlockResult{
+				BlockNumber:    blockNumber,
+				Digest:         fmt.Sprintf("digest-%v", blockNumber),
+				PreviousDigest: fmt.Sprintf("digest-%v", blockNumber-1),
+				EventCount:     eventCount,
+			}
+		}
+
+		convey.Convey("All blocks are done", func() {
+			ranges := splitRange(blockStatus, []*entity.BlockResult{
+				done(100, 1), done(101, 0), done(102, 2), done(103, 0), done(104, 3),
+			}, 3)
+			convey.So(ranges, convey.ShouldHaveLength, 1)
+			convey.So(ranges[0].ID, convey.ShouldEqual, "range-id")
+			convey.So(ranges[0].Status, convey.ShouldEqual, entity.BlockStatus_DONE)
+			convey.So(ranges[0].ToBlockNumber, convey.ShouldEqual, 104)
+			convey.So(ranges[0].PreviousDigest, convey.ShouldEqual, "digest-99")
+			convey.So(ranges[0].Digest, convey.ShouldEqual, "digest-104")
+			convey.So(ranges[0].EventCount, convey.ShouldEqual, 6)
+		})
+
+		convey.Convey("Failed and missing blocks are split", func() {
+			ranges := splitRange(blockStatus, []*entity.BlockResult{
+				done(100, 1),
+				{BlockNumber: 101, Err: errors.New("rpc error")},
+				done(103, 2),
+				done(104, 0),
+			}, 3)
+			convey.So(ranges, convey.ShouldHaveLength, 3)
+			convey.So(ranges[0].ID, convey.ShouldEqual, "range-id")
+			convey.So(ranges[0].Range(), convey.ShouldResemble, &entity.BlockRange{From: 100, To: 100})
+			convey.So(ranges[1].ID, convey.ShouldBeEmpty)
+			convey.So(ranges[1].Range(), convey.ShouldResemble, &entity.BlockRange{From: 101, To: 102})
+			convey.So(ranges[1].Status, convey.ShouldEqual, entity.BlockStatus_FAIL)
+			convey.So(ranges[1].Attempts, convey.ShouldEqual, 2)
+			convey.So(ranges[1].LastError, convey.ShouldEqual, "rpc error")
+			convey.So(ranges[2].Range(), convey.ShouldResemble, &entity.BlockRange{From: 103, To: 104})
+			convey.So(ranges[2].Status, convey.ShouldEqual, entity.BlockStatus_DONE)
+			convey.So(ranges[2].Attempts, convey.ShouldEqual, 1)
+			convey.So(ranges[2].EventCount, convey.ShouldEqual, 2)
+		})
+
+		convey.Convey("Only failed blocks of a reprocessed range skip dedup again", func() {
+			reprocessed := *blockStatus
+			reprocessed.SkipDedup = true
+			ranges := splitRange(&reprocessed, []*entity.BlockResult{
+				done(100, 1),
+				{BlockNumber: 101, Err: errors.New("rpc error")},
+			}, 3)
+			convey.So(ranges, convey.ShouldHaveLength, 2)
+			convey.So(ranges[0].Status, convey.ShouldEqual, entity.BlockStatus_DONE)
+			convey.So(ranges[0].SkipDedup, convey.ShouldBeFalse)
+			convey.So(ranges[1].Status, convey.ShouldEqual, entity.BlockStatus_FAIL)
+			convey.So(ranges[1].SkipDedup, convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Failed blocks become dead at max attempts", func() {
+			ranges := splitRange(blockStatus, nil, 2)
+			convey.So(ranges, convey.ShouldHaveLength, 1)
+			convey.So(ranges[0].Status, convey.ShouldEqual, entity.BlockStatus_DEAD)
+			convey.So(ranges[0].Range(), convey.ShouldResemble, &entity.BlockRange{From: 100, To: 104})
+		})
+	})
+}
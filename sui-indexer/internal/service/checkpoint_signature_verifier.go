@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/getnimbus/ultrago/u_logger"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/entity_dto/sui_model"
+	"feng-sui-core/internal/setting"
+	"feng-sui-core/pkg/sui_cert"
+	"feng-sui-core/pkg/sui_rpc"
+)
+
+// CheckpointSourceConfig tells where the certified checkpoints are read and which committee is trusted
+type CheckpointSourceConfig struct {
+	// Url of the CheckpointData of a checkpoint, {seq} is replaced by its sequence number. Urls ending
+	// with .chk are checkpoint files of the archives, the others serve the BCS CheckpointData.
+	Url string
+	// TrustedEpoch is the epoch whose committee is taken from the rpc, the next committees are read
+	// from the end of epoch checkpoints
+	TrustedEpoch uint64
+	// Concurrency bounds the checkpoints verified at the same time
+	Concurrency int
+}
+
+// NewCheckpointSignatureVerifier verifies the checkpoints against the certified checkpoints of
+// CHECKPOINT_SOURCE_URL when CHECKPOINT_VERIFY_SIGNATURES is yes, it accepts all checkpoints otherwise
+func NewCheckpointSignatureVerifier(pool *sui_rpc.Pool) (CheckpointSignatureVerifier, error) {
+	if !conf.Config.IsCheckpointVerifySignatures() {
+		return &noSignatureVerifier{}, nil
+	}
+	if conf.Config.CheckpointTrustedEpoch < 0 {
+		return nil, fmt.Errorf("invalid CHECKPOINT_TRUSTED_EPOCH %v", conf.Config.CheckpointTrustedEpoch)
+	}
+	transport, err := newHttpTransport()
+	if err != nil {
+		return nil, err
+	}
+	return newCheckpointSignatureVerifier(pool, &http.Client{
+		Transport: transport,
+		Timeout:   2 * 60 * time.Second, // 2 mins
+	}, CheckpointSourceConfig{
+		Url:          conf.Config.CheckpointSourceUrl,
+		TrustedEpoch: uint64(conf.Config.CheckpointTrustedEpoch),
+		Concurrency:  conf.Config.CheckpointVerifyConcurrency,
+	}), nil
+}
+
+func newCheckpointSignatureVerifier(pool *sui_rpc.Pool, client *http.Client, config CheckpointSourceConfig) *checkpointSignatureVerifier {
+	return &checkpointSignatureVerifier{
+		pool:       pool,
+		client:     client,
+		config:     config,
+		committees: make(map[uint64]*sui_cert.Committee),
+		epochEnds:  make(map[uint64]int64),
+	}
+}
+
+type CheckpointSignatureVerifier interface {
+	// VerifySignatures checks each checkpoint against its certified checkpoint: the summary must be signed by a
+	// quorum of the committee of its epoch, match the checkpoint and list the txs of the checkpoint in its contents.
+	// It returns the errors of the checkpoints failing verification, keyed by sequence number.
+	VerifySignatures(ctx context.Context, checkpoints ...*sui_model.Checkpoint) (map[int64]error, error)
+}
+
+type noSignatureVerifier struct{}
+
+func (svc *noSignatureVerifier) VerifySignatures(ctx context.Context, checkpoints ...*sui_model.Checkpoint) (map[int64]error, error) {
+	return make(map[int64]error), nil
+}
+
+type checkpointSignatureVerifier struct {
+	pool   *sui_rpc.Pool
+	client *http.Client
+	config CheckpointSourceConfig
+
+	// mu guards the committees and serializes the walks from an epoch to the next ones
+	mu         sync.Mutex
+	committees map[uint64]*sui_cert.Committee
+	epochEnds  map[uint64]int64 // last checkpoint of the walked epochs
+}
+
+func (svc *checkpointSignatureVerifier) VerifySignatures(ctx context.Context, checkpoints ...*sui_model.Checkpoint) (map[int64]error, error) {
+	var (
+		failures     = make(map[int64]error)
+		mu           sync.Mutex
+		eg, childCtx = errgroup.WithContext(ctx)
+	)
+	eg.SetLimit(max(svc.config.Concurrency, 1))
+	for _, checkpoint := range checkpoints {
+		checkpoint := checkpoint
+		eg.Go(func() error {
+			seq, err := strconv.ParseInt(checkpoint.SequenceNumber, 10, 64)
+			if err != nil {
+				return err
+			}
+			certified, err := svc.fetchCertified(childCtx, seq)
+			if err != nil {
+				return err
+			}
+			committee, err := svc.committee(childCtx, certified.Summary.Epoch, seq)
+			if err != nil {
+				return err
+			}
+
+			if err := verifyCertified(checkpoint, certified, committee); err != nil {
+				mu.Lock()
+				failures[seq] = fmt.Errorf("%w: checkpoint %v: %v", setting.CheckpointCertificateErr, seq, err)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
+
+// verifyCertified checks checkpoint against the certified checkpoint, signed by committee
+func verifyCertified(checkpoint *sui_model.Checkpoint, certified *sui_cert.CertifiedCheckpoint, committee *sui_cert.Committee) error {
+	if err := committee.Verify(certified); err != nil {
+		return err
+	}
+
+	summary := certified.Summary
+	var previousDigest string
+	if summary.PreviousDigest != nil {
+		previousDigest = summary.PreviousDigest.String()
+	}
+	gasCost := checkpoint.EpochRollingGasCostSummary
+	for _, field := range []struct {
+		name      string
+		json      string
+		certified string
+	}{
+		{"sequence number", checkpoint.SequenceNumber, strconv.FormatUint(summary.SequenceNumber, 10)},
+		{"epoch", checkpoint.Epoch, strconv.FormatUint(summary.Epoch, 10)},
+		{"digest", checkpoint.Digest, certified.Digest().String()},
+		{"previous digest", checkpoint.PreviousDigest, previousDigest},
+		{"network total transactions", checkpoint.NetworkTotalTransactions, strconv.FormatUint(summary.NetworkTotalTransactions, 10)},
+		{"timestamp", checkpoint.TimestampMs, strconv.FormatUint(summary.TimestampMs, 10)},
+		{"computation cost", gasCost.ComputationCost, strconv.FormatUint(summary.EpochRollingGasCostSummary.ComputationCost, 10)},
+		{"storage cost", gasCost.StorageCost, strconv.FormatUint(summary.EpochRollingGasCostSummary.StorageCost, 10)},
+		{"storage rebate", gasCost.StorageRebate, strconv.FormatUint(summary.EpochRollingGasCostSummary.StorageRebate, 10)},
+		{"non refundable storage fee", gasCost.NonRefundableStorageFee, strconv.FormatUint(summary.EpochRollingGasCostSummary.NonRefundableStorageFee, 10)},
+	} {
+		if field.json != field.certified {
+			return fmt.Errorf("%v is %v, the certified checkpoint has %v", field.name, field.json, field.certified)
+		}
+	}
+
+	if digest := certified.ContentsDigest(); digest != summary.ContentDigest {
+		return fmt.Errorf("contents digest is %v, the certified summary has %v", digest, summary.ContentDigest)
+	}
+	txs := lo.Map(certified.Contents.Transactions, func(digests sui_cert.ExecutionDigests, _ int) string {
+		return digests.Transaction.String()
+	})
+	if len(txs) != len(checkpoint.Transactions) {
+		return fmt.Errorf("%v txs, the certified contents have %v", len(checkpoint.Transactions), len(txs))
+	}
+	for i, tx := range checkpoint.Transactions {
+		if tx != txs[i] {
+			return fmt.Errorf("tx %v is %v, the certified contents have %v", i, tx, txs[i])
+		}
+	}
+	return nil
+}
+
+// fetchCertified reads the certified checkpoint seq from the source
+func (svc *checkpointSignatureVerifier) fetchCertified(ctx context.Context, seq int64) (*sui_cert.CertifiedCheckpoint, error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	url := strings.ReplaceAll(svc.config.Url, "{seq}", strconv.FormatInt(seq, 10))
+	data, err := retry.DoWithData(
+		func() ([]byte, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return nil, retry.Unrecoverable(err)
+			}
+			req.Header.Set("Accept", "application/bcs")
+			resp, err := svc.client.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode == http.StatusNotFound {
+				// the source does not have the checkpoint yet, it is verified again when the checkpoint is retried
+				return nil, retry.Unrecoverable(fmt.Errorf("status %v", resp.Status))
+			}
+			if resp.StatusCode != http.StatusOK {
+				return nil, fmt.Errorf("status %v", resp.Status)
+			}
+			return io.ReadAll(resp.Body)
+		},
+		retry.Attempts(3),
+		retry.OnRetry(func(n uint, err error) {
+			logger.Warnf("retry %v fetching certified checkpoint %v: %v", n+1, seq, err)
+		}),
+		sui_rpc.RetryDelay(time.Second),
+		retry.Context(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch certified checkpoint %v: %v", seq, err)
+	}
+
+	var certified *sui_cert.CertifiedCheckpoint
+	if strings.HasSuffix(svc.config.Url, ".chk") {
+		certified, err = sui_cert.ParseCheckpointBlob(data)
+	} else {
+		certified, err = sui_cert.ParseCheckpointData(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("certified checkpoint %v: %v", seq, err)
+	}
+	if certified.Summary.SequenceNumber != uint64(seq) {
+		return nil, fmt.Errorf("certified checkpoint %v is checkpoint %v", seq, certified.Summary.SequenceNumber)
+	}
+	return certified, nil
+}
+
+// committee returns the committee of epoch. The committee of the trusted epoch is read from the rpc, the
+// next ones are walked to through the last checkpoint of each epoch, verified by the committee of that epoch.
+// Checkpoint seq is in epoch, it bounds the search of the end of the epochs before.
+func (svc *checkpointSignatureVerifier) committee(ctx context.Context, epoch uint64, seq int64) (*sui_cert.Committee, error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	if committee, ok := svc.committees[epoch]; ok {
+		return committee, nil
+	}
+	if epoch < svc.config.TrustedEpoch {
+		return nil, fmt.Errorf("checkpoint %v of epoch %v is before the trusted epoch %v", seq, epoch, svc.config.TrustedEpoch)
+	}
+
+	current, ok := svc.committees[svc.config.TrustedEpoch]
+	if !ok {
+		var err error
+		if current, err = svc.fetchCommittee(ctx, svc.config.TrustedEpoch); err != nil {
+			return nil, err
+		}
+		svc.committees[current.Epoch] = current
+		logger.Infof("trusted committee of epoch %v: %v validators", current.Epoch, len(current.Members))
+	}
+	for next, ok := svc.committees[current.Epoch+1]; ok; next, ok = svc.committees[next.Epoch+1] {
+		current = next
+	}
+
+	for current.Epoch < epoch {
+		end, err := svc.findEpochEnd(ctx, current.Epoch, seq)
+		if err != nil {
+			return nil, err
+		}
+		certified, err := svc.fetchCertified(ctx, end)
+		if err != nil {
+			return nil, err
+		}
+		next, err := current.NextCommittee(certified)
+		if err != nil {
+			return nil, fmt.Errorf("%w: end of epoch %v: %v", setting.CheckpointCertificateErr, current.Epoch, err)
+		}
+		svc.committees[next.Epoch] = next
+		svc.epochEnds[current.Epoch] = end
+		logger.Infof("committee of epoch %v: %v validators, from checkpoint %v", next.Epoch, len(next.Members), end)
+		current = next
+	}
+	return current, nil
+}
+
+// fetchCommittee reads the committee of epoch from the rpc
+func (svc *checkpointSignatureVerifier) fetchCommittee(ctx context.Context, epoch uint64) (*sui_cert.Committee, error) {
+	var resp struct {
+		Epoch      string      `json:"epoch"`
+		Validators [][2]string `json:"validators"`
+	}
+	if err := svc.pool.Call(ctx, 0, &resp, "suix_getCommitteeInfo", strconv.FormatUint(epoch, 10)); err != nil {
+		return nil, err
+	}
+	if resp.Epoch != strconv.FormatUint(epoch, 10) {
+		return nil, fmt.Errorf("committee of epoch %v answered for epoch %v", epoch, resp.Epoch)
+	}
+	var members = make([]sui_cert.Member, 0, len(resp.Validators))
+	for _, validator := range resp.Validators {
+		publicKey, err := base64.StdEncoding.DecodeString(validator[0])
+		if err != nil {
+			return nil, fmt.Errorf("committee of epoch %v: invalid public key %v", epoch, validator[0])
+		}
+		stake, err := strconv.ParseUint(validator[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("committee of epoch %v: invalid stake %v", epoch, validator[1])
+		}
+		members = append(members, sui_cert.Member{PublicKey: publicKey, Stake: stake})
+	}
+	return sui_cert.NewCommittee(epoch, members)
+}
+
+// findEpochEnd searches the last checkpoint of epoch before checkpoint seq of a later epoch. The epochs of the
+// checkpoints are read from the rpc, the checkpoint found is verified as the end of the epoch by the caller.
+func (svc *checkpointSignatureVerifier) findEpochEnd(ctx context.Context, epoch uint64, seq int64) (int64, error) {
+	// lo is in epoch or before, hi is after epoch
+	var lo, hi int64 = 0, seq
+	if end, ok := svc.epochEnds[epoch-1]; epoch > 0 && ok {
+		lo = end + 1
+	}
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		var checkpoint sui_model.Checkpoint
+		if err := svc.pool.Call(ctx, mid, &checkpoint, "getCheckpoint", strconv.FormatInt(mid, 10)); err != nil {
+			return 0, err
+		}
+		midEpoch, err := strconv.ParseUint(checkpoint.Epoch, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("checkpoint %v: invalid epoch %v", mid, checkpoint.Epoch)
+		}
+		if midEpoch <= epoch {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
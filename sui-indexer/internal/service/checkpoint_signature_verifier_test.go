@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sui_client "github.com/coming-chat/go-sui/v2/client"
+	"github.com/smartystreets/goconvey/convey"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/entity_dto/sui_model"
+	"feng-sui-core/internal/setting"
+	"feng-sui-core/pkg/sui_cert"
+	"feng-sui-core/pkg/sui_rpc"
+)
+
+// testCommittee is a committee with the secret keys of its members, in committee order
+type testCommittee struct {
+	committee  *sui_cert.Committee
+	secretKeys []*big.Int
+}
+
+func newTestCommittee(epoch uint64, seed int64, stakes ...uint64) *testCommittee {
+	var (
+		members   = make([]sui_cert.Member, 0, len(stakes))
+		secretKey = make(map[string]*big.Int, len(stakes))
+	)
+	for i, stake := range stakes {
+		key := big.NewInt(seed*1000 + int64(i) + 1)
+		publicKey := sui_cert.PublicKey(key)
+		members = append(members, sui_cert.Member{PublicKey: publicKey, Stake: stake})
+		secretKey[string(publicKey)] = key
+	}
+	committee, err := sui_cert.NewCommittee(epoch, members)
+	convey.So(err, convey.ShouldBeNil)
+	res := &testCommittee{committee: committee}
+	for _, member := range committee.Members {
+		res.secretKeys = append(res.secretKeys, secretKey[string(member.PublicKey)])
+	}
+	return res
+}
+
+// sign certifies checkpoint with the signatures of signers
+func (c *testCommittee) sign(checkpoint *sui_cert.CertifiedCheckpoint, signers ...uint32) {
+	var signatures = make([][]byte, 0, len(signers))
+	for _, signer := range signers {
+		signature, err := sui_cert.Sign(c.secretKeys[signer], checkpoint.SignedMessage())
+		convey.So(err, convey.ShouldBeNil)
+		signatures = append(signatures, signature)
+	}
+	aggregate, err := sui_cert.AggregateSignatures(signatures...)
+	convey.So(err, convey.ShouldBeNil)
+	checkpoint.SignInfo = &sui_cert.SignInfo{Epoch: checkpoint.Summary.Epoch, Signature: aggregate, Signers: signers}
+}
+
+// toJson returns the checkpoint as the json-rpc returns it
+func toJson(certified *sui_cert.CertifiedCheckpoint) *sui_model.Checkpoint {
+	summary := certified.Summary
+	checkpoint := &sui_model.Checkpoint{
+		Epoch:                    strconv.FormatUint(summary.Epoch, 10),
+		SequenceNumber:           strconv.FormatUint(summary.SequenceNumber, 10),
+		Digest:                   certified.Digest().String(),
+		TimestampMs:              strconv.FormatUint(summary.TimestampMs, 10),
+		NetworkTotalTransactions: strconv.FormatUint(summary.NetworkTotalTransactions, 10),
+		EpochRollingGasCostSummary: sui_model.GasCostSummary{
+			ComputationCost:         strconv.FormatUint(summary.EpochRollingGasCostSummary.ComputationCost, 10),
+			StorageCost:             strconv.FormatUint(summary.EpochRollingGasCostSummary.StorageCost, 10),
+			StorageRebate:           strconv.FormatUint(summary.EpochRollingGasCostSummary.StorageRebate, 10),
+			NonRefundableStorageFee: strconv.FormatUint(summary.EpochRollingGasCostSummary.NonRefundableStorageFee, 10),
+		},
+	}
+	if summary.PreviousDigest != nil {
+		checkpoint.PreviousDigest = summary.PreviousDigest.String()
+	}
+	for _, tx := range certified.Contents.Transactions {
+		checkpoint.Transactions = append(checkpoint.Transactions, tx.Transaction.String())
+	}
+	return checkpoint
+}
+
+// certifiedSource serves the checkpoint files of an archive
+type certifiedSource struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (s *certifiedSource) put(certified *sui_cert.CertifiedCheckpoint) {
+	data, err := certified.Marshal()
+	convey.So(err, convey.ShouldBeNil)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[fmt.Sprintf("/%v.chk", certified.Summary.SequenceNumber)] = append([]byte{1}, data...)
+}
+
+func (s *certifiedSource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, ok := s.files[r.URL.Path]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	_, _ = w.Write(data)
+}
+
+func TestCheckpointSignatureVerifier(t *testing.T) {
+	convey.Convey("TestCheckpointSignatureVerifier", t, func() {
+		ctx := context.Background()
+
+		committee5 := newTestCommittee(5, 1, 2500, 2500, 2500, 2500)
+		committee6 := newTestCommittee(6, 2, 5000, 3000, 2000)
+
+		// checkpoints 0..4 are in epoch 5, 4 is the end of the epoch. 5..9 are in epoch 6
+		var (
+			certified   = make([]*sui_cert.CertifiedCheckpoint, 0, 10)
+			checkpoints = make([]*sui_model.Checkpoint, 0, 10)
+			previous    *sui_cert.Digest
+		)
+		newCertified := func(seq uint64, previous *sui_cert.Digest, committee *testCommittee) *sui_cert.CertifiedCheckpoint {
+			contents := &sui_cert.CheckpointContents{
+				Transactions:   []sui_cert.ExecutionDigests{{Transaction: sui_cert.Digest{byte(seq), 1}, Effects: sui_cert.Digest{byte(seq), 2}}},
+				UserSignatures: [][][]byte{{[]byte("signature")}},
+			}
+			summary := &sui_cert.CheckpointSummary{
+				Epoch:                      committee.committee.Epoch,
+				SequenceNumber:             seq,
+				NetworkTotalTransactions:   seq + 1,
+				ContentDigest:              sui_cert.NewCertifiedCheckpoint(&sui_cert.CheckpointSummary{}, contents).ContentsDigest(),
+				PreviousDigest:             previous,
+				EpochRollingGasCostSummary: sui_cert.GasCostSummary{ComputationCost: seq * 10, StorageCost: 1, StorageRebate: 2},
+				TimestampMs:                1700000000000 + seq*250,
+				VersionSpecificData:        []byte{},
+			}
+			if seq == 4 {
+				summary.EndOfEpochData = &sui_cert.EndOfEpochData{NextEpochCommittee: committee6.committee.Members, NextEpochProtocolVersion: 42}
+			}
+			checkpoint := sui_cert.NewCertifiedCheckpoint(summary, contents)
+			committee.sign(checkpoint, 0, 1, 2)
+			return checkpoint
+		}
+		for seq := uint64(0); seq < 10; seq++ {
+			committee := committee5
+			if seq >= 5 {
+				committee = committee6
+			}
+			checkpoint := newCertified(seq, previous, committee)
+			digest := checkpoint.Digest()
+			previous = &digest
+			certified = append(certified, checkpoint)
+			checkpoints = append(checkpoints, toJson(checkpoint))
+		}
+
+		source := &certifiedSource{files: make(map[string][]byte)}
+		for _, checkpoint := range certified {
+			source.put(checkpoint)
+		}
+		sourceServer := httptest.NewServer(source)
+		defer sourceServer.Close()
+
+		// the rpc answers the trusted committee and the epochs of the checkpoints
+		handler, err := NewRpcReplayHandler(t.TempDir())
+		convey.So(err, convey.ShouldBeNil)
+		var validators = make([][2]string, 0)
+		for _, member := range committee5.committee.Members {
+			validators = append(validators, [2]string{base64.StdEncoding.EncodeToString(member.PublicKey), strconv.FormatUint(member.Stake, 10)})
+		}
+		committeeInfo, _ := json.Marshal(map[string]interface{}{"epoch": "5", "validators": validators})
+		convey.So(handler.(*rpcReplayHandler).add(&RpcFixture{Method: "suix_getCommitteeInfo", Params: json.RawMessage(`["5"]`), Result: committeeInfo}), convey.ShouldBeNil)
+		for _, checkpoint := range checkpoints {
+			data, _ := json.Marshal(checkpoint)
+			convey.So(handler.(*rpcReplayHandler).add(&RpcFixture{Method: "sui_getCheckpoint", Params: json.RawMessage(`["` + checkpoint.SequenceNumber + `"]`), Result: data}), convey.ShouldBeNil)
+		}
+		rpcServer := httptest.NewServer(handler)
+		defer rpcServer.Close()
+		client, err := sui_client.DialWithClient(rpcServer.URL, &http.Client{Timeout: 10 * time.Second})
+		convey.So(err, convey.ShouldBeNil)
+		pool := sui_rpc.NewPool([]string{rpcServer.URL}, map[string]sui_rpc.Client{rpcServer.URL: client}, sui_rpc.Config{})
+
+		newVerifier := func(trustedEpoch uint64) *checkpointSignatureVerifier {
+			return newCheckpointSignatureVerifier(pool, &http.Client{Timeout: 10 * time.Second}, CheckpointSourceConfig{
+				Url:          sourceServer.URL + "/{seq}.chk",
+				TrustedEpoch: trustedEpoch,
+				Concurrency:  4,
+			})
+		}
+
+		convey.Convey("Certified checkpoints pass across an epoch change", func() {
+			verifier := newVerifier(5)
+			failures, err := verifier.VerifySignatures(ctx, checkpoints[1], checkpoints[4], checkpoints[7], checkpoints[9])
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(failures, convey.ShouldBeEmpty)
+			convey.So(verifier.committees, convey.ShouldContainKey, uint64(6))
+			convey.So(verifier.committees[6].Members, convey.ShouldResemble, committee6.committee.Members)
+			convey.So(verifier.epochEnds[5], convey.ShouldEqual, 4)
+		})
+
+		convey.Convey("A checkpoint differing from its certified checkpoint fails", func() {
+			checkpoint := *checkpoints[2]
+			checkpoint.NetworkTotalTransactions = "100"
+			failures, err := newVerifier(5).VerifySignatures(ctx, &checkpoint, checkpoints[3])
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(failures, convey.ShouldHaveLength, 1)
+			convey.So(errors.Is(failures[2], setting.CheckpointCertificateErr), convey.ShouldBeTrue)
+			convey.So(failures[2].Error(), convey.ShouldContainSubstring, "network total transactions")
+
+			checkpoint = *checkpoints[2]
+			checkpoint.Digest = checkpoints[3].Digest
+			failures, err = newVerifier(5).VerifySignatures(ctx, &checkpoint)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(failures[2].Error(), convey.ShouldContainSubstring, "digest")
+		})
+
+		convey.Convey("A tampered summary fails", func() {
+			tampered := newCertified(3, certified[3].Summary.PreviousDigest, committee5)
+			tampered.Summary.TimestampMs++
+			tampered.SummaryBcs = tampered.Summary.Marshal()
+			source.put(tampered)
+
+			failures, err := newVerifier(5).VerifySignatures(ctx, toJson(tampered))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(failures[3].Error(), convey.ShouldContainSubstring, "invalid aggregate signature")
+		})
+
+		convey.Convey("A missing signer fails", func() {
+			tampered := newCertified(3, certified[3].Summary.PreviousDigest, committee5)
+			committee5.sign(tampered, 0, 1)
+			source.put(tampered)
+
+			failures, err := newVerifier(5).VerifySignatures(ctx, checkpoints[3])
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(failures[3].Error(), convey.ShouldContainSubstring, "5000 of the 6667 stake")
+		})
+
+		convey.Convey("A checkpoint signed by the wrong committee fails", func() {
+			tampered := newCertified(8, certified[8].Summary.PreviousDigest, committee6)
+			committee5.sign(tampered, 0, 1, 2)
+			source.put(tampered)
+
+			failures, err := newVerifier(5).VerifySignatures(ctx, checkpoints[7], checkpoints[8])
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(failures, convey.ShouldHaveLength, 1)
+			convey.So(failures[8].Error(), convey.ShouldContainSubstring, "invalid aggregate signature")
+		})
+
+		convey.Convey("An end of epoch signed by the wrong committee stops the walk", func() {
+			tampered := newCertified(4, certified[4].Summary.PreviousDigest, committee5)
+			committee6.sign(tampered, 0, 1, 2)
+			source.put(tampered)
+
+			_, err := newVerifier(5).VerifySignatures(ctx, checkpoints[7])
+			convey.So(errors.Is(err, setting.CheckpointCertificateErr), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("The txs must be the certified contents", func() {
+			checkpoint := *checkpoints[6]
+			checkpoint.Transactions = []string{checkpoints[5].Transactions[0]}
+			failures, err := newVerifier(5).VerifySignatures(ctx, &checkpoint)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(failures[6].Error(), convey.ShouldContainSubstring, "tx 0")
+
+			checkpoint.Transactions = append(checkpoints[6].Transactions, checkpoints[5].Transactions[0])
+			failures, err = newVerifier(5).VerifySignatures(ctx, &checkpoint)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(failures[6].Error(), convey.ShouldContainSubstring, "2 txs")
+
+			// contents which are not the ones of the summary
+			tampered := newCertified(6, certified[6].Summary.PreviousDigest, committee6)
+			tampered.Contents.Transactions[0].Transaction = sui_cert.Digest{42}
+			tampered.ContentsBcs = tampered.Contents.Marshal()
+			source.put(tampered)
+			failures, err = newVerifier(5).VerifySignatures(ctx, checkpoints[6])
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(failures[6].Error(), convey.ShouldContainSubstring, "contents digest")
+		})
+
+		convey.Convey("Checkpoints before the trusted epoch cannot be verified", func() {
+			_, err := newVerifier(6).VerifySignatures(ctx, checkpoints[2])
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(err.Error(), convey.ShouldContainSubstring, "before the trusted epoch")
+		})
+
+		convey.Convey("Missing certified checkpoints are an error, not a failure", func() {
+			checkpoint := *checkpoints[9]
+			checkpoint.SequenceNumber = "10"
+			_, err := newVerifier(5).VerifySignatures(ctx, &checkpoint)
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(strings.Contains(err.Error(), "404"), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("The verification is off by default", func() {
+			saved := conf.Config
+			defer func() {
+				conf.Config = saved
+			}()
+			conf.Config.CheckpointVerifySignatures = "no"
+			verifier, err := NewCheckpointSignatureVerifier(pool)
+			convey.So(err, convey.ShouldBeNil)
+			checkpoint := *checkpoints[2]
+			checkpoint.Digest = "tampered"
+			failures, err := verifier.VerifySignatures(ctx, &checkpoint)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(failures, convey.ShouldBeEmpty)
+		})
+	})
+}
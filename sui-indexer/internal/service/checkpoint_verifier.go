@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/getnimbus/ultrago/u_logger"
+	"github.com/samber/lo"
+
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/entity_dto/sui_model"
+	"feng-sui-core/internal/repo"
+	"feng-sui-core/internal/setting"
+)
+
+func NewCheckpointVerifier(
+	blockStatusRepo repo.BlockStatusRepo,
+	checkpointMismatchRepo repo.CheckpointMismatchRepo,
+) CheckpointVerifier {
+	return &checkpointVerifier{
+		blockStatusRepo:        blockStatusRepo,
+		checkpointMismatchRepo: checkpointMismatchRepo,
+	}
+}
+
+type CheckpointVerifier interface {
+	// VerifyChain checks each checkpoint against checkpoint N-1 and N+1, which are either in checkpoints
+	// or the last and first block of the stored neighbour ranges.
+	// Neighbour ranges which are not DONE yet are skipped, they will check these checkpoints when they are done.
+	// It returns the errors of the checkpoints breaking the chain, keyed by sequence number.
+	VerifyChain(ctx context.Context, checkpoints ...*sui_model.Checkpoint) (map[int64]error, error)
+}
+
+type checkpointVerifier struct {
+	blockStatusRepo        repo.BlockStatusRepo
+	checkpointMismatchRepo repo.CheckpointMismatchRepo
+}
+
+func (svc *checkpointVerifier) VerifyChain(ctx context.Context, checkpoints ...*sui_model.Checkpoint) (map[int64]error, error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	var failures = make(map[int64]error)
+	if len(checkpoints) == 0 {
+		return failures, nil
+	}
+
+	var seqs = make([]int64, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		seq, err := strconv.ParseInt(checkpoint.SequenceNumber, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		seqs = append(seqs, seq)
+	}
+
+	// lock the ranges of the checkpoints and their neighbours so that two adjacent ranges cannot be verified at the same time
+	blockStatuses, err := svc.blockStatusRepo.GetList(ctx,
+		svc.blockStatusRepo.S().LockingForUpdate(),
+		svc.blockStatusRepo.S().ColumnEqual("chain", "SUI"),
+		svc.blockStatusRepo.S().FilterOverlap(lo.Min(seqs)-1, lo.Max(seqs)+1),
+		svc.blockStatusRepo.S().SortBy("block_number", "ASC"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches := findChainMismatches(checkpoints, blockStatuses)
+	if len(mismatches) == 0 {
+		return failures, nil
+	}
+
+	// record mismatches for investigation
+	if err := svc.checkpointMismatchRepo.CreateMany(ctx, mismatches...); err != nil {
+		logger.Errorf("failed to save checkpoint mismatches: %v", err)
+		return nil, err
+	}
+	for _, mismatch := range mismatches {
+		if _, ok := failures[mismatch.BlockNumber]; ok {
+			continue
+		}
+		failures[mismatch.BlockNumber] = fmt.Errorf("%w: checkpoint %v with digest %v and previous digest %v does not match block %v",
+			setting.CheckpointMismatchErr, mismatch.BlockNumber, mismatch.Digest, mismatch.PreviousDigest, mismatch.NeighbourBlockNumber)
+	}
+	return failures, nil
+}
+
+func findChainMismatches(checkpoints []*sui_model.Checkpoint, blockStatuses []*entity.BlockStatus) []*entity.CheckpointMismatch {
+	var (
+		mismatches   = make([]*entity.CheckpointMismatch, 0)
+		checkpointBy = make(map[int64]*sui_model.Checkpoint, len(checkpoints))
+	)
+	for _, checkpoint := range checkpoints {
+		seq, _ := strconv.ParseInt(checkpoint.SequenceNumber, 10, 64)
+		checkpointBy[seq] = checkpoint
+	}
+
+	for seq, checkpoint := range checkpointBy {
+		newMismatch := func(neighbourBlockNumber int64, neighbourDigest string, neighbourPreviousDigest string) *entity.CheckpointMismatch {
+			return &entity.CheckpointMismatch{
+				Chain:                   "SUI",
+				BlockNumber:             seq,
+				Digest:                  checkpoint.Digest,
+				PreviousDigest:          checkpoint.PreviousDigest,
+				NeighbourBlockNumber:    neighbourBlockNumber,
+				NeighbourDigest:         neighbourDigest,
+				NeighbourPreviousDigest: neighbourPreviousDigest,
+			}
+		}
+
+		// N-1 is verified in memory, N+1 is verified when N+1 checks its own N-1
+		previous, hasPrevious := checkpointBy[seq-1]
+		if hasPrevious && previous.Digest != checkpoint.PreviousDigest {
+			mismatches = append(mismatches, newMismatch(seq-1, previous.Digest, previous.PreviousDigest))
+		}
+		_, hasNext := checkpointBy[seq+1]
+
+		for _, blockStatus := range blockStatuses {
+			if blockStatus.Status != entity.BlockStatus_DONE || blockStatus.Digest == "" {
+				continue
+			}
+
+			switch {
+			case blockStatus.ToBlockNumber == seq-1 && !hasPrevious:
+				if blockStatus.Digest != checkpoint.PreviousDigest {
+					mismatches = append(mismatches, newMismatch(blockStatus.ToBlockNumber, blockStatus.Digest, blockStatus.PreviousDigest))
+				}
+			case blockStatus.BlockNumber == seq+1 && !hasNext:
+				if blockStatus.PreviousDigest != checkpoint.Digest {
+					mismatches = append(mismatches, newMismatch(blockStatus.BlockNumber, blockStatus.Digest, blockStatus.PreviousDigest))
+				}
+			}
+		}
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].BlockNumber < mismatches[j].BlockNumber
+	})
+	return mismatches
+}
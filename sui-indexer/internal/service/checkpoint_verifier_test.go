@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/entity_dto/sui_model"
+)
+
+func TestFindChainMismatches(t *testing.T) {
+	convey.Convey("TestFindChainMismatches", t, func() {
+		checkpoint := &sui_model.Checkpoint{
+			SequenceNumber: "100",
+			Digest:         "digest-100",
+			PreviousDigest: "digest-99",
+		}
+
+		convey.Convey("Neighbours are linked", func() {
+			mismatches := findChainMismatches([]*sui_model.Checkpoint{checkpoint}, []*entity.BlockStatus{
+				{Chain: "SUI", BlockNumber: 90, ToBlockNumber: 99, Status: entity.BlockStatus_DONE, Digest: "digest-99", PreviousDigest: "digest-89"},
+				{Chain: "SUI", BlockNumber: 100, ToBlockNumber: 100, Status: entity.BlockStatus_PROCESSING},
+				{Chain: "SUI", BlockNumber: 101, ToBlockNumber: 110, Status: entity.BlockStatus_DONE, Digest: "digest-110", PreviousDigest: "digest-100"},
+			})
+			convey.So(mismatches, convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("Neighbours are not done", func() {
+			mismatches := findChainMismatches([]*sui_model.Checkpoint{checkpoint}, []*entity.BlockStatus{
+				{Chain: "SUI", BlockNumber: 99, ToBlockNumber: 99, Status: entity.BlockStatus_FAIL, Digest: "other"},
+				{Chain: "SUI", BlockNumber: 101, ToBlockNumber: 101, Status: entity.BlockStatus_DONE},
+			})
+			convey.So(mismatches, convey.ShouldBeEmpty)
+		})
+
+		convey.Convey("Previous digest is not equal to digest of N-1", func() {
+			mismatches := findChainMismatches([]*sui_model.Checkpoint{checkpoint}, []*entity.BlockStatus{
+				{Chain: "SUI", BlockNumber: 99, ToBlockNumber: 99, Status: entity.BlockStatus_DONE, Digest: "forked-99"},
+			})
+			convey.So(mismatches, convey.ShouldHaveLength, 1)
+			convey.So(mismatches[0].BlockNumber, convey.ShouldEqual, 100)
+			convey.So(mismatches[0].NeighbourBlockNumber, convey.ShouldEqual, 99)
+			convey.So(mismatches[0].NeighbourDigest, convey.ShouldEqual, "forked-99")
+		})
+
+		convey.Convey("Digest is not equal to previous digest of N+1", func() {
+			mismatches := findChainMismatches([]*sui_model.Checkpoint{checkpoint}, []*entity.BlockStatus{
+				{Chain: "SUI", BlockNumber: 101, ToBlockNumber: 101, Status: entity.BlockStatus_DONE, Digest: "digest-101", PreviousDigest: "forked-100"},
+			})
+			convey.So(mismatches, convey.ShouldHaveLength, 1)
+			convey.So(mismatches[0].NeighbourBlockNumber, convey.ShouldEqual, 101)
+		})
+
+		convey.Convey("Checkpoints of the same range are not linked", func() {
+			next := &sui_model.Checkpoint{
+				SequenceNumber: "101",
+				Digest:         "digest-101",
+				PreviousDigest: "forked-100",
+			}
+			mismatches := findChainMismatches([]*sui_model.Checkpoint{checkpoint, next}, []*entity.BlockStatus{
+				{Chain: "SUI", BlockNumber: 100, ToBlockNumber: 101, Status: entity.BlockStatus_PROCESSING},
+			})
+			convey.So(mismatches, convey.ShouldHaveLength, 1)
+			convey.So(mismatches[0].BlockNumber, convey.ShouldEqual, 101)
+			convey.So(mismatches[0].NeighbourBlockNumber, convey.ShouldEqual, 100)
+		})
+	})
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/getnimbus/ultrago/u_logger"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/entity_dto/sui_model"
+	"feng-sui-core/internal/setting"
+)
+
+// NewLocalBlockStore tracks the block ranges in the json file path instead of Postgres,
+// ranges left PROCESSING by a previous run are marked FAIL to be processed again.
+func NewLocalBlockStore(path string) (LocalBlockStore, error) {
+	store := &localBlockStore{
+		path:   path,
+		ranges: make([]*entity.BlockStatus, 0),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.ranges); err != nil {
+		return nil, fmt.Errorf("invalid block store %v: %v", path, err)
+	}
+	for _, blockStatus := range store.ranges {
+		if blockStatus.Status == entity.BlockStatus_PROCESSING {
+			blockStatus.Status = entity.BlockStatus_FAIL
+			blockStatus.LastError = "interrupted"
+			blockStatus.Owner = ""
+			blockStatus.LeaseExpiresAt = nil
+		}
+	}
+	return store, store.save()
+}
+
+// LocalBlockStore replaces Postgres for a single worker process: it claims, completes and verifies the block ranges
+// and ExecTx runs the functions one at a time.
+type LocalBlockStore interface {
+	BaseService
+	BlockRangeService
+	CheckpointVerifier
+	// AddRange adds the blocks from..to which are not tracked yet
+	AddRange(ctx context.Context, blockType int, from int64, to int64) error
+	// Remaining returns the number of blocks which are neither DONE nor DEAD
+	Remaining(ctx context.Context) int64
+}
+
+type localBlockStore struct {
+	txMu   sync.Mutex
+	mu     sync.Mutex
+	path   string
+	ranges []*entity.BlockStatus
+}
+
+func (s *localBlockStore) ExecTx(ctx context.Context, f func(funcCtx context.Context) error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	return f(ctx)
+}
+
+func (s *localBlockStore) AddRange(ctx context.Context, blockType int, from int64, to int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// add the gaps of from..to between the tracked ranges
+	next := from
+	for _, blockStatus := range s.ranges {
+		if blockStatus.ToBlockNumber < next {
+			continue
+		}
+		if blockStatus.BlockNumber > to {
+			break
+		}
+		if blockStatus.BlockNumber > next {
+			s.ranges = append(s.ranges, newLocalBlockStatus(blockType, next, blockStatus.BlockNumber-1))
+		}
+		next = blockStatus.ToBlockNumber + 1
+	}
+	if next <= to {
+		s.ranges = append(s.ranges, newLocalBlockStatus(blockType, next, to))
+	}
+	return s.save()
+}
+
+func (s *localBlockStore) Remaining(ctx context.Context) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return lo.SumBy(s.ranges, func(blockStatus *entity.BlockStatus) int64 {
+		if blockStatus.Status == entity.BlockStatus_DONE || blockStatus.Status == entity.BlockStatus_DEAD {
+			return 0
+		}
+		return blockStatus.Size()
+	})
+}
+
+func (s *localBlockStore) Claim(ctx context.Context, blockType int, owner string, maxSize int64) (*entity.BlockStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blockStatus, ok := lo.Find(s.ranges, func(item *entity.BlockStatus) bool {
+		return item.Type == blockType && (item.Status == entity.BlockStatus_NOT_READY || item.Status == entity.BlockStatus_FAIL)
+	})
+	if !ok {
+		return nil, nil
+	}
+
+	if end := blockStatus.ClaimEnd(maxSize); end < blockStatus.ToBlockNumber {
+		// leave the remaining blocks for the next claims
+		remaining := *blockStatus
+		remaining.ID = uuid.NewString()
+		remaining.BlockNumber = end + 1
+		s.ranges = append(s.ranges, &remaining)
+		blockStatus.ToBlockNumber = remaining.BlockNumber - 1
+	}
+
+	now := time.Now()
+	blockStatus.Status = entity.BlockStatus_PROCESSING
+	blockStatus.Owner = owner
+	blockStatus.HeartbeatAt = &now
+	blockStatus.UpdatedAt = now
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+
+	claimed := *blockStatus
+	return &claimed, nil
+}
+
+// KeepAlive waits for ctx, the leases of one process never expire
+func (s *localBlockStore) KeepAlive(ctx context.Context, blockStatus *entity.BlockStatus, owner string) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (s *localBlockStore) Complete(ctx context.Context, blockStatus *entity.BlockStatus, owner string, results []*entity.BlockResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, idx, ok := lo.FindIndexOf(s.ranges, func(item *entity.BlockStatus) bool {
+		return item.ID == blockStatus.ID && item.Owner == owner && item.Status == entity.BlockStatus_PROCESSING
+	})
+	if !ok {
+		return setting.LeaseLostErr
+	}
+
+	ranges := splitRange(blockStatus, results, conf.Config.MaxBlockAttempts)
+	for i, item := range ranges {
+		if i > 0 {
+			item.ID = uuid.NewString()
+		}
+		item.Owner = ""
+		item.HeartbeatAt = nil
+		item.UpdatedAt = time.Now()
+	}
+	s.ranges = append(s.ranges[:idx], append(ranges, s.ranges[idx+1:]...)...)
+	return s.save()
+}
+
+func (s *localBlockStore) Fail(ctx context.Context, blockStatus *entity.BlockStatus, cause error) error {
+	if blockStatus == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, item := range s.ranges {
+		if item.ID != blockStatus.ID {
+			continue
+		}
+		item.Status = entity.BlockStatus_FAIL
+		item.Attempts++
+		item.LastError = cause.Error()
+		item.Owner = ""
+		item.UpdatedAt = time.Now()
+		if item.Attempts >= conf.Config.MaxBlockAttempts {
+			item.Status = entity.BlockStatus_DEAD
+		}
+	}
+	return s.save()
+}
+
+func (s *localBlockStore) VerifyChain(ctx context.Context, checkpoints ...*sui_model.Checkpoint) (map[int64]error, error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	var failures = make(map[int64]error)
+	if len(checkpoints) == 0 {
+		return failures, nil
+	}
+
+	var seqs = make([]int64, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		seq, err := strconv.ParseInt(checkpoint.SequenceNumber, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		seqs = append(seqs, seq)
+	}
+
+	s.mu.Lock()
+	var (
+		from          = lo.Min(seqs) - 1
+		to            = lo.Max(seqs) + 1
+		blockStatuses = make([]*entity.BlockStatus, 0)
+	)
+	for _, blockStatus := range s.ranges {
+		if blockStatus.BlockNumber <= to && blockStatus.ToBlockNumber >= from {
+			item := *blockStatus
+			blockStatuses = append(blockStatuses, &item)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, mismatch := range findChainMismatches(checkpoints, blockStatuses) {
+		if _, ok := failures[mismatch.BlockNumber]; ok {
+			continue
+		}
+		failures[mismatch.BlockNumber] = fmt.Errorf("%w: checkpoint %v with digest %v and previous digest %v does not match block %v",
+			setting.CheckpointMismatchErr, mismatch.BlockNumber, mismatch.Digest, mismatch.PreviousDigest, mismatch.NeighbourBlockNumber)
+		logger.Warnf("%v", failures[mismatch.BlockNumber])
+	}
+	return failures, nil
+}
+
+// save writes the ranges to a temp file then renames it, so the file is never partially written
+func (s *localBlockStore) save() error {
+	sort.Slice(s.ranges, func(i, j int) bool {
+		return s.ranges[i].BlockNumber < s.ranges[j].BlockNumber
+	})
+
+	data, err := json.MarshalIndent(s.ranges, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func newLocalBlockStatus(blockType int, from int64, to int64) *entity.BlockStatus {
+	now := time.Now()
+	return &entity.BlockStatus{
+		Base: entity.Base{
+			ID:        uuid.NewString(),
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		Chain:         "SUI",
+		BlockNumber:   from,
+		ToBlockNumber: to,
+		Status:        entity.BlockStatus_NOT_READY,
+		Type:          blockType,
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/entity_dto/sui_model"
+)
+
+func TestLocalBlockStore(t *testing.T) {
+	convey.Convey("TestLocalBlockStore", t, func() {
+		var (
+			ctx  = context.Background()
+			path = filepath.Join(t.TempDir(), "block_status.json")
+		)
+		store, err := NewLocalBlockStore(path)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(store.AddRange(ctx, entity.BlockStatusType_REALTIME, 100, 109), convey.ShouldBeNil)
+		convey.So(store.Remaining(ctx), convey.ShouldEqual, 10)
+
+		done := func(blockNumber int64) *entity.BlockResult {
+			return &entity.BlockResult{
+				BlockNumber:    blockNumber,
+				Digest:         fmt.Sprintf("digest-%v", blockNumber),
+				PreviousDigest: fmt.Sprintf("digest-%v", blockNumber-1),
+			}
+		}
+
+		convey.Convey("Claimed ranges are split and completed", func() {
+			blockStatus, err := store.Claim(ctx, entity.BlockStatusType_REALTIME, "owner", 5)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(blockStatus.Range(), convey.ShouldResemble, &entity.BlockRange{From: 100, To: 104})
+
+			convey.So(store.Complete(ctx, blockStatus, "owner", []*entity.BlockResult{
+				done(100), done(101), {BlockNumber: 102, Err: errors.New("boom")}, done(103), done(104),
+			}), convey.ShouldBeNil)
+			convey.So(store.Remaining(ctx), convey.ShouldEqual, 6)
+			convey.So(store.Complete(ctx, blockStatus, "owner", nil), convey.ShouldNotBeNil)
+
+			// the failed block is claimed again before the remaining blocks
+			blockStatus, err = store.Claim(ctx, entity.BlockStatusType_REALTIME, "owner", 5)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(blockStatus.Range(), convey.ShouldResemble, &entity.BlockRange{From: 102, To: 102})
+			convey.So(blockStatus.Attempts, convey.ShouldEqual, 1)
+
+			// claims stop at the multiples of their size
+			convey.So(store.Complete(ctx, blockStatus, "owner", []*entity.BlockResult{done(102)}), convey.ShouldBeNil)
+			convey.So(store.AddRange(ctx, entity.BlockStatusType_REALTIME, 110, 113), convey.ShouldBeNil)
+			blockStatus, err = store.Claim(ctx, entity.BlockStatusType_REALTIME, "owner", 4)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(blockStatus.Range(), convey.ShouldResemble, &entity.BlockRange{From: 105, To: 107})
+		})
+
+		convey.Convey("Checkpoints are verified against the done neighbours", func() {
+			blockStatus, _ := store.Claim(ctx, entity.BlockStatusType_REALTIME, "owner", 5)
+			convey.So(store.Complete(ctx, blockStatus, "owner", []*entity.BlockResult{
+				done(100), done(101), done(102), done(103), done(104),
+			}), convey.ShouldBeNil)
+
+			failures, err := store.VerifyChain(ctx, &sui_model.Checkpoint{
+				SequenceNumber: "105",
+				Digest:         "digest-105",
+				PreviousDigest: "forked-104",
+			})
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(failures, convey.ShouldContainKey, int64(105))
+		})
+
+		convey.Convey("Ranges left processing are failed when reopened", func() {
+			_, err := store.Claim(ctx, entity.BlockStatusType_REALTIME, "owner", 5)
+			convey.So(err, convey.ShouldBeNil)
+
+			reopened, err := NewLocalBlockStore(path)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(reopened.Remaining(ctx), convey.ShouldEqual, 10)
+			convey.So(reopened.AddRange(ctx, entity.BlockStatusType_REALTIME, 105, 112), convey.ShouldBeNil)
+			convey.So(reopened.Remaining(ctx), convey.ShouldEqual, 13)
+
+			blockStatus, err := reopened.Claim(ctx, entity.BlockStatusType_REALTIME, "owner", 5)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(blockStatus.Range(), convey.ShouldResemble, &entity.BlockRange{From: 100, To: 104})
+			convey.So(blockStatus.LastError, convey.ShouldEqual, "interrupted")
+		})
+	})
+}
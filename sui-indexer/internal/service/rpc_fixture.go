@@ -0,0 +1,360 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/samber/lo"
+)
+
+// RpcFixture is a json-rpc call recorded by NewRpcRecorder
+type RpcFixture struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+}
+
+type rpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcFixturePath returns where the call of method with params is recorded in dir
+func rpcFixturePath(dir string, method string, params json.RawMessage) string {
+	sum := sha1.Sum(canonicalJson(params))
+	return filepath.Join(dir, method, hex.EncodeToString(sum[:])+".json")
+}
+
+// canonicalJson re-encodes data so that equal values get the same bytes
+func canonicalJson(data json.RawMessage) []byte {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return data
+	}
+	res, err := json.Marshal(v)
+	if err != nil {
+		return data
+	}
+	return res
+}
+
+// NewRpcRecorder returns a transport calling next which saves the successful json-rpc calls to dir,
+// as <dir>/<method>/<hash of the params>.json
+func NewRpcRecorder(next http.RoundTripper, dir string) http.RoundTripper {
+	return &rpcRecorder{
+		next: next,
+		dir:  dir,
+	}
+}
+
+type rpcRecorder struct {
+	next http.RoundTripper
+	dir  string
+}
+
+func (r *rpcRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	var (
+		rpcReq  rpcRequest
+		rpcResp rpcResponse
+	)
+	if json.Unmarshal(body, &rpcReq) != nil || json.Unmarshal(respBody, &rpcResp) != nil ||
+		rpcResp.Error != nil || len(rpcResp.Result) == 0 {
+		// batches and failed calls are not recorded
+		return resp, nil
+	}
+	if err := r.save(&RpcFixture{
+		Method: rpcReq.Method,
+		Params: rpcReq.Params,
+		Result: rpcResp.Result,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to record %v: %v", rpcReq.Method, err)
+	}
+	return resp, nil
+}
+
+func (r *rpcRecorder) save(fixture *RpcFixture) error {
+	path := rpcFixturePath(r.dir, fixture.Method, fixture.Params)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write then rename so that concurrent calls never leave a partial fixture
+	tmp := fmt.Sprintf("%v.%v.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// NewRpcReplayHandler serves json-rpc calls from the fixtures recorded in dir. The recorded calls are
+// answered as recorded, besides sui_getCheckpoint, sui_getCheckpoints, sui_multiGetTransactionBlocks and
+// sui_getLatestCheckpointSequenceNumber are answered from every checkpoint and tx found in the fixtures.
+func NewRpcReplayHandler(dir string) (http.Handler, error) {
+	h := &rpcReplayHandler{
+		calls:       make(map[string]json.RawMessage),
+		checkpoints: make(map[int64]json.RawMessage),
+		digests:     make(map[string]int64),
+		txs:         make(map[string]json.RawMessage),
+	}
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var fixture RpcFixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return fmt.Errorf("invalid fixture %v: %v", path, err)
+		}
+		return h.add(&fixture)
+	}); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+type rpcReplayHandler struct {
+	mu          sync.RWMutex
+	calls       map[string]json.RawMessage
+	checkpoints map[int64]json.RawMessage
+	digests     map[string]int64
+	txs         map[string]json.RawMessage
+	latest      int64
+}
+
+func (h *rpcReplayHandler) add(fixture *RpcFixture) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.calls[fixture.Method+string(canonicalJson(fixture.Params))] = fixture.Result
+
+	switch fixture.Method {
+	case "sui_getCheckpoint":
+		return h.addCheckpoint(fixture.Result)
+	case "sui_getCheckpoints":
+		var page struct {
+			Data []json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(fixture.Result, &page); err != nil {
+			return err
+		}
+		for _, checkpoint := range page.Data {
+			if err := h.addCheckpoint(checkpoint); err != nil {
+				return err
+			}
+		}
+	case "sui_multiGetTransactionBlocks":
+		var txs []json.RawMessage
+		if err := json.Unmarshal(fixture.Result, &txs); err != nil {
+			return err
+		}
+		for _, tx := range txs {
+			var item struct {
+				Digest string `json:"digest"`
+			}
+			if err := json.Unmarshal(tx, &item); err != nil {
+				return err
+			}
+			h.txs[item.Digest] = tx
+		}
+	case "sui_getLatestCheckpointSequenceNumber":
+		var resp string
+		if err := json.Unmarshal(fixture.Result, &resp); err != nil {
+			return err
+		}
+		seq, err := strconv.ParseInt(resp, 10, 64)
+		if err != nil {
+			return err
+		}
+		h.latest = max(h.latest, seq)
+	}
+	return nil
+}
+
+// addCheckpoint indexes a checkpoint by sequence number and digest, h.mu must be held
+func (h *rpcReplayHandler) addCheckpoint(data json.RawMessage) error {
+	var checkpoint struct {
+		SequenceNumber string `json:"sequenceNumber"`
+		Digest         string `json:"digest"`
+	}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return err
+	}
+	seq, err := strconv.ParseInt(checkpoint.SequenceNumber, 10, 64)
+	if err != nil {
+		return err
+	}
+	h.checkpoints[seq] = data
+	h.digests[checkpoint.Digest] = seq
+	h.latest = max(h.latest, seq)
+	return nil
+}
+
+func (h *rpcReplayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req rpcRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid json-rpc request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	resp := &rpcResponse{
+		Version: "2.0",
+		Id:      req.Id,
+	}
+	result, err := h.call(req.Method, req.Params)
+	if err != nil {
+		resp.Error = err
+	} else {
+		resp.Result = result
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (h *rpcReplayHandler) call(method string, params json.RawMessage) (json.RawMessage, *rpcError) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if result, ok := h.calls[method+string(canonicalJson(params))]; ok {
+		return result, nil
+	}
+
+	var args []json.RawMessage
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, &rpcError{Code: -32602, Message: fmt.Sprintf("invalid params: %v", err)}
+		}
+	}
+	arg := func(idx int, v interface{}) bool {
+		return idx < len(args) && json.Unmarshal(args[idx], v) == nil
+	}
+
+	switch method {
+	case "sui_getLatestCheckpointSequenceNumber":
+		return json.RawMessage(strconv.Quote(strconv.FormatInt(h.latest, 10))), nil
+	case "sui_getCheckpoint":
+		var id string
+		if !arg(0, &id) {
+			return nil, &rpcError{Code: -32602, Message: "invalid checkpoint id"}
+		}
+		seq, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			var ok bool
+			if seq, ok = h.digests[id]; !ok {
+				return nil, &rpcError{Code: -32602, Message: fmt.Sprintf("checkpoint %v not found in the fixtures", id)}
+			}
+		}
+		checkpoint, ok := h.checkpoints[seq]
+		if !ok {
+			return nil, &rpcError{Code: -32602, Message: fmt.Sprintf("checkpoint %v not found in the fixtures", id)}
+		}
+		return checkpoint, nil
+	case "sui_getCheckpoints":
+		var (
+			cursor     *string
+			limit      int64 = 100
+			descending bool
+		)
+		_ = arg(0, &cursor)
+		_ = arg(1, &limit)
+		_ = arg(2, &descending)
+		if descending {
+			return nil, &rpcError{Code: -32602, Message: "descending pages are not replayed"}
+		}
+		var from int64
+		if cursor != nil {
+			seq, err := strconv.ParseInt(*cursor, 10, 64)
+			if err != nil {
+				return nil, &rpcError{Code: -32602, Message: fmt.Sprintf("invalid cursor %v", *cursor)}
+			}
+			from = seq + 1
+		}
+		// the page stops at the first checkpoint missing from the fixtures
+		var data = make([]json.RawMessage, 0, limit)
+		for seq := from; seq < from+limit; seq++ {
+			checkpoint, ok := h.checkpoints[seq]
+			if !ok {
+				break
+			}
+			data = append(data, checkpoint)
+		}
+		if len(data) == 0 {
+			return nil, &rpcError{Code: -32602, Message: fmt.Sprintf("checkpoint %v not found in the fixtures", from)}
+		}
+		page, _ := json.Marshal(map[string]interface{}{
+			"data":        data,
+			"nextCursor":  strconv.FormatInt(from+int64(len(data))-1, 10),
+			"hasNextPage": h.checkpoints[from+int64(len(data))] != nil,
+		})
+		return page, nil
+	case "sui_multiGetTransactionBlocks":
+		var digests []string
+		if !arg(0, &digests) {
+			return nil, &rpcError{Code: -32602, Message: "invalid digests"}
+		}
+		if missing := lo.Filter(digests, func(digest string, _ int) bool {
+			return h.txs[digest] == nil
+		}); len(missing) > 0 {
+			return nil, &rpcError{Code: -32602, Message: fmt.Sprintf("txs %v not found in the fixtures", strings.Join(missing, ","))}
+		}
+		txs, _ := json.Marshal(lo.Map(digests, func(digest string, _ int) json.RawMessage {
+			return h.txs[digest]
+		}))
+		return txs, nil
+	}
+	return nil, &rpcError{Code: -32601, Message: fmt.Sprintf("call %v is not in the fixtures", method)}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sui_client "github.com/coming-chat/go-sui/v2/client"
+	"github.com/samber/lo"
+	"github.com/smartystreets/goconvey/convey"
+
+	"feng-sui-core/internal/entity_dto/sui_model"
+	"feng-sui-core/pkg/sui_rpc"
+)
+
+func TestRpcFixture(t *testing.T) {
+	convey.Convey("TestRpcFixture", t, func() {
+		var (
+			ctx      = context.Background()
+			dir      = t.TempDir()
+			upstream = &rpcReplayHandler{
+				calls:       make(map[string]json.RawMessage),
+				checkpoints: make(map[int64]json.RawMessage),
+				digests:     make(map[string]int64),
+				txs:         make(map[string]json.RawMessage),
+			}
+		)
+		for seq := int64(10); seq < 15; seq++ {
+			checkpoint, _ := json.Marshal(&sui_model.Checkpoint{
+				SequenceNumber: fmt.Sprint(seq),
+				Digest:         fmt.Sprintf("digest-%v", seq),
+				PreviousDigest: fmt.Sprintf("digest-%v", seq-1),
+				Transactions:   []string{fmt.Sprintf("tx-%v", seq)},
+			})
+			convey.So(upstream.add(&RpcFixture{Method: "sui_getCheckpoint", Result: checkpoint}), convey.ShouldBeNil)
+			tx, _ := json.Marshal([]*sui_model.Transaction{{Checkpoint: fmt.Sprint(seq), Digest: fmt.Sprintf("tx-%v", seq)}})
+			convey.So(upstream.add(&RpcFixture{Method: "sui_multiGetTransactionBlocks", Result: tx}), convey.ShouldBeNil)
+		}
+
+		newIndexer := func(url string, transport http.RoundTripper) *SuiIndexer {
+			client, err := sui_client.DialWithClient(url, &http.Client{Transport: transport, Timeout: 10 * time.Second})
+			convey.So(err, convey.ShouldBeNil)
+			return NewSuiIndexer(sui_rpc.NewPool([]string{url}, map[string]sui_rpc.Client{url: client}, sui_rpc.Config{}))
+		}
+
+		// record the calls to the upstream
+		server := httptest.NewServer(upstream)
+		defer server.Close()
+		recording := newIndexer(server.URL, NewRpcRecorder(http.DefaultTransport, dir))
+
+		checkpoints, err := recording.FetchCheckpoints(ctx, "10", "12")
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(checkpoints, convey.ShouldHaveLength, 3)
+		_, err = recording.FetchTxs(ctx, "tx-10", "tx-11")
+		convey.So(err, convey.ShouldBeNil)
+		_, err = recording.FetchTxs(ctx, "tx-unknown")
+		convey.So(err, convey.ShouldNotBeNil)
+
+		// replay them without the upstream
+		handler, err := NewRpcReplayHandler(dir)
+		convey.So(err, convey.ShouldBeNil)
+		replayServer := httptest.NewServer(handler)
+		defer replayServer.Close()
+		replaying := newIndexer(replayServer.URL, http.DefaultTransport)
+
+		convey.Convey("Recorded calls are replayed", func() {
+			res, err := replaying.FetchCheckpoints(ctx, "10", "12")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res, convey.ShouldResemble, checkpoints)
+
+			txs, err := replaying.FetchTxs(ctx, "tx-10", "tx-11")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(lo.Map(txs, func(tx *sui_model.Transaction, _ int) string { return tx.Digest }), convey.ShouldResemble, []string{"tx-10", "tx-11"})
+		})
+
+		convey.Convey("Recorded checkpoints and txs answer other calls", func() {
+			checkpoint, err := replaying.FetchCheckpoint(ctx, "11")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(checkpoint, convey.ShouldResemble, checkpoints[1])
+
+			res, err := replaying.FetchCheckpoints(ctx, "11", "20")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res, convey.ShouldHaveLength, 2)
+
+			txs, err := replaying.FetchTxs(ctx, "tx-11")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(txs[0].Checkpoint, convey.ShouldEqual, "11")
+
+			latest, err := replaying.FetchLatestCheckpoint(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(latest, convey.ShouldEqual, "12")
+		})
+
+		convey.Convey("Calls missing from the fixtures fail", func() {
+			_, err := replaying.FetchCheckpoint(ctx, "13")
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = replaying.FetchTxs(ctx, "tx-12")
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
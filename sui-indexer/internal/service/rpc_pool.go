@@ -0,0 +1,48 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/pkg/sui_rpc"
+)
+
+// NewSuiRpcPool dials the endpoints of SUI_RPCS, or SUI_RPC and FALLBACK_SUI_RPC when it is not set.
+// The calls are recorded as fixtures in RPC_RECORD_DIR when it is set.
+func NewSuiRpcPool() (*sui_rpc.Pool, error) {
+	transport, err := newHttpTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if conf.Config.RpcRecordDir != "" {
+		roundTripper = NewRpcRecorder(roundTripper, conf.Config.RpcRecordDir)
+	}
+
+	return sui_rpc.Dial(sui_rpc.Endpoints(conf.Config.SuiRpcs, conf.Config.SuiRpc, conf.Config.FallbackSuiRpc), roundTripper, sui_rpc.Config{
+		FailureThreshold: conf.Config.RpcFailureThreshold,
+		Cooldown:         conf.Config.RpcCooldown,
+		MaxLag:           conf.Config.RpcMaxLag,
+		TipInterval:      conf.Config.RpcTipInterval,
+		Limit: sui_rpc.LimitConfig{
+			MinRate:        conf.Config.RpcMinRate,
+			MaxRate:        conf.Config.RpcMaxRate,
+			MinConcurrency: conf.Config.RpcMinConcurrency,
+			MaxConcurrency: conf.Config.RpcMaxConcurrency,
+		},
+	})
+}
+
+// newHttpTransport returns the transport of the calls to the sui endpoints, through HTTP_PROXY when it is set
+func newHttpTransport() (*http.Transport, error) {
+	if !conf.Config.IsUseProxy() {
+		return http.DefaultTransport.(*http.Transport).Clone(), nil
+	}
+	proxyUrl, err := url.Parse(conf.Config.HttpProxy)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Transport{Proxy: http.ProxyURL(proxyUrl)}, nil
+}
@@ -101,6 +101,8 @@ func (svc *s3Service) FileStreamWriter(ctx context.Context, bucket string, key s
 				Key:    aws.String(key),
 				Body:   pr,
 			})
+		// unblock the writer when the upload stopped before reading everything
+		pr.CloseWithError(err)
 		errCh <- err
 	}()
 
@@ -5,50 +5,53 @@ import (
 	"fmt"
 	"strconv"
 
-	sui_client "github.com/coming-chat/go-sui/v2/client"
 	"github.com/coming-chat/go-sui/v2/types"
+	"github.com/samber/lo"
 
 	"feng-sui-core/internal/entity_dto/sui_model"
+	"feng-sui-core/pkg/sui_rpc"
 )
 
-func NewSuiIndexer(client, fallbackClient *sui_client.Client) *SuiIndexer {
+func NewSuiIndexer(pool *sui_rpc.Pool) *SuiIndexer {
 	return &SuiIndexer{
-		client:         client,
-		fallbackClient: fallbackClient,
+		pool: pool,
 	}
 }
 
 type SuiIndexer struct {
-	client         *sui_client.Client
-	fallbackClient *sui_client.Client
+	pool *sui_rpc.Pool
 }
 
+// RpcCalls returns the number of rpc calls made so far, retries on other endpoints included
+func (svc *SuiIndexer) RpcCalls() int64 {
+	return svc.pool.Calls()
+}
+
+// FetchLatestCheckpoint returns the highest latest checkpoint of the rpc endpoints
 func (svc *SuiIndexer) FetchLatestCheckpoint(ctx context.Context) (string, error) {
-	return svc.client.GetLatestCheckpointSequenceNumber(ctx)
+	tip, err := svc.pool.Tip(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(tip, 10), nil
 }
 
 func (svc *SuiIndexer) FetchCheckpoint(ctx context.Context, checkpointId string) (*sui_model.Checkpoint, error) {
-	var resp sui_model.Checkpoint
-	if err := svc.client.CallContext(ctx, &resp, sui_client.SuiMethod("getCheckpoint"), checkpointId); err == nil {
-		return &resp, nil
+	seq, err := strconv.ParseInt(checkpointId, 10, 64)
+	if err != nil {
+		return nil, err
 	}
-	// fallback query
-	return &resp, svc.fallbackClient.CallContext(ctx, &resp, sui_client.SuiMethod("getCheckpoint"), checkpointId)
+	var resp sui_model.Checkpoint
+	return &resp, svc.pool.Call(ctx, seq, &resp, "getCheckpoint", checkpointId)
 }
 
+// FetchCheckpoints returns the checkpoints from fromCheckpointId to toCheckpointId (inclusive),
+// fetched by pages of at most 100 checkpoints
 func (svc *SuiIndexer) FetchCheckpoints(ctx context.Context, fromCheckpointId string, toCheckpointId string) ([]*sui_model.Checkpoint, error) {
 	fromId, err := strconv.ParseInt(fromCheckpointId, 10, 64)
 	if err != nil {
 		return nil, err
 	}
-	if fromId > 0 {
-		// E.g. fromId=1 then method `getCheckpoints` will return checkpoints from 2
-		// Because of that we need to decrease `fromId` by 1
-		fromId = fromId - 1
-	} else {
-		return nil, fmt.Errorf("from checkpoint id must be larger than 0")
-	}
-
 	toId, err := strconv.ParseInt(toCheckpointId, 10, 64)
 	if err != nil {
 		return nil, err
@@ -56,36 +59,40 @@ func (svc *SuiIndexer) FetchCheckpoints(ctx context.Context, fromCheckpointId st
 	if toId < fromId {
 		return nil, fmt.Errorf("to checkpoint id must be equal or larger than fromCheckpointId")
 	}
-	limit := toId - fromId
-	if limit > 100 {
-		limit = 100
-	}
 
-	var resp = struct {
+	type checkpointPage struct {
 		Data        []*sui_model.Checkpoint `json:"data"`
 		NextCursor  string                  `json:"nextCursor"`
 		HasNextPage bool                    `json:"hasNextPage"`
-	}{}
-	if err := svc.client.CallContext(ctx, &resp, sui_client.SuiMethod("getCheckpoints"), strconv.FormatInt(fromId, 10), limit, false); err == nil {
-		return resp.Data, nil
 	}
-	// fallback query
-	return resp.Data, svc.fallbackClient.CallContext(ctx, &resp, sui_client.SuiMethod("getCheckpoints"), strconv.FormatInt(fromId, 10), limit, false)
+	checkpoints := make([]*sui_model.Checkpoint, 0, toId-fromId+1)
+	for pageFrom := fromId; pageFrom <= toId; {
+		limit := min(toId-pageFrom+1, 100)
+
+		// E.g. cursor=1 then method `getCheckpoints` will return checkpoints from 2
+		// Because of that the cursor is `pageFrom - 1`, and no cursor to start from the genesis checkpoint
+		var cursor *string
+		if pageFrom > 0 {
+			cursor = lo.ToPtr(strconv.FormatInt(pageFrom-1, 10))
+		}
+
+		var resp checkpointPage
+		if err := svc.pool.Call(ctx, pageFrom+limit-1, &resp, "getCheckpoints", cursor, limit, false); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, resp.Data...)
+		if int64(len(resp.Data)) < limit {
+			// the endpoint does not have the next checkpoints yet
+			break
+		}
+		pageFrom += limit
+	}
+	return checkpoints, nil
 }
 
 func (svc *SuiIndexer) FetchTxs(ctx context.Context, digests ...string) ([]*sui_model.Transaction, error) {
 	resp := make([]*sui_model.Transaction, 0)
-	if err := svc.client.CallContext(ctx, &resp, sui_client.SuiMethod("multiGetTransactionBlocks"), digests, types.SuiTransactionBlockResponseOptions{
-		ShowInput:          true,
-		ShowEffects:        true,
-		ShowEvents:         true,
-		ShowObjectChanges:  true,
-		ShowBalanceChanges: true,
-	}); err == nil {
-		return resp, nil
-	}
-	// fallback query
-	return resp, svc.fallbackClient.CallContext(ctx, &resp, sui_client.SuiMethod("multiGetTransactionBlocks"), digests, types.SuiTransactionBlockResponseOptions{
+	return resp, svc.pool.Call(ctx, 0, &resp, "multiGetTransactionBlocks", digests, types.SuiTransactionBlockResponseOptions{
 		ShowInput:          true,
 		ShowEffects:        true,
 		ShowEvents:         true,
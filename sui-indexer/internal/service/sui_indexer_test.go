@@ -2,72 +2,64 @@ package service
 
 import (
 	"context"
-	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	sui_client "github.com/coming-chat/go-sui/v2/client"
+	"github.com/samber/lo"
 	"github.com/smartystreets/goconvey/convey"
-	"golang.org/x/exp/slices"
 
-	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/entity_dto/sui_model"
+	"feng-sui-core/pkg/sui_rpc"
 )
 
-func TestBlockSubscribeService(t *testing.T) {
-	ctx := context.Background()
+// rpcFixtureDir holds the json-rpc calls of the checkpoints 999..1011 of epoch 5, in the format of RPC_RECORD_DIR
+const rpcFixtureDir = "testdata/rpc"
 
-	_ = conf.LoadConfig(".")
+func TestSuiIndexerService(t *testing.T) {
+	convey.Convey("TestSuiIndexerService", t, func() {
+		ctx := context.Background()
 
-	convey.FocusConvey("TestSuiIndexerService", t, func() {
-		transport := http.DefaultTransport.(*http.Transport)
-		client, err := sui_client.DialWithClient(conf.Config.SuiRpc, &http.Client{
-			Transport: transport.Clone(),
-			Timeout:   2 * 60 * time.Second, // 2 mins
-		})
-		convey.So(err, convey.ShouldBeNil)
-		fallbackClient, err := sui_client.DialWithClient(conf.Config.FallbackSuiRpc, &http.Client{
-			Transport: transport.Clone(),
-			Timeout:   2 * 60 * time.Second, // 2 mins
-		})
+		handler, err := NewRpcReplayHandler(rpcFixtureDir)
 		convey.So(err, convey.ShouldBeNil)
+		server := httptest.NewServer(handler)
+		defer server.Close()
 
-		indexer := NewSuiIndexer(client, fallbackClient)
+		client, err := sui_client.DialWithClient(server.URL, &http.Client{Timeout: 10 * time.Second})
+		convey.So(err, convey.ShouldBeNil)
+		indexer := NewSuiIndexer(sui_rpc.NewPool([]string{server.URL}, map[string]sui_rpc.Client{server.URL: client}, sui_rpc.Config{}))
 
 		convey.Convey("Fetch latest checkpoint", func() {
 			res, err := indexer.FetchLatestCheckpoint(ctx)
 			convey.So(err, convey.ShouldBeNil)
-
-			fmt.Println(res)
+			convey.So(res, convey.ShouldEqual, "1011")
 		})
 
 		convey.Convey("Fetch checkpoints", func() {
-			res, err := indexer.FetchCheckpoints(ctx, "25081993", "25082093")
+			res, err := indexer.FetchCheckpoints(ctx, "1000", "1011")
 			convey.So(err, convey.ShouldBeNil)
-			convey.So(len(res), convey.ShouldNotBeZeroValue)
-			convey.So(true, convey.ShouldEqual, slices.Contains(res[0].Transactions, "9tqki1JHL2zCwVPSge5TCbqjquJpGzkQXXRX4qpeB9nL"))
+			convey.So(res, convey.ShouldHaveLength, 12)
+			convey.So(res[0].SequenceNumber, convey.ShouldEqual, "1000")
+			convey.So(res[0].Transactions, convey.ShouldContain, "9tgqM4mx1a5RSwzRpcufnSh8gYmpt28o5MuE3qN4aY6k")
+			convey.So(sui_model.VerifyTotals(nil, res), convey.ShouldBeEmpty)
 
-			fmt.Println(res)
+			// the checkpoints after the tip are not returned
+			res, err = indexer.FetchCheckpoints(ctx, "1010", "1020")
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(res, convey.ShouldHaveLength, 2)
 		})
 
-		convey.FocusConvey("Fetch txs", func() {
-			res, err := indexer.FetchTxs(ctx, []string{
-				"9tqki1JHL2zCwVPSge5TCbqjquJpGzkQXXRX4qpeB9nL",
-				"UrcvxPKvGS527FmdT8ir1hn6xA75Us1mVabS7zKiVYY",
-				//"G39aMVvJ3nckxStXa6ox7xfvAd4uaUKCjSgfk3Bz4J8T",
-				//"41hnYMezCvAsz1jjojC13TW9zz9A7TJE8e4zpc2u2Znh",
-				//"4y7iJBnYtKUnpRCiAkKL2F2ffB4YnCZpWUY1K2FvRgy5",
-				//"5gBCpwARZcqBn5ofp7gE2SPSLigxknLn4MHDCj5pGGB4",
-				//"EP45NbKk1Yc7PoD21eaojmquw2ie7LedMyi63koCGjnU",
-				//"6JLFWtM8FK1LCVSTH4n71nqZk4Jc5ydLgmK3Z9rvfUfk",
-				//"AXhxPUbqipePRiv5i5phpmtyiXV9WCAA9CWWgrXSFDLr",
-				//"ApQk7EmYqcqgckEhsikpgKGLq2tZapJsbHyCgYqxtQtr",
-				//"Az8ityE1zLUtiPKrBGVbJ43h4B9iDQMu3VCkxyi9tyka",
-				//"GA8KCy6fvw8Xm4xwtB5Jw9W2WgmANKiLgiWM5a5RQDTU",
-			}...)
+		convey.Convey("Fetch txs", func() {
+			res, err := indexer.FetchTxs(ctx, "9tgqM4mx1a5RSwzRpcufnSh8gYmpt28o5MuE3qN4aY6k", "FRUBve2Y6xgbvE4Mnt6wmbedUFcLYc8XUmsCcoyW9WXM")
 			convey.So(err, convey.ShouldBeNil)
-
-			fmt.Println(res)
+			convey.So(lo.Map(res, func(tx *sui_model.Transaction, _ int) string {
+				return tx.Digest
+			}), convey.ShouldResemble, []string{"9tgqM4mx1a5RSwzRpcufnSh8gYmpt28o5MuE3qN4aY6k", "FRUBve2Y6xgbvE4Mnt6wmbedUFcLYc8XUmsCcoyW9WXM"})
+			convey.So(res[0].Events, convey.ShouldHaveLength, 1)
+			convey.So(res[0].ObjectChanges, convey.ShouldHaveLength, 2)
+			convey.So(res[0].BalanceChanges, convey.ShouldHaveLength, 1)
 		})
 	})
 }
@@ -8,6 +8,11 @@ var (
 	TransactionInProgressErr error
 	TransactionNotStartedErr error
 	DuplicatedRecordsErr     error
+
+	// sui
+	CheckpointMismatchErr    error
+	CheckpointCertificateErr error
+	LeaseLostErr             error
 )
 
 func init() {
@@ -15,4 +20,8 @@ func init() {
 	TransactionInProgressErr = errors.New("transaction already in progress")
 	TransactionNotStartedErr = errors.New("transaction not started")
 	DuplicatedRecordsErr = errors.New("duplicated records")
+
+	CheckpointMismatchErr = errors.New("checkpoint chain mismatch")
+	CheckpointCertificateErr = errors.New("checkpoint not certified")
+	LeaseLostErr = errors.New("block range lease lost")
 }
@@ -0,0 +1,32 @@
+package sink
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// NewFanoutSink writes every batch to all sinks concurrently, the batch fails when any of them fails
+func NewFanoutSink(sinks ...Sink) Sink {
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return &fanoutSink{
+		sinks: sinks,
+	}
+}
+
+type fanoutSink struct {
+	sinks []Sink
+}
+
+func (s *fanoutSink) Write(ctx context.Context, batch *Batch) error {
+	eg, childCtx := errgroup.WithContext(ctx)
+	for _, sink := range s.sinks {
+		sink := sink
+		eg.Go(func() error {
+			return sink.Write(childCtx, batch)
+		})
+	}
+	return eg.Wait()
+}
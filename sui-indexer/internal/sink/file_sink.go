@@ -0,0 +1,235 @@
+package sink
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+
+	"feng-sui-core/internal/service"
+)
+
+// fileStorage stores the files written by a file sink
+type fileStorage interface {
+	// Put stores the bytes written by write at key, a failed write must not leave a partial file
+	Put(ctx context.Context, key string, write func(w io.Writer) error) error
+	// Get opens the file key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns the keys of the files starting with prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes the files keys, missing files are ignored
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// NewS3Sink stores the streams of each batch as gzipped json lines in bucket,
+// at <dataset>/<topic>/datekey=<date key>/<checkpoint>.json.gz
+func NewS3Sink(s3Svc service.S3Service, bucket string) Sink {
+	return newS3JsonFiles(s3Svc, bucket)
+}
+
+// NewS3ParquetSink stores the streams of each batch as parquet files in bucket,
+// at <prefix>/<topic>/datekey=<date key>/<checkpoint>.parquet which is the layout of the final_sui_* athena tables
+func NewS3ParquetSink(s3Svc service.S3Service, bucket string, prefix string) Sink {
+	return newS3ParquetFiles(s3Svc, bucket, prefix)
+}
+
+func newS3JsonFiles(s3Svc service.S3Service, bucket string) *fileSink {
+	return &fileSink{
+		storage: &s3Storage{
+			s3Svc:  s3Svc,
+			bucket: bucket,
+		},
+		dir: func(stream *Stream) string {
+			return fmt.Sprintf("%v/%v", stream.Dataset, stream.Topic)
+		},
+		ext:         "json.gz",
+		manifestDir: "manifests",
+		write: func(w io.Writer, stream *Stream) error {
+			return writeJsonLines(w, stream.Records)
+		},
+	}
+}
+
+func newS3ParquetFiles(s3Svc service.S3Service, bucket string, prefix string) *fileSink {
+	return &fileSink{
+		storage: &s3Storage{
+			s3Svc:  s3Svc,
+			bucket: bucket,
+		},
+		dir: func(stream *Stream) string {
+			return fmt.Sprintf("%v/%v", prefix, stream.Topic)
+		},
+		ext:         "parquet",
+		manifestDir: fmt.Sprintf("%v/manifests", prefix),
+		write: func(w io.Writer, stream *Stream) error {
+			return writeParquet(w, stream.Dataset, stream.Records)
+		},
+	}
+}
+
+// fileSink stores every stream as the file <dir>/datekey=<date key>/<name>.<ext>
+type fileSink struct {
+	storage fileStorage
+	dir     func(stream *Stream) string
+	ext     string
+	// manifestDir is where the roll-ups of the files write their manifests
+	manifestDir string
+	write       func(w io.Writer, stream *Stream) error
+}
+
+func (s *fileSink) Write(ctx context.Context, batch *Batch) error {
+	_, err := s.put(ctx, batch.DateKey, batch.Checkpoint, batch.Streams)
+	return err
+}
+
+// put stores the non empty streams as the files name of the date key partitions and returns them,
+// the files already stored are deleted when any of them fails so that no partial upload is left
+func (s *fileSink) put(ctx context.Context, dateKey string, name string, streams []*Stream) ([]*ManifestFile, error) {
+	var (
+		files        = make([]*ManifestFile, 0, len(streams))
+		eg, childCtx = errgroup.WithContext(ctx)
+	)
+	for _, stream := range streams {
+		if len(stream.Records) == 0 {
+			continue
+		}
+		stream, key := stream, fmt.Sprintf("%v/datekey=%v/%v.%v", s.dir(stream), dateKey, name, s.ext)
+		files = append(files, &ManifestFile{
+			Key:     key,
+			Dataset: stream.Dataset,
+			Topic:   stream.Topic,
+			Records: len(stream.Records),
+		})
+		eg.Go(func() error {
+			if err := s.storage.Put(childCtx, key, func(w io.Writer) error {
+				return s.write(w, stream)
+			}); err != nil {
+				return fmt.Errorf("failed to store %v: %v", key, err)
+			}
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		// the context of the group is canceled by now
+		if delErr := s.remove(context.WithoutCancel(ctx), files); delErr != nil {
+			return nil, errors.Join(err, delErr)
+		}
+		return nil, err
+	}
+	return files, nil
+}
+
+// remove deletes files
+func (s *fileSink) remove(ctx context.Context, files []*ManifestFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	keys := lo.Map(files, func(file *ManifestFile, _ int) string {
+		return file.Key
+	})
+	if err := s.storage.Delete(ctx, keys...); err != nil {
+		return fmt.Errorf("failed to delete %v: %v", strings.Join(keys, ","), err)
+	}
+	return nil
+}
+
+// writeJsonLines writes records to w as gzipped json lines
+func writeJsonLines[T any](w io.Writer, records []T) error {
+	zw, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
+	if err != nil {
+		return fmt.Errorf("failed to create gzip writer: %v", err)
+	}
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to marshal record: %v", err)
+		}
+		data = append(data, '\n')
+		if _, err := zw.Write(data); err != nil {
+			return fmt.Errorf("failed to write record: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to flush gzip writer: %v", err)
+	}
+	return nil
+}
+
+type s3Storage struct {
+	s3Svc  service.S3Service
+	bucket string
+}
+
+func (s *s3Storage) Put(ctx context.Context, key string, write func(w io.Writer) error) error {
+	errCh := make(chan error, 1)
+	defer close(errCh)
+
+	pw := s.s3Svc.FileStreamWriter(ctx, s.bucket, key, errCh)
+	if err := write(pw); err != nil {
+		// the upload is aborted by the error, nothing is stored
+		pw.CloseWithError(err)
+		<-errCh
+		return err
+	}
+	pw.Close()
+	return <-errCh
+}
+
+func (s *s3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.s3Svc.GetClient().GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *s3Storage) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys = make([]string, 0)
+	if err := s.s3Svc.GetClient().ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			keys = append(keys, aws.StringValue(object.Key))
+		}
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// s3DeleteBatch is the maximum number of keys of a DeleteObjects call
+const s3DeleteBatch = 1000
+
+func (s *s3Storage) Delete(ctx context.Context, keys ...string) error {
+	for _, chunk := range lo.Chunk(keys, s3DeleteBatch) {
+		out, err := s.s3Svc.GetClient().DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &s3.Delete{
+				Objects: lo.Map(chunk, func(key string, _ int) *s3.ObjectIdentifier {
+					return &s3.ObjectIdentifier{Key: aws.String(key)}
+				}),
+				Quiet: aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("failed to delete %v: %v", aws.StringValue(out.Errors[0].Key), aws.StringValue(out.Errors[0].Message))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package sink
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/samber/lo"
+
+	"feng-sui-core/internal/entity_dto/sui_model"
+	"feng-sui-core/internal/service"
+)
+
+// NewS3Inventory reads back the files the s3 sink stored in bucket with roll-ups of windowSize checkpoints
+func NewS3Inventory(s3Svc service.S3Service, bucket string, windowSize int64) *Inventory {
+	return &Inventory{
+		files:      newS3JsonFiles(s3Svc, bucket),
+		windowSize: windowSize,
+	}
+}
+
+// Inventory finds the checkpoints stored by the roll-ups of a json lines file sink through their manifests
+type Inventory struct {
+	files      *fileSink
+	windowSize int64
+}
+
+// StoredCheckpoint is what the roll-ups stored for a checkpoint
+type StoredCheckpoint struct {
+	Seq int64
+	// Checkpoint is the stored checkpoint record, nil when no manifest covers the checkpoint
+	Checkpoint *sui_model.Checkpoint
+	// StoredTxs is the number of distinct txs stored for the checkpoint, only counted on demand
+	StoredTxs int
+}
+
+// Stored reports whether a manifest covers the checkpoint, all files of the checkpoint are stored
+func (c *StoredCheckpoint) Stored() bool {
+	return c.Checkpoint != nil
+}
+
+// Complete reports whether the checkpoint is stored with all of its txs
+func (c *StoredCheckpoint) Complete() bool {
+	return c.Stored() && c.StoredTxs == len(lo.Uniq(c.Checkpoint.Transactions))
+}
+
+// Manifests returns the manifests of the roll-ups holding checkpoints of from..to
+func (i *Inventory) Manifests(ctx context.Context, from int64, to int64) ([]*Manifest, error) {
+	var manifests = make([]*Manifest, 0)
+	for start := from - from%i.windowSize; start <= to; start += i.windowSize {
+		keys, err := i.files.storage.List(ctx, fmt.Sprintf("%v/%v/", i.files.manifestDir, start))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list manifests of window %v: %v", start, err)
+		}
+		for _, key := range keys {
+			var manifest Manifest
+			if err := i.readLines(ctx, key, false, func(data []byte) error {
+				return json.Unmarshal(data, &manifest)
+			}); err != nil {
+				return nil, fmt.Errorf("invalid manifest %v: %v", key, err)
+			}
+			if manifest.From <= to && manifest.To >= from {
+				manifests = append(manifests, &manifest)
+			}
+		}
+	}
+	return manifests, nil
+}
+
+// Checkpoints returns the checkpoints from..to as stored by the roll-ups, their stored txs are counted
+// when countTxs is set which reads the txs files
+func (i *Inventory) Checkpoints(ctx context.Context, from int64, to int64, countTxs bool) ([]*StoredCheckpoint, error) {
+	manifests, err := i.Manifests(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		res   = make([]*StoredCheckpoint, 0, to-from+1)
+		txsBy = make(map[int64]map[string]bool)
+	)
+	for seq := from; seq <= to; seq++ {
+		res = append(res, &StoredCheckpoint{Seq: seq})
+	}
+	for _, manifest := range manifests {
+		for _, file := range manifest.Files {
+			switch {
+			case file.Dataset == Dataset_CHECKPOINTS:
+				if err := i.readLines(ctx, file.Key, true, func(data []byte) error {
+					var checkpoint sui_model.Checkpoint
+					if err := json.Unmarshal(data, &checkpoint); err != nil {
+						return err
+					}
+					seq, err := strconv.ParseInt(checkpoint.SequenceNumber, 10, 64)
+					if err != nil {
+						return err
+					}
+					if seq >= from && seq <= to {
+						res[seq-from].Checkpoint = &checkpoint
+					}
+					return nil
+				}); err != nil {
+					return nil, fmt.Errorf("failed to read %v: %v", file.Key, err)
+				}
+			case file.Dataset == Dataset_TXS && countTxs:
+				if err := i.readLines(ctx, file.Key, true, func(data []byte) error {
+					var tx struct {
+						Checkpoint string `json:"checkpoint"`
+						Digest     string `json:"digest"`
+					}
+					if err := json.Unmarshal(data, &tx); err != nil {
+						return err
+					}
+					seq, err := strconv.ParseInt(tx.Checkpoint, 10, 64)
+					if err != nil {
+						return err
+					}
+					if txsBy[seq] == nil {
+						txsBy[seq] = make(map[string]bool)
+					}
+					txsBy[seq][tx.Digest] = true
+					return nil
+				}); err != nil {
+					return nil, fmt.Errorf("failed to read %v: %v", file.Key, err)
+				}
+			}
+		}
+	}
+	for _, item := range res {
+		item.StoredTxs = len(txsBy[item.Seq])
+	}
+	return res, nil
+}
+
+// readLines calls fn with every line of the file key, gzipped files are decompressed
+func (i *Inventory) readLines(ctx context.Context, key string, gzipped bool, fn func(data []byte) error) error {
+	body, err := i.files.storage.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var r io.Reader = body
+	if gzipped {
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	// lines of txs can be larger than the buffer of a bufio.Scanner
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
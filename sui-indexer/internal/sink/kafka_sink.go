@@ -0,0 +1,32 @@
+package sink
+
+import (
+	"context"
+
+	"feng-sui-core/internal/infra"
+)
+
+// NewKafkaSink sends each batch to the topics of its streams in one kafka transaction
+func NewKafkaSink(producer infra.KafkaTxProducer) Sink {
+	return &kafkaSink{
+		producer: producer,
+	}
+}
+
+type kafkaSink struct {
+	producer infra.KafkaTxProducer
+}
+
+func (s *kafkaSink) Write(ctx context.Context, batch *Batch) error {
+	var data = make(map[string][]infra.KafkaMsg, len(batch.Streams))
+	for _, stream := range batch.Streams {
+		if len(stream.Records) == 0 {
+			continue
+		}
+		data[stream.Topic] = append(data[stream.Topic], stream.Records...)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return s.producer.SendJson(ctx, data)
+}
@@ -0,0 +1,164 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// formats of the local sink files
+const (
+	LocalFormat_JSONL = "jsonl"
+	LocalFormat_GZIP  = "gzip"
+)
+
+// NewLocalSink appends the records of each stream as json lines to files in dir,
+// at <dataset>/<topic>/<topic>-<first checkpoint of the file>.jsonl or .jsonl.gz.
+// A file is rotated once maxFileSize bytes of json are written to it, every batch is flushed to disk.
+func NewLocalSink(dir string, format string, maxFileSize int64) (LocalSink, error) {
+	switch strings.ToLower(format) {
+	case LocalFormat_JSONL, LocalFormat_GZIP:
+	default:
+		return nil, fmt.Errorf("unsupported local sink format %v", format)
+	}
+	return &localSink{
+		dir:         dir,
+		compress:    strings.ToLower(format) == LocalFormat_GZIP,
+		maxFileSize: maxFileSize,
+		files:       make(map[string]*localFile),
+	}, nil
+}
+
+type LocalSink interface {
+	Sink
+	// Close flushes and closes the open files
+	Close() error
+}
+
+type localSink struct {
+	mu          sync.Mutex
+	dir         string
+	compress    bool
+	maxFileSize int64
+	files       map[string]*localFile
+}
+
+// localFile is the file a stream is currently appended to
+type localFile struct {
+	f    *os.File
+	zw   *gzip.Writer
+	size int64
+}
+
+func (f *localFile) Write(data []byte) error {
+	var w io.Writer = f.f
+	if f.zw != nil {
+		w = f.zw
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	f.size += int64(len(data))
+	if f.zw != nil {
+		return f.zw.Flush()
+	}
+	return nil
+}
+
+func (f *localFile) Close() error {
+	if f.zw != nil {
+		if err := f.zw.Close(); err != nil {
+			f.f.Close()
+			return err
+		}
+	}
+	return f.f.Close()
+}
+
+func (s *localSink) Write(ctx context.Context, batch *Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stream := range batch.Streams {
+		if len(stream.Records) == 0 {
+			continue
+		}
+
+		// marshal first so a bad record does not leave a part of the stream in the file
+		var buf bytes.Buffer
+		for _, record := range stream.Records {
+			data, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("failed to marshal record: %v", err)
+			}
+			buf.Write(data)
+			buf.WriteByte('\n')
+		}
+
+		key := filepath.Join(stream.Dataset, stream.Topic)
+		file, ok := s.files[key]
+		if !ok {
+			var err error
+			if file, err = s.open(stream, batch.Checkpoint); err != nil {
+				return err
+			}
+			s.files[key] = file
+		}
+		if err := file.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write %v: %v", file.f.Name(), err)
+		}
+		if file.size >= s.maxFileSize {
+			delete(s.files, key)
+			if err := file.Close(); err != nil {
+				return fmt.Errorf("failed to rotate %v: %v", file.f.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *localSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var closeErr error
+	for key, file := range s.files {
+		if err := file.Close(); err != nil {
+			closeErr = err
+		}
+		delete(s.files, key)
+	}
+	return closeErr
+}
+
+func (s *localSink) open(stream *Stream, checkpoint string) (*localFile, error) {
+	name := fmt.Sprintf("%v-%v.jsonl", stream.Topic, checkpoint)
+	if s.compress {
+		name += ".gz"
+	}
+	path := filepath.Join(s.dir, stream.Dataset, stream.Topic, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	// a file written again by a later run is appended, gzip readers read the appended members as well
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	file := &localFile{f: f}
+	if s.compress {
+		if file.zw, err = gzip.NewWriterLevel(f, gzip.BestSpeed); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return file, nil
+}
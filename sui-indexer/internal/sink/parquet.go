@@ -0,0 +1,263 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/entity_dto/sui_model"
+	"feng-sui-core/internal/infra"
+)
+
+// checkpointRow is a row of the athena table final_sui_checkpoints, dateKey is the partition
+type checkpointRow struct {
+	Epoch                      int64  `parquet:"epoch"`
+	TimestampMs                int64  `parquet:"timestampms"`
+	SequenceNumber             int64  `parquet:"sequencenumber"`
+	Digest                     string `parquet:"digest"`
+	NetworkTotalTransactions   string `parquet:"networktotaltransactions"`
+	PreviousDigest             string `parquet:"previousdigest"`
+	EpochRollingGasCostSummary string `parquet:"epochrollinggascostsummary"`
+	ValidatorSignature         string `parquet:"validatorsignature"`
+	EventsBloom                string `parquet:"eventsbloom"`
+	PackagesBloom              string `parquet:"packagesbloom"`
+}
+
+// txRow is a row of the athena table final_sui_txs, the nested fields are json strings and dateKey is the partition
+type txRow struct {
+	Digest         string `parquet:"digest"`
+	TimestampMs    int64  `parquet:"timestampms"`
+	Checkpoint     int64  `parquet:"checkpoint"`
+	Transaction    string `parquet:"transaction"`
+	Effects        string `parquet:"effects"`
+	Events         string `parquet:"events"`
+	ObjectChanges  string `parquet:"objectchanges"`
+	BalanceChanges string `parquet:"balancechanges"`
+}
+
+// eventRow is a row of the athena table final_sui_events, parsedJson and gasUsed are json strings
+type eventRow struct {
+	TxDigest          string `parquet:"txdigest"`
+	EventSeq          int64  `parquet:"eventseq"`
+	TimestampMs       int64  `parquet:"timestampms"`
+	Checkpoint        int64  `parquet:"checkpoint"`
+	PackageId         string `parquet:"packageid"`
+	TransactionModule string `parquet:"transactionmodule"`
+	Sender            string `parquet:"sender"`
+	Type              string `parquet:"type"`
+	Bcs               string `parquet:"bcs"`
+	ParsedJson        string `parquet:"parsedjson"`
+	GasUsed           string `parquet:"gasused"`
+}
+
+// indexRow has the columns of the table sui_index
+type indexRow struct {
+	DateKey          string `parquet:"date_key"`
+	CheckpointDigest string `parquet:"checkpoint_digest"`
+	CheckpointSeq    int64  `parquet:"checkpoint_seq"`
+	TxDigest         string `parquet:"tx_digest"`
+	EventSeq         int64  `parquet:"event_seq"`
+	PackageId        string `parquet:"package_id"`
+	EventType        string `parquet:"event_type"`
+}
+
+type objectChangeRow struct {
+	Checkpoint      int64  `parquet:"checkpoint"`
+	TxDigest        string `parquet:"txdigest"`
+	TimestampMs     int64  `parquet:"timestampms"`
+	Index           int32  `parquet:"index"`
+	Type            string `parquet:"type"`
+	Sender          string `parquet:"sender"`
+	Owner           string `parquet:"owner"`
+	OwnerType       string `parquet:"ownertype"`
+	ObjectId        string `parquet:"objectid"`
+	ObjectType      string `parquet:"objecttype"`
+	CoinType        string `parquet:"cointype"`
+	Version         string `parquet:"version"`
+	PreviousVersion string `parquet:"previousversion"`
+	Digest          string `parquet:"digest"`
+}
+
+type balanceChangeRow struct {
+	Checkpoint  int64  `parquet:"checkpoint"`
+	TxDigest    string `parquet:"txdigest"`
+	TimestampMs int64  `parquet:"timestampms"`
+	Index       int32  `parquet:"index"`
+	Owner       string `parquet:"owner"`
+	OwnerType   string `parquet:"ownertype"`
+	CoinType    string `parquet:"cointype"`
+	Amount      string `parquet:"amount"`
+}
+
+// writeParquet writes the records of a dataset to w as a snappy compressed parquet file
+func writeParquet(w io.Writer, dataset string, records []infra.KafkaMsg) error {
+	switch dataset {
+	case Dataset_CHECKPOINTS:
+		return writeParquetRows(w, records, newCheckpointRow)
+	case Dataset_TXS:
+		return writeParquetRows(w, records, newTxRow)
+	case Dataset_EVENTS:
+		return writeParquetRows(w, records, newEventRow)
+	case Dataset_INDEX:
+		return writeParquetRows(w, records, newIndexRow)
+	case Dataset_OBJECT_CHANGES:
+		return writeParquetRows(w, records, newObjectChangeRow)
+	case Dataset_BALANCE_CHANGES:
+		return writeParquetRows(w, records, newBalanceChangeRow)
+	default:
+		return fmt.Errorf("no parquet schema for dataset %v", dataset)
+	}
+}
+
+func writeParquetRows[T any, R any](w io.Writer, records []infra.KafkaMsg, newRow func(record T) (R, error)) error {
+	var rows = make([]R, 0, len(records))
+	for _, record := range records {
+		item, ok := record.(T)
+		if !ok {
+			return fmt.Errorf("unexpected record %T", record)
+		}
+		row, err := newRow(item)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+
+	pw := parquet.NewGenericWriter[R](w, parquet.Compression(&parquet.Snappy))
+	if _, err := pw.Write(rows); err != nil {
+		return fmt.Errorf("failed to write parquet rows: %v", err)
+	}
+	if err := pw.Close(); err != nil {
+		return fmt.Errorf("failed to flush parquet writer: %v", err)
+	}
+	return nil
+}
+
+func newCheckpointRow(checkpoint *sui_model.Checkpoint) (checkpointRow, error) {
+	gasCostSummary, err := json.Marshal(checkpoint.EpochRollingGasCostSummary)
+	if err != nil {
+		return checkpointRow{}, err
+	}
+	return checkpointRow{
+		Epoch:                      parseInt(checkpoint.Epoch),
+		TimestampMs:                parseInt(checkpoint.TimestampMs),
+		SequenceNumber:             parseInt(checkpoint.SequenceNumber),
+		Digest:                     checkpoint.Digest,
+		NetworkTotalTransactions:   checkpoint.NetworkTotalTransactions,
+		PreviousDigest:             checkpoint.PreviousDigest,
+		EpochRollingGasCostSummary: string(gasCostSummary),
+		ValidatorSignature:         checkpoint.ValidatorSignature,
+		EventsBloom:                checkpoint.EventsBloom,
+		PackagesBloom:              checkpoint.PackagesBloom,
+	}, nil
+}
+
+func newTxRow(tx *sui_model.Transaction) (txRow, error) {
+	var (
+		row    = txRow{Digest: tx.Digest, TimestampMs: parseInt(tx.TimestampMs), Checkpoint: parseInt(tx.Checkpoint)}
+		fields = []struct {
+			value interface{}
+			out   *string
+		}{
+			{tx.Transaction, &row.Transaction},
+			{tx.Effects, &row.Effects},
+			{tx.Events, &row.Events},
+			{tx.ObjectChanges, &row.ObjectChanges},
+			{tx.BalanceChanges, &row.BalanceChanges},
+		}
+	)
+	for _, field := range fields {
+		data, err := json.Marshal(field.value)
+		if err != nil {
+			return txRow{}, fmt.Errorf("failed to marshal tx %v: %v", tx.Digest, err)
+		}
+		*field.out = string(data)
+	}
+	return row, nil
+}
+
+func newEventRow(event *sui_model.Event) (eventRow, error) {
+	parsedJson, err := json.Marshal(event.ParsedJson)
+	if err != nil {
+		return eventRow{}, fmt.Errorf("failed to marshal event %v: %v", event.Id.TxDigest, err)
+	}
+	gasUsed, err := json.Marshal(event.GasUsed)
+	if err != nil {
+		return eventRow{}, fmt.Errorf("failed to marshal event %v: %v", event.Id.TxDigest, err)
+	}
+
+	var timestampMs int64
+	if event.TimestampMs != nil {
+		timestampMs = event.TimestampMs.Int64()
+	}
+	return eventRow{
+		TxDigest:          event.Id.TxDigest.String(),
+		EventSeq:          event.Id.EventSeq.Int64(),
+		TimestampMs:       timestampMs,
+		Checkpoint:        parseInt(event.Checkpoint),
+		PackageId:         event.PackageId.String(),
+		TransactionModule: event.TransactionModule,
+		Sender:            event.Sender.String(),
+		Type:              event.Type,
+		Bcs:               event.Bcs,
+		ParsedJson:        string(parsedJson),
+		GasUsed:           string(gasUsed),
+	}, nil
+}
+
+func newIndexRow(record *entity.SuiIndexKafka) (indexRow, error) {
+	if record.Index == nil {
+		return indexRow{}, fmt.Errorf("missing index row of checkpoint %v", record.CheckpointSeq)
+	}
+	return indexRow{
+		DateKey:          record.Index.DateKey,
+		CheckpointDigest: record.Index.CheckpointDigest,
+		CheckpointSeq:    record.Index.CheckpointSeq,
+		TxDigest:         record.Index.TxDigest,
+		EventSeq:         record.Index.EventSeq,
+		PackageId:        record.Index.PackageId,
+		EventType:        record.Index.EventType,
+	}, nil
+}
+
+func newObjectChangeRow(change *sui_model.ObjectChange) (objectChangeRow, error) {
+	return objectChangeRow{
+		Checkpoint:      parseInt(change.Checkpoint),
+		TxDigest:        change.TxDigest,
+		TimestampMs:     parseInt(change.TimestampMs),
+		Index:           int32(change.Index),
+		Type:            change.Type,
+		Sender:          change.Sender,
+		Owner:           change.Owner,
+		OwnerType:       change.OwnerType,
+		ObjectId:        change.ObjectId,
+		ObjectType:      change.ObjectType,
+		CoinType:        change.CoinType,
+		Version:         change.Version,
+		PreviousVersion: change.PreviousVersion,
+		Digest:          change.Digest,
+	}, nil
+}
+
+func newBalanceChangeRow(change *sui_model.BalanceChange) (balanceChangeRow, error) {
+	return balanceChangeRow{
+		Checkpoint:  parseInt(change.Checkpoint),
+		TxDigest:    change.TxDigest,
+		TimestampMs: parseInt(change.TimestampMs),
+		Index:       int32(change.Index),
+		Owner:       change.Owner,
+		OwnerType:   change.OwnerType,
+		CoinType:    change.CoinType,
+		Amount:      change.Amount,
+	}, nil
+}
+
+// parseInt parses the numbers the rpc returns as strings, they are validated before being emitted
+func parseInt(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
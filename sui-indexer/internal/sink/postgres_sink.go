@@ -0,0 +1,52 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/repo"
+)
+
+// NewPostgresSink stores every record as jsonb in the table sink_record,
+// a batch written again keeps the records already stored
+func NewPostgresSink(sinkRecordRepo repo.SinkRecordRepo) Sink {
+	return &postgresSink{
+		sinkRecordRepo: sinkRecordRepo,
+	}
+}
+
+type postgresSink struct {
+	sinkRecordRepo repo.SinkRecordRepo
+}
+
+func (s *postgresSink) Write(ctx context.Context, batch *Batch) error {
+	checkpoint, err := strconv.ParseInt(batch.Checkpoint, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	var (
+		records = make([]*entity.SinkRecord, 0, batch.Len())
+		indices = make(map[string]int)
+	)
+	for _, stream := range batch.Streams {
+		for _, record := range stream.Records {
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			records = append(records, &entity.SinkRecord{
+				Topic:        stream.Topic,
+				Checkpoint:   checkpoint,
+				Index:        indices[stream.Topic],
+				PartitionKey: record.PartitionKey(),
+				Data:         string(data),
+			})
+			indices[stream.Topic]++
+		}
+	}
+	// the records of a batch are inserted in one db transaction
+	return s.sinkRecordRepo.CreateMany(ctx, records...)
+}
@@ -0,0 +1,350 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/getnimbus/ultrago/u_logger"
+	"github.com/samber/lo"
+)
+
+// RollupConfig bounds the files of a roll-up, a roll-up with less than 2 checkpoints stores one file per checkpoint
+type RollupConfig struct {
+	// Checkpoints is the size of the windows of checkpoints grouped together, windows are aligned on multiples of it
+	Checkpoints int64
+	// MaxRecords flushes a window early once it holds that many records
+	MaxRecords int
+	// MaxWait flushes a window which is still incomplete after that long
+	MaxWait time.Duration
+}
+
+// RollupSink is a Sink which has to be closed to flush the checkpoints it still holds
+type RollupSink interface {
+	Sink
+	Close() error
+}
+
+// Manifest describes the files of the consecutive checkpoints From..To, it is stored after all of them
+// so files without a manifest are leftovers of a failed upload
+type Manifest struct {
+	DateKey   string          `json:"dateKey"`
+	From      int64           `json:"from"`
+	To        int64           `json:"to"`
+	Files     []*ManifestFile `json:"files"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// ManifestFile is a file listed in a manifest
+type ManifestFile struct {
+	Key     string `json:"key"`
+	Dataset string `json:"dataset"`
+	Topic   string `json:"topic"`
+	Records int    `json:"records"`
+}
+
+// ManifestKey returns where the manifest of the checkpoints from..to of the window starting at windowStart is stored
+func ManifestKey(manifestDir string, windowStart int64, from int64, to int64) string {
+	return fmt.Sprintf("%v/%v/%v-%v.json", manifestDir, windowStart, from, to)
+}
+
+// newRollupSink groups the batches of the checkpoints of a window into one file per stream, named after the
+// first and last checkpoint <from>-<to>. Write returns once the files holding the batch and their manifest are stored.
+func newRollupSink(files *fileSink, config RollupConfig) RollupSink {
+	return &rollupSink{
+		files:   files,
+		config:  config,
+		windows: make(map[int64]*rollupWindow),
+	}
+}
+
+type rollupSink struct {
+	files  *fileSink
+	config RollupConfig
+
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closed  bool
+	windows map[int64]*rollupWindow
+	errs    []error
+}
+
+type rollupWindow struct {
+	start   int64
+	records int
+	batches []*rollupBatch
+	timer   *time.Timer
+}
+
+type rollupBatch struct {
+	seq   int64
+	batch *Batch
+	// done receives the result of the writes of the checkpoint, a checkpoint written again before
+	// being uploaded replaces the previous batch and both writes get the result of the upload
+	done []chan error
+}
+
+func (s *rollupSink) Write(ctx context.Context, batch *Batch) error {
+	seq, err := strconv.ParseInt(batch.Checkpoint, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid checkpoint %v: %v", batch.Checkpoint, err)
+	}
+
+	done := make(chan error, 1)
+	if err := s.add(ctx, &rollupBatch{
+		seq:   seq,
+		batch: batch,
+		done:  []chan error{done},
+	}); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *rollupSink) add(ctx context.Context, pending *rollupBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("roll-up sink is closed")
+	}
+
+	// the files are uploaded in the background, after the writer may have given up
+	ctx = context.WithoutCancel(ctx)
+
+	start := pending.seq - pending.seq%s.config.Checkpoints
+	window, ok := s.windows[start]
+	if !ok {
+		window = &rollupWindow{start: start}
+		window.timer = time.AfterFunc(s.config.MaxWait, func() {
+			s.flush(ctx, window)
+		})
+		s.windows[start] = window
+	}
+	if _, idx, ok := lo.FindIndexOf(window.batches, func(item *rollupBatch) bool {
+		return item.seq == pending.seq
+	}); ok {
+		window.records -= window.batches[idx].batch.Len()
+		pending.done = append(pending.done, window.batches[idx].done...)
+		window.batches[idx] = pending
+	} else {
+		window.batches = append(window.batches, pending)
+	}
+	window.records += pending.batch.Len()
+
+	if int64(len(window.batches)) >= s.config.Checkpoints || (s.config.MaxRecords > 0 && window.records >= s.config.MaxRecords) {
+		s.detach(ctx, window)
+	}
+	return nil
+}
+
+// flush uploads window unless it has already been uploaded
+func (s *rollupSink) flush(ctx context.Context, window *rollupWindow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.windows[window.start] == window {
+		s.detach(ctx, window)
+	}
+}
+
+// detach removes window from the pending windows and uploads it, s.mu must be held
+func (s *rollupSink) detach(ctx context.Context, window *rollupWindow) {
+	window.timer.Stop()
+	delete(s.windows, window.start)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.upload(ctx, window); err != nil {
+			s.mu.Lock()
+			s.errs = append(s.errs, err)
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// upload stores the batches of window as runs of consecutive checkpoints of the same date key,
+// every batch is told whether the run holding it is stored
+func (s *rollupSink) upload(ctx context.Context, window *rollupWindow) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	sort.Slice(window.batches, func(i, j int) bool {
+		return window.batches[i].seq < window.batches[j].seq
+	})
+
+	var errs = make([]error, 0)
+	for _, run := range splitRuns(window.batches, s.config.MaxRecords) {
+		err := s.uploadRun(ctx, window.start, run)
+		if err != nil {
+			logger.Errorf("failed to store checkpoints %v-%v: %v", run[0].seq, run[len(run)-1].seq, err)
+			errs = append(errs, err)
+		}
+		for _, pending := range run {
+			for _, done := range pending.done {
+				done <- err
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *rollupSink) uploadRun(ctx context.Context, windowStart int64, run []*rollupBatch) error {
+	var (
+		from, to = run[0].seq, run[len(run)-1].seq
+		dateKey  = run[0].batch.DateKey
+		streams  = make([]*Stream, 0)
+		index    = make(map[string]*Stream)
+	)
+	for _, pending := range run {
+		for _, stream := range pending.batch.Streams {
+			key := stream.Dataset + "/" + stream.Topic
+			merged, ok := index[key]
+			if !ok {
+				merged = &Stream{
+					Dataset: stream.Dataset,
+					Topic:   stream.Topic,
+				}
+				index[key] = merged
+				streams = append(streams, merged)
+			}
+			merged.Records = append(merged.Records, stream.Records...)
+		}
+	}
+
+	// the name only depends on the checkpoints so that a run uploaded again overwrites its files
+	files, err := s.files.put(ctx, dateKey, fmt.Sprintf("%v-%v", from, to), streams)
+	if err != nil {
+		return err
+	}
+
+	manifest := &Manifest{
+		DateKey:   dateKey,
+		From:      from,
+		To:        to,
+		Files:     files,
+		CreatedAt: time.Now().UTC(),
+	}
+	key := ManifestKey(s.files.manifestDir, windowStart, from, to)
+	if err := s.files.storage.Put(ctx, key, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(manifest)
+	}); err != nil {
+		err = fmt.Errorf("failed to store %v: %v", key, err)
+		if delErr := s.files.remove(ctx, files); delErr != nil {
+			return errors.Join(err, delErr)
+		}
+		return err
+	}
+	return s.supersede(ctx, windowStart, key, manifest)
+}
+
+// supersede deletes the runs of the window stored before and covered by manifest, a checkpoint uploaded again
+// in a different run, e.g. after a retry, would otherwise be stored twice. The files are deleted before
+// their manifest so that no file is left without one.
+func (s *rollupSink) supersede(ctx context.Context, windowStart int64, key string, manifest *Manifest) error {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	keys, err := s.files.storage.List(ctx, fmt.Sprintf("%v/%v/", s.files.manifestDir, windowStart))
+	if err != nil {
+		return fmt.Errorf("failed to list manifests of window %v: %v", windowStart, err)
+	}
+	stored := lo.SliceToMap(manifest.Files, func(file *ManifestFile) (string, bool) {
+		return file.Key, true
+	})
+	for _, oldKey := range keys {
+		if oldKey == key {
+			continue
+		}
+		old, err := s.readManifest(ctx, oldKey)
+		if err != nil {
+			return err
+		}
+		if old.To < manifest.From || old.From > manifest.To {
+			continue
+		}
+		if old.From < manifest.From || old.To > manifest.To {
+			// the other checkpoints of the old run are only stored there
+			logger.Warnf("checkpoints %v-%v are stored twice, in the runs %v-%v and %v-%v",
+				max(old.From, manifest.From), min(old.To, manifest.To), old.From, old.To, manifest.From, manifest.To)
+			continue
+		}
+		if err := s.files.remove(ctx, lo.Filter(old.Files, func(file *ManifestFile, _ int) bool {
+			return !stored[file.Key]
+		})); err != nil {
+			return err
+		}
+		if err := s.files.storage.Delete(ctx, oldKey); err != nil {
+			return fmt.Errorf("failed to delete %v: %v", oldKey, err)
+		}
+	}
+	return nil
+}
+
+func (s *rollupSink) readManifest(ctx context.Context, key string) (*Manifest, error) {
+	r, err := s.files.storage.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v: %v", key, err)
+	}
+	defer r.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest %v: %v", key, err)
+	}
+	return &manifest, nil
+}
+
+// Close uploads the pending windows, waits for all uploads and returns the errors of the failed ones
+func (s *rollupSink) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for _, window := range s.windows {
+		s.detach(context.Background(), window)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.errs...)
+}
+
+// splitRuns splits the sorted batches where a checkpoint does not follow the previous one, the date key changes
+// or the run would hold more than maxRecords records
+func splitRuns(batches []*rollupBatch, maxRecords int) [][]*rollupBatch {
+	var (
+		runs    = make([][]*rollupBatch, 0)
+		run     []*rollupBatch
+		records int
+	)
+	for _, pending := range batches {
+		if len(run) > 0 {
+			last := run[len(run)-1]
+			if pending.seq != last.seq+1 ||
+				pending.batch.DateKey != last.batch.DateKey ||
+				(maxRecords > 0 && records+pending.batch.Len() > maxRecords) {
+				runs = append(runs, run)
+				run, records = nil, 0
+			}
+		}
+		run = append(run, pending)
+		records += pending.batch.Len()
+	}
+	if len(run) > 0 {
+		runs = append(runs, run)
+	}
+	return runs
+}
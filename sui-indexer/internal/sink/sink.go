@@ -0,0 +1,181 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/getnimbus/ultrago/u_logger"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+
+	"feng-sui-core/internal/conf"
+	"feng-sui-core/internal/infra"
+	gorm_repo "feng-sui-core/internal/repo/gorm"
+	"feng-sui-core/internal/service"
+)
+
+// datasets are the kinds of records emitted for a checkpoint, files of a dataset are stored under its name
+const (
+	Dataset_CHECKPOINTS     = "checkpoints"
+	Dataset_TXS             = "txs"
+	Dataset_EVENTS          = "events"
+	Dataset_INDEX           = "index"
+	Dataset_OBJECT_CHANGES  = "object-changes"
+	Dataset_BALANCE_CHANGES = "balance-changes"
+)
+
+// sink names used in WORKER_SINKS and BACKFILL_SINKS
+const (
+	Name_KAFKA      = "kafka"
+	Name_S3         = "s3"
+	Name_S3_PARQUET = "s3-parquet"
+	Name_LOCAL      = "local"
+	Name_STDOUT     = "stdout"
+	Name_POSTGRES   = "postgres"
+	Name_SUI_INDEX  = "sui-index"
+)
+
+// Sink writes the records of checkpoints somewhere
+type Sink interface {
+	// Write writes all streams of batch, batch is either written completely or Write returns an error
+	Write(ctx context.Context, batch *Batch) error
+}
+
+// Batch is everything emitted for one checkpoint
+type Batch struct {
+	DateKey    string
+	Checkpoint string
+	Streams    []*Stream
+}
+
+// Stream is the records of a dataset sent to a topic
+type Stream struct {
+	Dataset string
+	Topic   string
+	Records []infra.KafkaMsg
+}
+
+// Add appends records to the stream of dataset and topic
+func (b *Batch) Add(dataset string, topic string, records ...infra.KafkaMsg) {
+	for _, stream := range b.Streams {
+		if stream.Dataset == dataset && stream.Topic == topic {
+			stream.Records = append(stream.Records, records...)
+			return
+		}
+	}
+	b.Streams = append(b.Streams, &Stream{
+		Dataset: dataset,
+		Topic:   topic,
+		Records: records,
+	})
+}
+
+// Len returns the number of records of all streams
+func (b *Batch) Len() int {
+	return lo.SumBy(b.Streams, func(stream *Stream) int {
+		return len(stream.Records)
+	})
+}
+
+// New builds the sink from comma separated sink names, several names fan out every batch to all of them.
+// Clients are only created for the sinks in use, the returned func releases them.
+// The s3 sinks group the checkpoints into roll-ups bounded by rollup.
+func New(ctx context.Context, names string, db *gorm.DB, rollup RollupConfig) (Sink, func(), error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	var (
+		sinks    = make([]Sink, 0)
+		cleanups = make([]func(), 0)
+		cleanup  = func() {
+			for i := len(cleanups) - 1; i >= 0; i-- {
+				cleanups[i]()
+			}
+		}
+		withRollup = func(name string, files *fileSink) Sink {
+			if rollup.Checkpoints < 2 {
+				return files
+			}
+			rollupSink := newRollupSink(files, rollup)
+			cleanups = append(cleanups, func() {
+				if err := rollupSink.Close(); err != nil {
+					logger.Errorf("failed to close %v sink: %v", name, err)
+				}
+			})
+			return rollupSink
+		}
+	)
+	for _, name := range lo.Uniq(strings.Split(names, ",")) {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+			continue
+		case Name_KAFKA:
+			producer, producerCleanup, err := infra.NewKafkaTxProducer(ctx)
+			if err != nil {
+				cleanup()
+				return nil, nil, err
+			}
+			cleanups = append(cleanups, producerCleanup)
+			sinks = append(sinks, NewKafkaSink(producer))
+		case Name_S3:
+			sess, err := infra.NewAwsSession()
+			if err != nil {
+				cleanup()
+				return nil, nil, err
+			}
+			sinks = append(sinks, withRollup(Name_S3, newS3JsonFiles(service.NewS3Service(sess), conf.Config.AwsBucket)))
+		case Name_S3_PARQUET:
+			sess, err := infra.NewAwsSession()
+			if err != nil {
+				cleanup()
+				return nil, nil, err
+			}
+			sinks = append(sinks, withRollup(Name_S3_PARQUET, newS3ParquetFiles(service.NewS3Service(sess), conf.Config.AwsBucket, conf.Config.S3ParquetPrefix)))
+		case Name_LOCAL:
+			localSink, err := NewLocalSink(conf.Config.SinkLocalDir, conf.Config.SinkLocalFormat, conf.Config.SinkLocalMaxFileSize)
+			if err != nil {
+				cleanup()
+				return nil, nil, err
+			}
+			cleanups = append(cleanups, func() {
+				if err := localSink.Close(); err != nil {
+					logger.Errorf("failed to close local sink: %v", err)
+				}
+			})
+			sinks = append(sinks, localSink)
+		case Name_STDOUT:
+			sinks = append(sinks, NewStdoutSink())
+		case Name_POSTGRES:
+			if db == nil {
+				cleanup()
+				return nil, nil, fmt.Errorf("postgres sink is not available without database")
+			}
+			sinks = append(sinks, NewPostgresSink(gorm_repo.NewSinkRecordRepo(gorm_repo.NewBaseRepo(db))))
+		case Name_SUI_INDEX:
+			if db == nil {
+				cleanup()
+				return nil, nil, fmt.Errorf("sui-index sink is not available without database")
+			}
+			sinks = append(sinks, NewSuiIndexSink(gorm_repo.NewSuiIndexRepo(gorm_repo.NewBaseRepo(db))))
+		default:
+			cleanup()
+			return nil, nil, fmt.Errorf("unsupported sink %v", name)
+		}
+	}
+	if len(sinks) == 0 {
+		cleanup()
+		return nil, nil, fmt.Errorf("missing sinks")
+	}
+	return NewFanoutSink(sinks...), cleanup, nil
+}
+
+// WritesIndexTable reports whether the sinks of names fill the table sui_index, directly or through the sui-index kafka connector
+func WritesIndexTable(names string) bool {
+	for _, name := range strings.Split(names, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case Name_KAFKA, Name_SUI_INDEX:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,388 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coming-chat/go-sui/v2/types"
+	"github.com/parquet-go/parquet-go"
+	"github.com/samber/lo"
+	"github.com/smartystreets/goconvey/convey"
+	"golang.org/x/sync/errgroup"
+
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/entity_dto/sui_model"
+	"feng-sui-core/internal/infra"
+)
+
+type testRecord struct {
+	Key   string `json:"key"`
+	Value int    `json:"value"`
+}
+
+func (r *testRecord) PartitionKey() string {
+	return r.Key
+}
+
+type errSink struct {
+	err error
+}
+
+func (s *errSink) Write(ctx context.Context, batch *Batch) error {
+	return s.err
+}
+
+func newTestBatch() *Batch {
+	batch := &Batch{DateKey: "2024-04-01", Checkpoint: "100"}
+	batch.Add(Dataset_TXS, "sui-txs", &testRecord{Key: "a", Value: 1})
+	batch.Add(Dataset_TXS, "sui-txs", &testRecord{Key: "b", Value: 2})
+	batch.Add(Dataset_CHECKPOINTS, "sui-checkpoints", &testRecord{Key: "100"})
+	batch.Add(Dataset_EVENTS, "sui-events")
+	return batch
+}
+
+func TestSink(t *testing.T) {
+	convey.Convey("TestSink", t, func() {
+		ctx := context.Background()
+
+		convey.Convey("Batch groups records by dataset and topic", func() {
+			batch := newTestBatch()
+			convey.So(batch.Streams, convey.ShouldHaveLength, 3)
+			convey.So(batch.Streams[0].Records, convey.ShouldHaveLength, 2)
+			convey.So(batch.Len(), convey.ShouldEqual, 3)
+		})
+
+		convey.Convey("Local sink appends json lines and rotates files", func() {
+			dir := t.TempDir()
+			localSink, err := NewLocalSink(dir, LocalFormat_GZIP, 30)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(localSink.Write(ctx, newTestBatch()), convey.ShouldBeNil)
+			next := &Batch{DateKey: "2024-04-01", Checkpoint: "101"}
+			next.Add(Dataset_TXS, "sui-txs", &testRecord{Key: "c", Value: 3})
+			convey.So(localSink.Write(ctx, next), convey.ShouldBeNil)
+			convey.So(localSink.Close(), convey.ShouldBeNil)
+
+			// the first file is rotated after 2 lines, the next batch opens a new file
+			convey.So(readGzipLines(filepath.Join(dir, "txs", "sui-txs", "sui-txs-100.jsonl.gz")), convey.ShouldResemble,
+				[]string{`{"key":"a","value":1}`, `{"key":"b","value":2}`})
+			convey.So(readGzipLines(filepath.Join(dir, "txs", "sui-txs", "sui-txs-101.jsonl.gz")), convey.ShouldResemble,
+				[]string{`{"key":"c","value":3}`})
+			convey.So(readGzipLines(filepath.Join(dir, "checkpoints", "sui-checkpoints", "sui-checkpoints-100.jsonl.gz")), convey.ShouldResemble,
+				[]string{`{"key":"100","value":0}`})
+			_, err = os.Stat(filepath.Join(dir, "events"))
+			convey.So(os.IsNotExist(err), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Parquet files have the columns of the athena tables", func() {
+			var buf bytes.Buffer
+			convey.So(writeParquet(&buf, Dataset_TXS, []infra.KafkaMsg{&sui_model.Transaction{
+				Digest:      "digest",
+				Checkpoint:  "100",
+				TimestampMs: "1712000000000",
+				Events:      []types.SuiEvent{},
+			}}), convey.ShouldBeNil)
+
+			rows, err := parquet.Read[txRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(rows, convey.ShouldResemble, []txRow{{
+				Digest:         "digest",
+				TimestampMs:    1712000000000,
+				Checkpoint:     100,
+				Transaction:    "null",
+				Effects:        "null",
+				Events:         "[]",
+				ObjectChanges:  "null",
+				BalanceChanges: "null",
+			}})
+
+			file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(file.Schema().Columns(), convey.ShouldResemble, [][]string{
+				{"digest"}, {"timestampms"}, {"checkpoint"}, {"transaction"}, {"effects"}, {"events"}, {"objectchanges"}, {"balancechanges"},
+			})
+
+			buf.Reset()
+			convey.So(writeParquet(&buf, Dataset_INDEX, []infra.KafkaMsg{(&entity.SuiIndex{
+				DateKey:       "2024-04-01",
+				CheckpointSeq: 100,
+				TxDigest:      "digest",
+				EventSeq:      1,
+			}).ToKafka()}), convey.ShouldBeNil)
+			indexRows, err := parquet.Read[indexRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(indexRows, convey.ShouldResemble, []indexRow{{DateKey: "2024-04-01", CheckpointSeq: 100, TxDigest: "digest", EventSeq: 1}})
+
+			convey.So(writeParquet(&buf, "unknown", nil), convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Roll-up sink groups consecutive checkpoints into files named after their range", func() {
+			storage := &memStorage{files: make(map[string][]byte)}
+			rollupSink := newRollupSink(&fileSink{
+				storage:     storage,
+				dir:         func(stream *Stream) string { return stream.Topic },
+				ext:         "json.gz",
+				manifestDir: "manifests",
+				write: func(w io.Writer, stream *Stream) error {
+					return writeJsonLines(w, stream.Records)
+				},
+			}, RollupConfig{Checkpoints: 4, MaxWait: 50 * time.Millisecond})
+
+			// a complete window is stored at once, a window with a gap is stored as two runs after MaxWait
+			var eg errgroup.Group
+			for _, seq := range []int{0, 1, 2, 3, 4, 5, 7} {
+				batch := &Batch{DateKey: "2024-04-01", Checkpoint: strconv.Itoa(seq)}
+				batch.Add(Dataset_TXS, "sui-txs", &testRecord{Key: strconv.Itoa(seq), Value: seq})
+				eg.Go(func() error {
+					return rollupSink.Write(ctx, batch)
+				})
+			}
+			convey.So(eg.Wait(), convey.ShouldBeNil)
+			convey.So(rollupSink.Close(), convey.ShouldBeNil)
+
+			convey.So(lo.Keys(storage.files), convey.ShouldHaveLength, 6)
+			convey.So(readGzipBytes(storage.files["sui-txs/datekey=2024-04-01/0-3.json.gz"]), convey.ShouldResemble, []string{
+				`{"key":"0","value":0}`, `{"key":"1","value":1}`, `{"key":"2","value":2}`, `{"key":"3","value":3}`,
+			})
+			convey.So(readGzipBytes(storage.files["sui-txs/datekey=2024-04-01/7-7.json.gz"]), convey.ShouldResemble, []string{
+				`{"key":"7","value":7}`,
+			})
+
+			var manifest Manifest
+			convey.So(json.Unmarshal(storage.files[ManifestKey("manifests", 4, 4, 5)], &manifest), convey.ShouldBeNil)
+			convey.So(manifest.From, convey.ShouldEqual, 4)
+			convey.So(manifest.To, convey.ShouldEqual, 5)
+			convey.So(manifest.Files, convey.ShouldResemble, []*ManifestFile{{
+				Key:     "sui-txs/datekey=2024-04-01/4-5.json.gz",
+				Dataset: Dataset_TXS,
+				Topic:   "sui-txs",
+				Records: 2,
+			}})
+
+			convey.So(rollupSink.Write(ctx, newTestBatch()), convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Inventory finds the stored checkpoints and counts their txs", func() {
+			files := &fileSink{
+				storage:     &memStorage{files: make(map[string][]byte)},
+				dir:         func(stream *Stream) string { return stream.Topic },
+				ext:         "json.gz",
+				manifestDir: "manifests",
+				write: func(w io.Writer, stream *Stream) error {
+					return writeJsonLines(w, stream.Records)
+				},
+			}
+			rollupSink := newRollupSink(files, RollupConfig{Checkpoints: 4, MaxWait: 10 * time.Millisecond})
+			var eg errgroup.Group
+			for seq, storedTxs := range []int{2, 2, 1} {
+				checkpoint := strconv.Itoa(seq)
+				batch := &Batch{DateKey: "2024-04-01", Checkpoint: checkpoint}
+				batch.Add(Dataset_CHECKPOINTS, "sui-checkpoints", &sui_model.Checkpoint{
+					SequenceNumber: checkpoint,
+					Digest:         "digest-" + checkpoint,
+					Transactions:   []string{"a" + checkpoint, "b" + checkpoint},
+				})
+				for _, digest := range []string{"a", "b"}[:storedTxs] {
+					batch.Add(Dataset_TXS, "sui-txs", &sui_model.Transaction{Checkpoint: checkpoint, Digest: digest + checkpoint})
+				}
+				eg.Go(func() error {
+					return rollupSink.Write(ctx, batch)
+				})
+			}
+			convey.So(eg.Wait(), convey.ShouldBeNil)
+			convey.So(rollupSink.Close(), convey.ShouldBeNil)
+
+			inventory := &Inventory{files: files, windowSize: 4}
+			storedCheckpoints, err := inventory.Checkpoints(ctx, 1, 3, true)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(storedCheckpoints, convey.ShouldHaveLength, 3)
+			convey.So(storedCheckpoints[0].Checkpoint.Digest, convey.ShouldEqual, "digest-1")
+			convey.So(storedCheckpoints[0].Complete(), convey.ShouldBeTrue)
+			convey.So(storedCheckpoints[1].Stored(), convey.ShouldBeTrue)
+			convey.So(storedCheckpoints[1].StoredTxs, convey.ShouldEqual, 1)
+			convey.So(storedCheckpoints[1].Complete(), convey.ShouldBeFalse)
+			convey.So(storedCheckpoints[2].Stored(), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Roll-up writes fail when the files are not stored", func() {
+			storage := &memStorage{files: make(map[string][]byte), err: errors.New("boom")}
+			rollupSink := newRollupSink(&fileSink{
+				storage:     storage,
+				dir:         func(stream *Stream) string { return stream.Topic },
+				ext:         "json.gz",
+				manifestDir: "manifests",
+				write: func(w io.Writer, stream *Stream) error {
+					return writeJsonLines(w, stream.Records)
+				},
+			}, RollupConfig{Checkpoints: 4, MaxWait: 10 * time.Millisecond})
+			convey.So(rollupSink.Write(ctx, newTestBatch()), convey.ShouldNotBeNil)
+			convey.So(rollupSink.Close(), convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Roll-up runs leave no files without a manifest", func() {
+			storage := &memStorage{files: make(map[string][]byte)}
+			files := &fileSink{
+				storage:     storage,
+				dir:         func(stream *Stream) string { return stream.Topic },
+				ext:         "json.gz",
+				manifestDir: "manifests",
+				write: func(w io.Writer, stream *Stream) error {
+					return writeJsonLines(w, stream.Records)
+				},
+			}
+			newBatch := func(seq int) *Batch {
+				batch := &Batch{DateKey: "2024-04-01", Checkpoint: strconv.Itoa(seq)}
+				batch.Add(Dataset_TXS, "sui-txs", &testRecord{Key: strconv.Itoa(seq), Value: seq})
+				batch.Add(Dataset_EVENTS, "sui-events", &testRecord{Key: strconv.Itoa(seq), Value: seq})
+				return batch
+			}
+			writeAll := func(rollupSink RollupSink, seqs ...int) error {
+				var eg errgroup.Group
+				for _, seq := range seqs {
+					batch := newBatch(seq)
+					eg.Go(func() error {
+						return rollupSink.Write(ctx, batch)
+					})
+				}
+				err := eg.Wait()
+				return errors.Join(err, rollupSink.Close())
+			}
+			config := RollupConfig{Checkpoints: 4, MaxWait: 10 * time.Millisecond}
+
+			// the events file of the run fails, the txs file already stored is deleted
+			storage.failKey = "sui-events"
+			convey.So(writeAll(newRollupSink(files, config), 0, 1), convey.ShouldNotBeNil)
+			convey.So(storage.files, convey.ShouldBeEmpty)
+
+			// the checkpoints are stored by two runs, then again by one run which replaces them
+			storage.failKey = ""
+			convey.So(writeAll(newRollupSink(files, config), 0, 1), convey.ShouldBeNil)
+			convey.So(writeAll(newRollupSink(files, config), 2), convey.ShouldBeNil)
+			convey.So(writeAll(newRollupSink(files, config), 0, 1, 2), convey.ShouldBeNil)
+			convey.So(lo.Keys(storage.files), convey.ShouldHaveLength, 3)
+			convey.So(storage.files, convey.ShouldContainKey, ManifestKey("manifests", 0, 0, 2))
+			convey.So(storage.files, convey.ShouldContainKey, "sui-txs/datekey=2024-04-01/0-2.json.gz")
+			convey.So(storage.files, convey.ShouldContainKey, "sui-events/datekey=2024-04-01/0-2.json.gz")
+
+			inventory := &Inventory{files: files, windowSize: 4}
+			manifests, err := inventory.Manifests(ctx, 0, 3)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(manifests, convey.ShouldHaveLength, 1)
+		})
+
+		convey.Convey("Stdout sink prints topic, key and value", func() {
+			var out bytes.Buffer
+			s := &stdoutSink{out: &out}
+			convey.So(s.Write(ctx, newTestBatch()), convey.ShouldBeNil)
+			convey.So(out.String(), convey.ShouldStartWith, `{"topic":"sui-txs","key":"a","value":{"key":"a","value":1}}`+"\n")
+		})
+
+		convey.Convey("Fanout sink fails when any sink fails", func() {
+			dir := t.TempDir()
+			localSink, _ := NewLocalSink(dir, LocalFormat_JSONL, 1024)
+			err := NewFanoutSink(localSink, &errSink{err: errors.New("boom")}).Write(ctx, newTestBatch())
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Unknown sinks are rejected", func() {
+			_, _, err := New(ctx, "local,unknown", nil, RollupConfig{})
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+	})
+}
+
+func readGzipLines(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+func readGzipBytes(data []byte) []string {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+type memStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	err   error
+	// failKey makes the puts of the keys containing it fail
+	failKey string
+}
+
+func (s *memStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memStorage) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return lo.Filter(lo.Keys(s.files), func(key string, _ int) bool {
+		return strings.HasPrefix(key, prefix)
+	}), nil
+}
+
+func (s *memStorage) Put(ctx context.Context, key string, write func(w io.Writer) error) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.failKey != "" && strings.Contains(key, s.failKey) {
+		return errors.New("boom")
+	}
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[key] = buf.Bytes()
+	return nil
+}
+
+func (s *memStorage) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.files, key)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// NewStdoutSink prints every record as a json line {"topic": ..., "key": ..., "value": ...}, for debugging
+func NewStdoutSink() Sink {
+	return &stdoutSink{
+		out: os.Stdout,
+	}
+}
+
+type stdoutSink struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+type stdoutRecord struct {
+	Topic string      `json:"topic"`
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+func (s *stdoutSink) Write(ctx context.Context, batch *Batch) error {
+	// batches are printed whole, never interleaved with the other writers
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := bufio.NewWriter(s.out)
+	enc := json.NewEncoder(w)
+	for _, stream := range batch.Streams {
+		for _, record := range stream.Records {
+			if err := enc.Encode(&stdoutRecord{
+				Topic: stream.Topic,
+				Key:   record.PartitionKey(),
+				Value: record,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return w.Flush()
+}
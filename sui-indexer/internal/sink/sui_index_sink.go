@@ -0,0 +1,38 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+
+	"feng-sui-core/internal/entity"
+	"feng-sui-core/internal/repo"
+)
+
+// NewSuiIndexSink stores the index records in the table sui_index, like the sui-index kafka connector,
+// the other datasets are skipped
+func NewSuiIndexSink(suiIndexRepo repo.SuiIndexRepo) Sink {
+	return &suiIndexSink{
+		suiIndexRepo: suiIndexRepo,
+	}
+}
+
+type suiIndexSink struct {
+	suiIndexRepo repo.SuiIndexRepo
+}
+
+func (s *suiIndexSink) Write(ctx context.Context, batch *Batch) error {
+	var rows = make([]*entity.SuiIndex, 0)
+	for _, stream := range batch.Streams {
+		if stream.Dataset != Dataset_INDEX {
+			continue
+		}
+		for _, record := range stream.Records {
+			item, ok := record.(*entity.SuiIndexKafka)
+			if !ok || item.Index == nil {
+				return fmt.Errorf("unexpected index record %T", record)
+			}
+			rows = append(rows, item.Index)
+		}
+	}
+	return s.suiIndexRepo.CreateMany(ctx, rows...)
+}
@@ -0,0 +1,164 @@
+package sui_cert
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+/*
+ * Binary Canonical Serialization, the encoding of the sui types
+ * https://github.com/diem/bcs
+ */
+
+// bcsReader decodes BCS values from data, the first error is kept and the next reads return zero values
+type bcsReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *bcsReader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf("offset %v: %v", r.pos, fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *bcsReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data)-r.pos {
+		r.fail("%v bytes past the end of the data", n)
+		return nil
+	}
+	res := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return res
+}
+
+func (r *bcsReader) peek() byte {
+	if r.err != nil || r.pos >= len(r.data) {
+		r.fail("unexpected end of the data")
+		return 0
+	}
+	return r.data[r.pos]
+}
+
+func (r *bcsReader) u8() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *bcsReader) u64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+// uleb reads the ULEB128 lengths and enum variants, which are at most a u32
+func (r *bcsReader) uleb() int {
+	var value uint64
+	for shift := 0; shift < 32; shift += 7 {
+		b := r.u8()
+		if r.err != nil {
+			return 0
+		}
+		value |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			if value > math.MaxUint32 {
+				r.fail("uleb128 overflows a u32")
+				return 0
+			}
+			return int(value)
+		}
+	}
+	r.fail("uleb128 overflows a u32")
+	return 0
+}
+
+// length reads the length of a vector, each item taking at least itemSize bytes
+func (r *bcsReader) length(itemSize int) int {
+	n := r.uleb()
+	if r.err == nil && n*itemSize > len(r.data)-r.pos {
+		r.fail("vector of %v items past the end of the data", n)
+		return 0
+	}
+	return n
+}
+
+func (r *bcsReader) vecBytes() []byte {
+	return r.bytes(r.length(1))
+}
+
+func (r *bcsReader) option() bool {
+	switch r.u8() {
+	case 0:
+		return false
+	case 1:
+		return true
+	default:
+		r.fail("invalid option tag")
+		return false
+	}
+}
+
+func (r *bcsReader) digest() Digest {
+	var d Digest
+	if n := r.uleb(); r.err == nil && n != len(d) {
+		r.fail("digest of %v bytes", n)
+	}
+	copy(d[:], r.bytes(len(d)))
+	return d
+}
+
+// fixedBytes reads n bytes which are serialized either as a vector or as a fixed array.
+// The first byte of a compressed BLS12-381 point always has its high bit set, so a
+// length byte equal to n cannot be mistaken for it.
+func (r *bcsReader) fixedBytes(n int) []byte {
+	if r.peek() == byte(n) {
+		r.pos++
+	}
+	return r.bytes(n)
+}
+
+// bcsWriter encodes BCS values
+type bcsWriter struct {
+	data []byte
+}
+
+func (w *bcsWriter) u8(v byte) {
+	w.data = append(w.data, v)
+}
+
+func (w *bcsWriter) u64(v uint64) {
+	w.data = binary.LittleEndian.AppendUint64(w.data, v)
+}
+
+func (w *bcsWriter) uleb(v int) {
+	for v >= 0x80 {
+		w.data = append(w.data, byte(v)|0x80)
+		v >>= 7
+	}
+	w.data = append(w.data, byte(v))
+}
+
+func (w *bcsWriter) vecBytes(v []byte) {
+	w.uleb(len(v))
+	w.data = append(w.data, v...)
+}
+
+func (w *bcsWriter) option(some bool) {
+	if some {
+		w.u8(1)
+	} else {
+		w.u8(0)
+	}
+}
+
+func (w *bcsWriter) digest(d Digest) {
+	w.vecBytes(d[:])
+}
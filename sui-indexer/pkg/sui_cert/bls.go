@@ -0,0 +1,112 @@
+package sui_cert
+
+import (
+	"fmt"
+	"math/big"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+)
+
+/*
+ * The validators sign with min-sig BLS12-381: signatures in G1, public keys in G2
+ * https://www.ietf.org/archive/id/draft-irtf-cfrg-bls-signature-05.html
+ */
+
+// signatureDst is the domain separation tag of the hash to G1
+var signatureDst = []byte("BLS_SIG_BLS12381G1_XMD:SHA-256_SSWU_RO_NUL_")
+
+func parseSignature(signature []byte) (*bls12381.G1Affine, error) {
+	if len(signature) != signatureLength {
+		return nil, fmt.Errorf("signature of %v bytes", len(signature))
+	}
+	var point bls12381.G1Affine
+	// SetBytes checks that the point is in the subgroup
+	if _, err := point.SetBytes(signature); err != nil {
+		return nil, fmt.Errorf("invalid signature: %v", err)
+	}
+	if point.IsInfinity() {
+		return nil, fmt.Errorf("invalid signature: point at infinity")
+	}
+	return &point, nil
+}
+
+func parsePublicKey(publicKey []byte) (*bls12381.G2Affine, error) {
+	if len(publicKey) != publicKeyLength {
+		return nil, fmt.Errorf("public key of %v bytes", len(publicKey))
+	}
+	var point bls12381.G2Affine
+	if _, err := point.SetBytes(publicKey); err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	}
+	if point.IsInfinity() {
+		return nil, fmt.Errorf("invalid public key: point at infinity")
+	}
+	return &point, nil
+}
+
+// verifyAggregate checks that signature is the aggregate of the signatures of msg by publicKeys
+func verifyAggregate(signature []byte, msg []byte, publicKeys []*bls12381.G2Affine) error {
+	sig, err := parseSignature(signature)
+	if err != nil {
+		return err
+	}
+	var aggregate bls12381.G2Jac
+	for _, publicKey := range publicKeys {
+		aggregate.AddMixed(publicKey)
+	}
+	var aggregateKey bls12381.G2Affine
+	aggregateKey.FromJacobian(&aggregate)
+
+	hash, err := bls12381.HashToG1(msg, signatureDst)
+	if err != nil {
+		return err
+	}
+	// e(sig, g2) == e(H(msg), pk) <=> e(sig, -g2) * e(H(msg), pk) == 1
+	_, _, _, g2 := bls12381.Generators()
+	var negG2 bls12381.G2Affine
+	negG2.Neg(&g2)
+	ok, err := bls12381.PairingCheck([]bls12381.G1Affine{*sig, hash}, []bls12381.G2Affine{negG2, aggregateKey})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("invalid aggregate signature")
+	}
+	return nil
+}
+
+// PublicKey returns the compressed public key of secretKey
+func PublicKey(secretKey *big.Int) []byte {
+	_, _, _, g2 := bls12381.Generators()
+	var publicKey bls12381.G2Affine
+	publicKey.ScalarMultiplication(&g2, secretKey)
+	res := publicKey.Bytes()
+	return res[:]
+}
+
+// Sign signs msg with secretKey like a validator, the signatures of a checkpoint are combined with AggregateSignatures
+func Sign(secretKey *big.Int, msg []byte) ([]byte, error) {
+	hash, err := bls12381.HashToG1(msg, signatureDst)
+	if err != nil {
+		return nil, err
+	}
+	var signature bls12381.G1Affine
+	signature.ScalarMultiplication(&hash, secretKey)
+	res := signature.Bytes()
+	return res[:], nil
+}
+
+func AggregateSignatures(signatures ...[]byte) ([]byte, error) {
+	var aggregate bls12381.G1Jac
+	for _, signature := range signatures {
+		sig, err := parseSignature(signature)
+		if err != nil {
+			return nil, err
+		}
+		aggregate.AddMixed(sig)
+	}
+	var res bls12381.G1Affine
+	res.FromJacobian(&aggregate)
+	b := res.Bytes()
+	return b[:], nil
+}
@@ -0,0 +1,356 @@
+package sui_cert
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/coming-chat/go-sui/v2/lib"
+	"golang.org/x/crypto/blake2b"
+)
+
+/*
+ * The certified checkpoints of sui, as served by the full node REST API and the checkpoint archives
+ * https://github.com/MystenLabs/sui/blob/main/crates/sui-types/src/messages_checkpoint.rs
+ */
+
+// Digest is a blake2b-256 hash, printed in base58 like the digests of the json-rpc
+type Digest [32]byte
+
+func (d Digest) String() string {
+	return lib.Base58(d[:]).String()
+}
+
+type GasCostSummary struct {
+	ComputationCost         uint64
+	StorageCost             uint64
+	StorageRebate           uint64
+	NonRefundableStorageFee uint64
+}
+
+// Commitment is a CheckpointCommitment, Kind is its enum variant
+type Commitment struct {
+	Kind   int
+	Digest Digest
+}
+
+// Member is a validator of a committee, PublicKey is a compressed BLS12-381 G2 point
+type Member struct {
+	PublicKey []byte
+	Stake     uint64
+}
+
+// EndOfEpochData is carried by the last checkpoint of an epoch
+type EndOfEpochData struct {
+	NextEpochCommittee       []Member
+	NextEpochProtocolVersion uint64
+	EpochCommitments         []Commitment
+}
+
+type CheckpointSummary struct {
+	Epoch                      uint64
+	SequenceNumber             uint64
+	NetworkTotalTransactions   uint64
+	ContentDigest              Digest
+	PreviousDigest             *Digest
+	EpochRollingGasCostSummary GasCostSummary
+	TimestampMs                uint64
+	CheckpointCommitments      []Commitment
+	EndOfEpochData             *EndOfEpochData
+	VersionSpecificData        []byte
+}
+
+// SignInfo is the AuthorityStrongQuorumSignInfo of a checkpoint: the aggregate signature of the
+// signers, which are indexes in the committee of the epoch
+type SignInfo struct {
+	Epoch     uint64
+	Signature []byte
+	Signers   []uint32
+}
+
+// ExecutionDigests are the digests of a tx and its effects
+type ExecutionDigests struct {
+	Transaction Digest
+	Effects     Digest
+}
+
+// CheckpointContents lists the txs of a checkpoint in execution order
+type CheckpointContents struct {
+	Transactions   []ExecutionDigests
+	UserSignatures [][][]byte
+}
+
+// CertifiedCheckpoint is a checkpoint summary with its signature and contents. SummaryBcs and ContentsBcs are
+// the bytes the digests and the signature are computed on.
+type CertifiedCheckpoint struct {
+	Summary     *CheckpointSummary
+	SummaryBcs  []byte
+	SignInfo    *SignInfo
+	Contents    *CheckpointContents
+	ContentsBcs []byte
+}
+
+const (
+	// the BCS encoding of the blob files of the checkpoint archives
+	blobEncodingBcs = 1
+
+	// the lengths of the compressed BLS12-381 points
+	signatureLength = 48
+	publicKeyLength = 96
+
+	// CheckpointCommitment variants
+	commitmentEcmhLiveObjectSet   = 0
+	commitmentCheckpointArtifacts = 1
+)
+
+// checkpointSummaryIntent is the intent of the signed checkpoint summaries: scope CheckpointSummary, version V0, app Sui
+var checkpointSummaryIntent = []byte{2, 0, 0}
+
+// ParseCheckpointBlob parses a checkpoint file of the archives, a CheckpointData prefixed with its encoding
+func ParseCheckpointBlob(data []byte) (*CertifiedCheckpoint, error) {
+	if len(data) == 0 || data[0] != blobEncodingBcs {
+		return nil, fmt.Errorf("unsupported checkpoint blob encoding")
+	}
+	return ParseCheckpointData(data[1:])
+}
+
+// ParseCheckpointData parses the certified summary and the contents at the start of a BCS CheckpointData,
+// the transactions after them are not read
+func ParseCheckpointData(data []byte) (*CertifiedCheckpoint, error) {
+	r := &bcsReader{data: data}
+
+	start := r.pos
+	summary := readSummary(r)
+	summaryBcs := r.data[start:r.pos]
+	signInfo := readSignInfo(r)
+	start = r.pos
+	contents := readContents(r)
+	contentsBcs := r.data[start:r.pos]
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid checkpoint data: %v", r.err)
+	}
+
+	return &CertifiedCheckpoint{
+		Summary:     summary,
+		SummaryBcs:  summaryBcs,
+		SignInfo:    signInfo,
+		Contents:    contents,
+		ContentsBcs: contentsBcs,
+	}, nil
+}
+
+// NewCertifiedCheckpoint encodes summary and contents, SignInfo is left to the signers
+func NewCertifiedCheckpoint(summary *CheckpointSummary, contents *CheckpointContents) *CertifiedCheckpoint {
+	return &CertifiedCheckpoint{
+		Summary:     summary,
+		SummaryBcs:  summary.Marshal(),
+		SignInfo:    &SignInfo{Epoch: summary.Epoch},
+		Contents:    contents,
+		ContentsBcs: contents.Marshal(),
+	}
+}
+
+// Digest is the digest of the checkpoint, sequenceNumber and digest identify the checkpoint in the json-rpc
+func (c *CertifiedCheckpoint) Digest() Digest {
+	return hashWithName("CheckpointSummary", c.SummaryBcs)
+}
+
+// ContentsDigest is the digest of the contents, it must be the ContentDigest of the summary
+func (c *CertifiedCheckpoint) ContentsDigest() Digest {
+	return hashWithName("CheckpointContents", c.ContentsBcs)
+}
+
+// SignedMessage returns the bytes signed by the validators: the intent message of the summary followed by the epoch
+func (c *CertifiedCheckpoint) SignedMessage() []byte {
+	w := &bcsWriter{data: make([]byte, 0, len(checkpointSummaryIntent)+len(c.SummaryBcs)+8)}
+	w.data = append(w.data, checkpointSummaryIntent...)
+	w.data = append(w.data, c.SummaryBcs...)
+	w.u64(c.Summary.Epoch)
+	return w.data
+}
+
+// Marshal encodes the checkpoint as a CheckpointData without transactions
+func (c *CertifiedCheckpoint) Marshal() ([]byte, error) {
+	signersBcs, err := c.signersBcs()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &bcsWriter{}
+	w.data = append(w.data, c.SummaryBcs...)
+	w.u64(c.SignInfo.Epoch)
+	w.vecBytes(c.SignInfo.Signature)
+	w.vecBytes(signersBcs)
+	w.data = append(w.data, c.ContentsBcs...)
+	w.uleb(0) // transactions
+	return w.data, nil
+}
+
+// signersBcs encodes the signers like the sui RoaringBitmap, in the portable roaring format
+func (c *CertifiedCheckpoint) signersBcs() ([]byte, error) {
+	return roaring.BitmapOf(c.SignInfo.Signers...).ToBytes()
+}
+
+// hashWithName is the digest of the sui types: blake2b-256 of the type name and the BCS bytes
+func hashWithName(name string, bcs []byte) Digest {
+	h, _ := blake2b.New256(nil)
+	h.Write([]byte(name + "::"))
+	h.Write(bcs)
+	var d Digest
+	copy(d[:], h.Sum(nil))
+	return d
+}
+
+func readSummary(r *bcsReader) *CheckpointSummary {
+	s := &CheckpointSummary{
+		Epoch:                    r.u64(),
+		SequenceNumber:           r.u64(),
+		NetworkTotalTransactions: r.u64(),
+		ContentDigest:            r.digest(),
+	}
+	if r.option() {
+		previous := r.digest()
+		s.PreviousDigest = &previous
+	}
+	s.EpochRollingGasCostSummary = GasCostSummary{
+		ComputationCost:         r.u64(),
+		StorageCost:             r.u64(),
+		StorageRebate:           r.u64(),
+		NonRefundableStorageFee: r.u64(),
+	}
+	s.TimestampMs = r.u64()
+	s.CheckpointCommitments = readCommitments(r)
+	if r.option() {
+		eoe := &EndOfEpochData{}
+		n := r.length(publicKeyLength + 8)
+		for i := 0; i < n && r.err == nil; i++ {
+			eoe.NextEpochCommittee = append(eoe.NextEpochCommittee, Member{
+				PublicKey: r.fixedBytes(publicKeyLength),
+				Stake:     r.u64(),
+			})
+		}
+		eoe.NextEpochProtocolVersion = r.u64()
+		eoe.EpochCommitments = readCommitments(r)
+		s.EndOfEpochData = eoe
+	}
+	s.VersionSpecificData = r.vecBytes()
+	return s
+}
+
+func readCommitments(r *bcsReader) []Commitment {
+	var (
+		n           = r.length(1)
+		commitments []Commitment
+	)
+	for i := 0; i < n && r.err == nil; i++ {
+		kind := r.uleb()
+		if kind != commitmentEcmhLiveObjectSet && kind != commitmentCheckpointArtifacts {
+			r.fail("unknown checkpoint commitment %v", kind)
+			return nil
+		}
+		commitments = append(commitments, Commitment{Kind: kind, Digest: r.digest()})
+	}
+	return commitments
+}
+
+func readSignInfo(r *bcsReader) *SignInfo {
+	info := &SignInfo{
+		Epoch:     r.u64(),
+		Signature: r.fixedBytes(signatureLength),
+	}
+	signersBcs := r.vecBytes()
+	if r.err != nil {
+		return info
+	}
+	signers := roaring.New()
+	if n, err := signers.ReadFrom(bytes.NewReader(signersBcs)); err != nil {
+		r.fail("invalid signers bitmap: %v", err)
+		return info
+	} else if n != int64(len(signersBcs)) {
+		r.fail("invalid signers bitmap: %v trailing bytes", int64(len(signersBcs))-n)
+		return info
+	}
+	info.Signers = signers.ToArray()
+	return info
+}
+
+func readContents(r *bcsReader) *CheckpointContents {
+	if version := r.uleb(); r.err == nil && version != 0 {
+		r.fail("unsupported checkpoint contents version %v", version)
+		return nil
+	}
+	contents := &CheckpointContents{}
+	n := r.length(2 * 33)
+	for i := 0; i < n && r.err == nil; i++ {
+		contents.Transactions = append(contents.Transactions, ExecutionDigests{
+			Transaction: r.digest(),
+			Effects:     r.digest(),
+		})
+	}
+	n = r.length(1)
+	for i := 0; i < n && r.err == nil; i++ {
+		m := r.length(1)
+		signatures := make([][]byte, 0, m)
+		for j := 0; j < m && r.err == nil; j++ {
+			signatures = append(signatures, r.vecBytes())
+		}
+		contents.UserSignatures = append(contents.UserSignatures, signatures)
+	}
+	return contents
+}
+
+func (s *CheckpointSummary) Marshal() []byte {
+	w := &bcsWriter{}
+	w.u64(s.Epoch)
+	w.u64(s.SequenceNumber)
+	w.u64(s.NetworkTotalTransactions)
+	w.digest(s.ContentDigest)
+	w.option(s.PreviousDigest != nil)
+	if s.PreviousDigest != nil {
+		w.digest(*s.PreviousDigest)
+	}
+	w.u64(s.EpochRollingGasCostSummary.ComputationCost)
+	w.u64(s.EpochRollingGasCostSummary.StorageCost)
+	w.u64(s.EpochRollingGasCostSummary.StorageRebate)
+	w.u64(s.EpochRollingGasCostSummary.NonRefundableStorageFee)
+	w.u64(s.TimestampMs)
+	writeCommitments(w, s.CheckpointCommitments)
+	w.option(s.EndOfEpochData != nil)
+	if eoe := s.EndOfEpochData; eoe != nil {
+		w.uleb(len(eoe.NextEpochCommittee))
+		for _, member := range eoe.NextEpochCommittee {
+			w.vecBytes(member.PublicKey)
+			w.u64(member.Stake)
+		}
+		w.u64(eoe.NextEpochProtocolVersion)
+		writeCommitments(w, eoe.EpochCommitments)
+	}
+	w.vecBytes(s.VersionSpecificData)
+	return w.data
+}
+
+func writeCommitments(w *bcsWriter, commitments []Commitment) {
+	w.uleb(len(commitments))
+	for _, commitment := range commitments {
+		w.uleb(commitment.Kind)
+		w.digest(commitment.Digest)
+	}
+}
+
+func (c *CheckpointContents) Marshal() []byte {
+	w := &bcsWriter{}
+	w.uleb(0) // V1
+	w.uleb(len(c.Transactions))
+	for _, tx := range c.Transactions {
+		w.digest(tx.Transaction)
+		w.digest(tx.Effects)
+	}
+	w.uleb(len(c.UserSignatures))
+	for _, signatures := range c.UserSignatures {
+		w.uleb(len(signatures))
+		for _, signature := range signatures {
+			w.vecBytes(signature)
+		}
+	}
+	return w.data
+}
@@ -0,0 +1,168 @@
+package sui_cert
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/smartystreets/goconvey/convey"
+)
+
+// testValidators returns the members of a committee and their secret keys, in committee order
+func testValidators(seed int64, stakes ...uint64) ([]Member, []*big.Int) {
+	var secretKeys = make([]*big.Int, 0, len(stakes))
+	for i := range stakes {
+		secretKeys = append(secretKeys, big.NewInt(seed*1000+int64(i)+1))
+	}
+	sort.Slice(secretKeys, func(i, j int) bool {
+		return bytes.Compare(PublicKey(secretKeys[i]), PublicKey(secretKeys[j])) < 0
+	})
+	var members = make([]Member, 0, len(stakes))
+	for i, stake := range stakes {
+		members = append(members, Member{PublicKey: PublicKey(secretKeys[i]), Stake: stake})
+	}
+	return members, secretKeys
+}
+
+// testSign signs checkpoint with the secret keys of signers
+func testSign(checkpoint *CertifiedCheckpoint, secretKeys []*big.Int, signers ...uint32) {
+	var signatures = make([][]byte, 0, len(signers))
+	for _, signer := range signers {
+		signature, err := Sign(secretKeys[signer], checkpoint.SignedMessage())
+		convey.So(err, convey.ShouldBeNil)
+		signatures = append(signatures, signature)
+	}
+	aggregate, err := AggregateSignatures(signatures...)
+	convey.So(err, convey.ShouldBeNil)
+	checkpoint.SignInfo = &SignInfo{Epoch: checkpoint.Summary.Epoch, Signature: aggregate, Signers: signers}
+}
+
+func TestCertifiedCheckpoint(t *testing.T) {
+	convey.Convey("TestCertifiedCheckpoint", t, func() {
+		members, secretKeys := testValidators(1, 2500, 2500, 2500, 2500)
+		committee, err := NewCommittee(5, members)
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(committee.QuorumThreshold(), convey.ShouldEqual, 6667)
+
+		nextMembers, nextSecretKeys := testValidators(2, 5000, 3000, 2000)
+		contents := &CheckpointContents{
+			Transactions:   []ExecutionDigests{{Transaction: Digest{1}, Effects: Digest{2}}, {Transaction: Digest{3}, Effects: Digest{4}}},
+			UserSignatures: [][][]byte{{[]byte("sig1")}, {[]byte("sig2"), []byte("sig3")}},
+		}
+		previous := Digest{9}
+		newCheckpoint := func() *CertifiedCheckpoint {
+			contentsDigest := hashWithName("CheckpointContents", contents.Marshal())
+			return NewCertifiedCheckpoint(&CheckpointSummary{
+				Epoch:                      5,
+				SequenceNumber:             1000,
+				NetworkTotalTransactions:   2002,
+				ContentDigest:              contentsDigest,
+				PreviousDigest:             &previous,
+				EpochRollingGasCostSummary: GasCostSummary{ComputationCost: 1, StorageCost: 2, StorageRebate: 3, NonRefundableStorageFee: 4},
+				TimestampMs:                1700000000000,
+				CheckpointCommitments:      []Commitment{{Kind: commitmentEcmhLiveObjectSet, Digest: Digest{7}}},
+				EndOfEpochData: &EndOfEpochData{
+					NextEpochCommittee:       nextMembers,
+					NextEpochProtocolVersion: 42,
+				},
+				VersionSpecificData: []byte{0, 1},
+			}, contents)
+		}
+
+		convey.Convey("Checkpoint data is parsed back with the same digests", func() {
+			checkpoint := newCheckpoint()
+			testSign(checkpoint, secretKeys, 0, 1, 3)
+			data, err := checkpoint.Marshal()
+			convey.So(err, convey.ShouldBeNil)
+
+			parsed, err := ParseCheckpointBlob(append([]byte{blobEncodingBcs}, data...))
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(parsed.Summary, convey.ShouldResemble, checkpoint.Summary)
+			convey.So(parsed.SignInfo, convey.ShouldResemble, checkpoint.SignInfo)
+			convey.So(parsed.Contents, convey.ShouldResemble, contents)
+			convey.So(parsed.Digest(), convey.ShouldEqual, checkpoint.Digest())
+			convey.So(parsed.ContentsDigest(), convey.ShouldEqual, parsed.Summary.ContentDigest)
+			convey.So(committee.Verify(parsed), convey.ShouldBeNil)
+
+			_, err = ParseCheckpointData(data[:len(data)-10])
+			convey.So(err, convey.ShouldNotBeNil)
+			_, err = ParseCheckpointBlob(data)
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("A quorum of the committee certifies the checkpoint", func() {
+			checkpoint := newCheckpoint()
+			testSign(checkpoint, secretKeys, 0, 1, 2, 3)
+			convey.So(committee.Verify(checkpoint), convey.ShouldBeNil)
+			testSign(checkpoint, secretKeys, 1, 2, 3)
+			convey.So(committee.Verify(checkpoint), convey.ShouldBeNil)
+		})
+
+		convey.Convey("A tampered summary fails", func() {
+			checkpoint := newCheckpoint()
+			testSign(checkpoint, secretKeys, 0, 1, 2)
+			checkpoint.Summary.NetworkTotalTransactions++
+			checkpoint.SummaryBcs = checkpoint.Summary.Marshal()
+			convey.So(committee.Verify(checkpoint), convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Signers without a quorum fail", func() {
+			checkpoint := newCheckpoint()
+			testSign(checkpoint, secretKeys, 0, 1)
+			convey.So(committee.Verify(checkpoint).Error(), convey.ShouldContainSubstring, "5000 of the 6667 stake")
+
+			// a missing signature of a signer listed in the bitmap
+			testSign(checkpoint, secretKeys, 0, 1, 2)
+			checkpoint.SignInfo.Signers = []uint32{0, 1, 2, 3}
+			convey.So(committee.Verify(checkpoint), convey.ShouldNotBeNil)
+
+			checkpoint.SignInfo.Signers = []uint32{0, 1, 2, 4}
+			convey.So(committee.Verify(checkpoint), convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("A checkpoint signed by another committee fails", func() {
+			checkpoint := newCheckpoint()
+			testSign(checkpoint, nextSecretKeys, 0, 1, 2)
+			convey.So(committee.Verify(checkpoint), convey.ShouldNotBeNil)
+
+			other, err := NewCommittee(6, nextMembers)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(other.Verify(checkpoint), convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("The last checkpoint of the epoch carries the next committee", func() {
+			checkpoint := newCheckpoint()
+			testSign(checkpoint, secretKeys, 0, 1, 2)
+			next, err := committee.NextCommittee(checkpoint)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(next.Epoch, convey.ShouldEqual, 6)
+			convey.So(next.Members, convey.ShouldResemble, nextMembers)
+			convey.So(next.QuorumThreshold(), convey.ShouldEqual, 6667)
+
+			checkpoint.Summary.EndOfEpochData = nil
+			checkpoint.SummaryBcs = checkpoint.Summary.Marshal()
+			testSign(checkpoint, secretKeys, 0, 1, 2)
+			_, err = committee.NextCommittee(checkpoint)
+			convey.So(err, convey.ShouldNotBeNil)
+		})
+
+		convey.Convey("Signatures may be encoded as fixed arrays", func() {
+			checkpoint := newCheckpoint()
+			testSign(checkpoint, secretKeys, 0, 1, 2)
+			w := &bcsWriter{}
+			w.data = append(w.data, checkpoint.SummaryBcs...)
+			w.u64(checkpoint.SignInfo.Epoch)
+			w.data = append(w.data, checkpoint.SignInfo.Signature...)
+			signers, err := (&CertifiedCheckpoint{SignInfo: checkpoint.SignInfo}).signersBcs()
+			convey.So(err, convey.ShouldBeNil)
+			w.vecBytes(signers)
+			w.data = append(w.data, checkpoint.ContentsBcs...)
+
+			parsed, err := ParseCheckpointData(w.data)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(parsed.SignInfo.Signature, convey.ShouldResemble, checkpoint.SignInfo.Signature)
+			convey.So(committee.Verify(parsed), convey.ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,89 @@
+package sui_cert
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+)
+
+// Committee is the validators of an epoch. They are sorted by public key, the signers of a SignInfo are
+// indexes in this order.
+type Committee struct {
+	Epoch   uint64
+	Members []Member
+	keys    []*bls12381.G2Affine
+	total   uint64
+}
+
+func NewCommittee(epoch uint64, members []Member) (*Committee, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("committee of epoch %v is empty", epoch)
+	}
+	sorted := append([]Member(nil), members...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].PublicKey, sorted[j].PublicKey) < 0
+	})
+
+	c := &Committee{
+		Epoch:   epoch,
+		Members: sorted,
+		keys:    make([]*bls12381.G2Affine, 0, len(sorted)),
+	}
+	for i, member := range sorted {
+		if i > 0 && bytes.Equal(member.PublicKey, sorted[i-1].PublicKey) {
+			return nil, fmt.Errorf("committee of epoch %v has a duplicated member", epoch)
+		}
+		key, err := parsePublicKey(member.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("committee of epoch %v: %v", epoch, err)
+		}
+		c.keys = append(c.keys, key)
+		c.total += member.Stake
+	}
+	if c.total == 0 {
+		return nil, fmt.Errorf("committee of epoch %v has no stake", epoch)
+	}
+	return c, nil
+}
+
+// QuorumThreshold is the stake a strong quorum must hold, more than 2/3 of the total stake
+func (c *Committee) QuorumThreshold() uint64 {
+	return c.total - (c.total-1)/3
+}
+
+// Verify checks that checkpoint is signed by a strong quorum of the committee
+func (c *Committee) Verify(checkpoint *CertifiedCheckpoint) error {
+	if checkpoint.Summary.Epoch != c.Epoch || checkpoint.SignInfo.Epoch != c.Epoch {
+		return fmt.Errorf("checkpoint %v of epoch %v is signed for epoch %v, the committee is of epoch %v",
+			checkpoint.Summary.SequenceNumber, checkpoint.Summary.Epoch, checkpoint.SignInfo.Epoch, c.Epoch)
+	}
+
+	var (
+		stake uint64
+		keys  = make([]*bls12381.G2Affine, 0, len(checkpoint.SignInfo.Signers))
+	)
+	for _, signer := range checkpoint.SignInfo.Signers {
+		if int(signer) >= len(c.Members) {
+			return fmt.Errorf("signer %v is not in the %v members of the committee of epoch %v", signer, len(c.Members), c.Epoch)
+		}
+		stake += c.Members[signer].Stake
+		keys = append(keys, c.keys[signer])
+	}
+	if stake < c.QuorumThreshold() {
+		return fmt.Errorf("signers hold %v of the %v stake of a quorum of epoch %v", stake, c.QuorumThreshold(), c.Epoch)
+	}
+	return verifyAggregate(checkpoint.SignInfo.Signature, checkpoint.SignedMessage(), keys)
+}
+
+// NextCommittee verifies the last checkpoint of the epoch and returns the committee of the next epoch it carries
+func (c *Committee) NextCommittee(checkpoint *CertifiedCheckpoint) (*Committee, error) {
+	if err := c.Verify(checkpoint); err != nil {
+		return nil, err
+	}
+	if checkpoint.Summary.EndOfEpochData == nil {
+		return nil, fmt.Errorf("checkpoint %v is not the last checkpoint of epoch %v", checkpoint.Summary.SequenceNumber, c.Epoch)
+	}
+	return NewCommittee(c.Epoch+1, checkpoint.Summary.EndOfEpochData.NextEpochCommittee)
+}
@@ -0,0 +1,212 @@
+package sui_rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	sui_client "github.com/coming-chat/go-sui/v2/client"
+	"github.com/getnimbus/ultrago/u_logger"
+	"github.com/samber/lo"
+	"golang.org/x/time/rate"
+)
+
+// LimitConfig bounds the rate and the concurrency of the calls to an endpoint, both start at their max,
+// are halved when the endpoint throttles and grow back while the calls succeed. A max of 0 disables the limit.
+type LimitConfig struct {
+	MinRate        float64 // calls per second
+	MaxRate        float64
+	MinConcurrency int
+	MaxConcurrency int
+}
+
+// rpcBackoffInterval is the minimum time between two backoffs, the calls in flight when an endpoint starts
+// to throttle fail together and must only back off once
+const rpcBackoffInterval = time.Second
+
+// rpcMinRate keeps a throttled endpoint from being stopped when MinRate is not set
+const rpcMinRate = 0.1
+
+func newRpcLimiter(config LimitConfig) *rpcLimiter {
+	l := &rpcLimiter{
+		config:      config,
+		rate:        config.MaxRate,
+		concurrency: float64(config.MaxConcurrency),
+		released:    make(chan struct{}),
+	}
+	if config.MaxRate > 0 {
+		l.limiter = rate.NewLimiter(rate.Limit(config.MaxRate), max(1, int(config.MaxRate)))
+	} else {
+		l.limiter = rate.NewLimiter(rate.Inf, 0)
+	}
+	return l
+}
+
+// rpcLimiter is a token bucket with an adaptive rate, in front of a limit of the calls in flight
+type rpcLimiter struct {
+	config  LimitConfig
+	limiter *rate.Limiter
+
+	mu          sync.Mutex
+	rate        float64
+	concurrency float64
+	inFlight    int
+	// released is closed and replaced every time a call ends, to wake up the calls waiting for a slot
+	released   chan struct{}
+	backoffAt  time.Time
+	throttling bool
+}
+
+// acquire waits for a slot and a token, release must be called after the call when it returns nil
+func (l *rpcLimiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.config.MaxConcurrency <= 0 || l.inFlight < int(l.concurrency) {
+			l.inFlight++
+			l.mu.Unlock()
+			break
+		}
+		released := l.released
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+
+	if err := l.limiter.Wait(ctx); err != nil {
+		l.mu.Lock()
+		l.free()
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// free releases a slot without adapting the limits, l.mu must be held
+func (l *rpcLimiter) free() {
+	l.inFlight--
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// release frees the slot of a call and adapts the limits to its result, calls given up by the caller
+// must only be freed as they say nothing about the endpoint
+func (l *rpcLimiter) release(ctx context.Context, err error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.free()
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	switch {
+	case isRpcThrottled(err):
+		if time.Since(l.backoffAt) < rpcBackoffInterval {
+			return
+		}
+		l.backoffAt = time.Now()
+		l.throttling = true
+		if l.config.MaxRate > 0 {
+			l.rate = max(l.config.MinRate, rpcMinRate, l.rate/2)
+			l.limiter.SetLimit(rate.Limit(l.rate))
+		}
+		if l.config.MaxConcurrency > 0 {
+			l.concurrency = max(float64(max(1, l.config.MinConcurrency)), l.concurrency/2)
+		}
+		logger.Warnf("rpc endpoint is throttling, backing off to %.2f calls/s and %v calls in flight: %v", l.rate, int(l.concurrency), err)
+	case err == nil:
+		// additive increase, the concurrency grows by one after as many successful calls
+		if l.config.MaxRate > 0 && l.rate < l.config.MaxRate {
+			l.rate = min(l.config.MaxRate, l.rate+1/max(1, l.concurrency))
+			l.limiter.SetLimit(rate.Limit(l.rate))
+		}
+		if l.config.MaxConcurrency > 0 && l.concurrency < float64(l.config.MaxConcurrency) {
+			l.concurrency = min(float64(l.config.MaxConcurrency), l.concurrency+1/l.concurrency)
+		}
+		if l.throttling && l.rate >= l.config.MaxRate && l.concurrency >= float64(l.config.MaxConcurrency) {
+			l.throttling = false
+			logger.Infof("rpc endpoint is back to %.2f calls/s and %v calls in flight", l.rate, int(l.concurrency))
+		}
+	}
+}
+
+// share returns the part of its max rate and concurrency the endpoint currently accepts, between 0 and 1
+func (l *rpcLimiter) share() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	share := 1.0
+	if l.config.MaxRate > 0 {
+		share = min(share, l.rate/l.config.MaxRate)
+	}
+	if l.config.MaxConcurrency > 0 {
+		share = min(share, l.concurrency/float64(l.config.MaxConcurrency))
+	}
+	return share
+}
+
+// limits returns the current rate and concurrency limits
+func (l *rpcLimiter) limits() (float64, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate, int(l.concurrency)
+}
+
+// rpcRateLimitCodes are the json-rpc error codes the providers answer with http 200 when the calls are rate limited
+var rpcRateLimitCodes = []int{http.StatusTooManyRequests, -32005}
+
+// rpcRateLimitMessages are the messages of the json-rpc errors of the rate limited calls, lower cased
+var rpcRateLimitMessages = []string{"rate limit", "too many requests", "limit exceeded", "exceeded the quota"}
+
+// isRpcThrottled reports whether err means the endpoint is overloaded: 429, 5xx, a timeout or a json-rpc error
+// of a rate limit
+func isRpcThrottled(err error) bool {
+	if err == nil {
+		return false
+	}
+	var httpErr sui_client.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= http.StatusInternalServerError
+	}
+	var codeErr rpcCodeError
+	if errors.As(err, &codeErr) {
+		message := strings.ToLower(err.Error())
+		return lo.Contains(rpcRateLimitCodes, codeErr.ErrorCode()) || lo.SomeBy(rpcRateLimitMessages, func(item string) bool {
+			return strings.Contains(message, item)
+		})
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// rpcRetryMaxDelay caps the delays of RetryDelay
+const rpcRetryMaxDelay = 30 * time.Second
+
+// RetryDelay makes the retries of rpc calls back off exponentially from delay, with a random jitter up to delay
+// so that the calls failing together when the endpoints throttle are not retried together
+func RetryDelay(delay time.Duration) retry.Option {
+	return func(config *retry.Config) {
+		for _, option := range []retry.Option{
+			retry.Delay(delay),
+			retry.MaxJitter(delay),
+			retry.MaxDelay(rpcRetryMaxDelay),
+			retry.DelayType(retry.CombineDelay(retry.BackOffDelay, retry.RandomDelay)),
+		} {
+			option(config)
+		}
+	}
+}
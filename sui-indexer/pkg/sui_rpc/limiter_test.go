@@ -0,0 +1,92 @@
+package sui_rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	sui_client "github.com/coming-chat/go-sui/v2/client"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func TestRpcLimiter(t *testing.T) {
+	convey.Convey("TestRpcLimiter", t, func() {
+		var (
+			ctx     = context.Background()
+			limiter = newRpcLimiter(LimitConfig{
+				MinRate:        1,
+				MaxRate:        40,
+				MinConcurrency: 1,
+				MaxConcurrency: 4,
+			})
+			throttled = fmt.Errorf("call failed: %w", sui_client.HTTPError{StatusCode: http.StatusTooManyRequests})
+		)
+
+		convey.Convey("Throttling halves the limits once per interval and successes ramp them up", func() {
+			convey.So(limiter.acquire(ctx), convey.ShouldBeNil)
+			convey.So(limiter.acquire(ctx), convey.ShouldBeNil)
+			limiter.release(ctx, throttled)
+			limiter.release(ctx, throttled)
+
+			rate, concurrency := limiter.limits()
+			convey.So(rate, convey.ShouldEqual, 20)
+			convey.So(concurrency, convey.ShouldEqual, 2)
+			convey.So(limiter.share(), convey.ShouldEqual, 0.5)
+
+			// other errors do not change the limits
+			convey.So(limiter.acquire(ctx), convey.ShouldBeNil)
+			limiter.release(ctx, errors.New("invalid params"))
+			rate, _ = limiter.limits()
+			convey.So(rate, convey.ShouldEqual, 20)
+
+			for i := 0; i < 100; i++ {
+				convey.So(limiter.acquire(ctx), convey.ShouldBeNil)
+				limiter.release(ctx, nil)
+			}
+			rate, concurrency = limiter.limits()
+			convey.So(rate, convey.ShouldEqual, 40)
+			convey.So(concurrency, convey.ShouldEqual, 4)
+		})
+
+		convey.Convey("Calls wait for a slot", func() {
+			for i := 0; i < 4; i++ {
+				convey.So(limiter.acquire(ctx), convey.ShouldBeNil)
+			}
+			timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			convey.So(limiter.acquire(timeoutCtx), convey.ShouldNotBeNil)
+
+			go limiter.release(ctx, nil)
+			convey.So(limiter.acquire(ctx), convey.ShouldBeNil)
+		})
+
+		convey.Convey("Timeouts and server errors are throttling", func() {
+			convey.So(isRpcThrottled(sui_client.HTTPError{StatusCode: http.StatusBadGateway}), convey.ShouldBeTrue)
+			convey.So(isRpcThrottled(sui_client.HTTPError{StatusCode: http.StatusBadRequest}), convey.ShouldBeFalse)
+			convey.So(isRpcThrottled(fmt.Errorf("post: %w", context.DeadlineExceeded)), convey.ShouldBeTrue)
+			convey.So(isRpcThrottled(errors.New("invalid params")), convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Json-rpc errors of a rate limit are throttling", func() {
+			convey.So(isRpcThrottled(&fakeRpcCodeError{code: http.StatusTooManyRequests, message: "Too Many Requests"}), convey.ShouldBeTrue)
+			convey.So(isRpcThrottled(&fakeRpcCodeError{code: -32000, message: "Rate limit exceeded, retry later"}), convey.ShouldBeTrue)
+			convey.So(isRpcThrottled(&fakeRpcCodeError{code: -32602, message: "Invalid params"}), convey.ShouldBeFalse)
+			convey.So(isRpcAppError(&fakeRpcCodeError{code: -32005, message: "limit exceeded"}), convey.ShouldBeFalse)
+			convey.So(isRpcAppError(&fakeRpcCodeError{code: -32602, message: "Invalid params"}), convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Retries back off exponentially", func() {
+			// 20ms then 40ms, each with a jitter up to 20ms
+			start := time.Now()
+			err := retry.Do(func() error {
+				return errors.New("boom")
+			}, retry.Attempts(3), RetryDelay(20*time.Millisecond))
+			convey.So(err, convey.ShouldNotBeNil)
+			convey.So(time.Since(start), convey.ShouldBeGreaterThanOrEqualTo, 60*time.Millisecond)
+		})
+	})
+}
@@ -0,0 +1,370 @@
+package sui_rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sui_client "github.com/coming-chat/go-sui/v2/client"
+	"github.com/getnimbus/ultrago/u_logger"
+	"github.com/samber/lo"
+	"golang.org/x/sync/errgroup"
+)
+
+// Config tunes how the endpoints of a Pool are scored
+type Config struct {
+	// FailureThreshold is the number of consecutive failures opening the circuit of an endpoint
+	FailureThreshold int
+	// Cooldown is how long an open circuit keeps the endpoint as a last resort before it is tried again
+	Cooldown time.Duration
+	// MaxLag is how many checkpoints an endpoint may be behind the highest tip of the pool before it is avoided
+	MaxLag int64
+	// TipInterval is how often the tips of the endpoints are refreshed
+	TipInterval time.Duration
+	// Limit bounds the calls to every endpoint
+	Limit LimitConfig
+}
+
+// Client is the part of sui_client.Client used by the pool
+type Client interface {
+	CallContext(ctx context.Context, result interface{}, method sui_client.Method, args ...interface{}) error
+}
+
+// Endpoints returns the comma separated endpoints of rpcs, or rpc and fallbackRpc when rpcs is empty
+func Endpoints(rpcs string, rpc string, fallbackRpc string) []string {
+	var urls = make([]string, 0)
+	for _, item := range strings.Split(rpcs, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			urls = append(urls, item)
+		}
+	}
+	if len(urls) == 0 {
+		urls = append(urls, rpc)
+		if fallbackRpc != "" {
+			urls = append(urls, fallbackRpc)
+		}
+	}
+	return lo.Uniq(urls)
+}
+
+// Dial returns a pool of the endpoints urls, their calls go through transport and time out after 2 minutes
+func Dial(urls []string, transport http.RoundTripper, config Config) (*Pool, error) {
+	var clients = make(map[string]Client, len(urls))
+	for _, rpc := range urls {
+		client, err := sui_client.DialWithClient(rpc, &http.Client{
+			Transport: transport,
+			Timeout:   2 * 60 * time.Second, // 2 mins
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial %v: %v", rpc, err)
+		}
+		clients[rpc] = client
+	}
+	return NewPool(urls, clients, config), nil
+}
+
+// NewPool returns a pool of the endpoints urls, calling them with their clients
+func NewPool(urls []string, clients map[string]Client, config Config) *Pool {
+	return &Pool{
+		endpoints: lo.Map(urls, func(rpc string, _ int) *rpcEndpoint {
+			return &rpcEndpoint{
+				url:     rpc,
+				client:  clients[rpc],
+				limiter: newRpcLimiter(config.Limit),
+			}
+		}),
+		config: config,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Pool spreads the rpc calls over several endpoints. Endpoints are picked at random weighted by their
+// latency and error rate, an endpoint failing FailureThreshold times in a row is only used as a last resort
+// for Cooldown and endpoints lagging behind the highest tip are avoided.
+type Pool struct {
+	endpoints []*rpcEndpoint
+	config    Config
+	calls     atomic.Int64
+
+	mu             sync.Mutex
+	random         *rand.Rand
+	tipRefreshedAt time.Time
+	tipRefreshing  bool
+}
+
+type rpcEndpoint struct {
+	url     string
+	client  Client
+	limiter *rpcLimiter
+
+	// the fields below are guarded by the mutex of the pool
+	latency   time.Duration // moving average of the successful calls
+	errorRate float64       // moving average of the failures, between 0 and 1
+	failures  int           // consecutive failures
+	openUntil time.Time
+	tip       int64
+}
+
+// EndpointStats is the health of an endpoint of the pool
+type EndpointStats struct {
+	Url       string
+	Latency   time.Duration
+	ErrorRate float64
+	Open      bool
+	Tip       int64
+	// Rate and Concurrency are the current limits of the calls to the endpoint
+	Rate        float64
+	Concurrency int
+}
+
+// smoothing of the moving averages of the endpoints
+const rpcEwmaAlpha = 0.2
+
+// Calls returns the number of rpc calls made so far, retries on other endpoints included
+func (p *Pool) Calls() int64 {
+	return p.calls.Load()
+}
+
+// Stats returns the health of every endpoint
+func (p *Pool) Stats() []EndpointStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	return lo.Map(p.endpoints, func(endpoint *rpcEndpoint, _ int) EndpointStats {
+		rate, concurrency := endpoint.limiter.limits()
+		return EndpointStats{
+			Url:         endpoint.url,
+			Latency:     endpoint.latency,
+			ErrorRate:   endpoint.errorRate,
+			Open:        endpoint.openUntil.After(now),
+			Tip:         endpoint.tip,
+			Rate:        rate,
+			Concurrency: concurrency,
+		}
+	})
+}
+
+// Call calls method on the endpoints in order of preference until one succeeds. minTip is the checkpoint the
+// endpoint must have, endpoints with a lower tip are only tried after the others.
+func (p *Pool) Call(ctx context.Context, minTip int64, result interface{}, method string, args ...interface{}) error {
+	p.refreshTipsInBackground(ctx)
+
+	var errs = make([]error, 0)
+	for _, endpoint := range p.order(minTip) {
+		err := p.callEndpoint(ctx, endpoint, result, method, args...)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("%v: %v", endpoint.url, err))
+	}
+	return fmt.Errorf("%v failed on all endpoints: %v", method, errors.Join(errs...))
+}
+
+// Tip asks every endpoint its latest checkpoint and returns the highest one
+func (p *Pool) Tip(ctx context.Context) (int64, error) {
+	var (
+		tips         = make([]int64, len(p.endpoints))
+		eg, childCtx = errgroup.WithContext(ctx)
+	)
+	for idx, endpoint := range p.endpoints {
+		idx, endpoint := idx, endpoint
+		eg.Go(func() error {
+			var resp string
+			if err := p.callEndpoint(childCtx, endpoint, &resp, "getLatestCheckpointSequenceNumber"); err != nil {
+				// endpoints which do not answer keep their last tip
+				return nil
+			}
+			tip, err := strconv.ParseInt(resp, 10, 64)
+			if err != nil {
+				return nil
+			}
+			p.mu.Lock()
+			endpoint.tip = tip
+			p.mu.Unlock()
+			tips[idx] = tip
+			return nil
+		})
+	}
+	_ = eg.Wait()
+
+	p.mu.Lock()
+	p.tipRefreshedAt = time.Now()
+	p.mu.Unlock()
+
+	if tip := lo.Max(tips); tip > 0 {
+		return tip, nil
+	}
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	return 0, fmt.Errorf("failed to get the latest checkpoint of all endpoints")
+}
+
+// refreshTipsInBackground refreshes the tips once they are older than TipInterval, without waiting for them
+func (p *Pool) refreshTipsInBackground(ctx context.Context) {
+	if len(p.endpoints) < 2 || p.config.TipInterval <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	if p.tipRefreshing || time.Since(p.tipRefreshedAt) < p.config.TipInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.tipRefreshing = true
+	p.mu.Unlock()
+
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			p.tipRefreshing = false
+			p.mu.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		_, _ = p.Tip(ctx)
+	}()
+}
+
+// order returns all endpoints by preference: the healthy ones shuffled by weight, then the lagging ones
+// and last the ones with an open circuit
+func (p *Pool) order(minTip int64) []*rpcEndpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var (
+		now                     = time.Now()
+		maxTip                  int64
+		healthy, lagging, opens = make([]*rpcEndpoint, 0), make([]*rpcEndpoint, 0), make([]*rpcEndpoint, 0)
+	)
+	for _, endpoint := range p.endpoints {
+		maxTip = max(maxTip, endpoint.tip)
+	}
+	for _, endpoint := range p.endpoints {
+		switch {
+		case endpoint.openUntil.After(now):
+			opens = append(opens, endpoint)
+		case endpoint.tip > 0 && (endpoint.tip < minTip || (p.config.MaxLag > 0 && maxTip-endpoint.tip > p.config.MaxLag)):
+			lagging = append(lagging, endpoint)
+		default:
+			healthy = append(healthy, endpoint)
+		}
+	}
+
+	// weighted shuffle, an endpoint is drawn before the remaining ones with a probability proportional to its weight
+	var res = make([]*rpcEndpoint, 0, len(p.endpoints))
+	for len(healthy) > 0 {
+		var (
+			weights = lo.Map(healthy, func(endpoint *rpcEndpoint, _ int) float64 { return endpoint.weight() })
+			draw    = p.random.Float64() * lo.Sum(weights)
+			idx     = 0
+		)
+		for ; idx < len(healthy)-1 && draw >= weights[idx]; idx++ {
+			draw -= weights[idx]
+		}
+		res = append(res, healthy[idx])
+		healthy = append(healthy[:idx], healthy[idx+1:]...)
+	}
+	sort.SliceStable(lagging, func(i, j int) bool {
+		return lagging[i].tip > lagging[j].tip
+	})
+	sort.SliceStable(opens, func(i, j int) bool {
+		return opens[i].openUntil.Before(opens[j].openUntil)
+	})
+	res = append(res, lagging...)
+	return append(res, opens...)
+}
+
+// weight favours the fast endpoints which rarely fail and do not throttle, endpoints without calls yet get
+// the weight of a 100ms latency
+func (e *rpcEndpoint) weight() float64 {
+	latency := e.latency
+	if latency <= 0 {
+		latency = 100 * time.Millisecond
+	}
+	latency = max(latency, 10*time.Millisecond)
+	success := 1 - e.errorRate
+	return success * success * e.limiter.share() / latency.Seconds()
+}
+
+func (p *Pool) callEndpoint(ctx context.Context, endpoint *rpcEndpoint, result interface{}, method string, args ...interface{}) error {
+	if err := endpoint.limiter.acquire(ctx); err != nil {
+		return err
+	}
+	p.calls.Add(1)
+	start := time.Now()
+	err := endpoint.client.CallContext(ctx, result, rpcMethod(method), args...)
+	endpoint.limiter.release(ctx, err)
+	if err != nil && ctx.Err() != nil {
+		// the caller gave up, the endpoint is not to blame
+		return err
+	}
+	p.record(ctx, endpoint, time.Since(start), err)
+	return err
+}
+
+// rpcMethod is a method of the sui namespace, unless it names its own namespace like suix_getCommitteeInfo
+type rpcMethod string
+
+func (m rpcMethod) String() string {
+	if strings.Contains(string(m), "_") {
+		return string(m)
+	}
+	return sui_client.SuiPrefix + string(m)
+}
+
+// record updates the health of endpoint with the result of a call, the errors answered for the call itself
+// count as a success as the endpoint did answer
+func (p *Pool) record(ctx context.Context, endpoint *rpcEndpoint, latency time.Duration, err error) {
+	ctx, logger := u_logger.GetLogger(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err == nil || isRpcAppError(err) {
+		if endpoint.latency <= 0 {
+			endpoint.latency = latency
+		} else {
+			endpoint.latency = time.Duration(rpcEwmaAlpha*float64(latency) + (1-rpcEwmaAlpha)*float64(endpoint.latency))
+		}
+		endpoint.errorRate *= 1 - rpcEwmaAlpha
+		if endpoint.failures >= p.config.FailureThreshold && p.config.FailureThreshold > 0 {
+			logger.Infof("rpc endpoint %v recovered", endpoint.url)
+		}
+		endpoint.failures = 0
+		endpoint.openUntil = time.Time{}
+		return
+	}
+
+	endpoint.errorRate = rpcEwmaAlpha + (1-rpcEwmaAlpha)*endpoint.errorRate
+	endpoint.failures++
+	if p.config.FailureThreshold > 0 && endpoint.failures >= p.config.FailureThreshold {
+		// a failed trial call after the cooldown opens the circuit again
+		endpoint.openUntil = time.Now().Add(p.config.Cooldown)
+		logger.Warnf("rpc endpoint %v failed %v times in a row, avoided for %v: %v", endpoint.url, endpoint.failures, p.config.Cooldown, err)
+	}
+}
+
+// rpcCodeError is implemented by the errors of the json-rpc responses of sui_client
+type rpcCodeError interface {
+	ErrorCode() int
+}
+
+// isRpcAppError reports whether err is a json-rpc error answered for the call itself, like an object not found or
+// invalid params. Unlike the transport errors, the http errors and the timeouts it says nothing about the endpoint.
+func isRpcAppError(err error) bool {
+	var codeErr rpcCodeError
+	return errors.As(err, &codeErr) && !isRpcThrottled(err)
+}
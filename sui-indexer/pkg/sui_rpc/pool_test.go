@@ -0,0 +1,133 @@
+package sui_rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sui_client "github.com/coming-chat/go-sui/v2/client"
+	"github.com/samber/lo"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+type fakeRpcClient struct {
+	tip   string
+	err   error
+	calls int
+}
+
+func (c *fakeRpcClient) CallContext(ctx context.Context, result interface{}, method sui_client.Method, args ...interface{}) error {
+	c.calls++
+	if c.err != nil {
+		return c.err
+	}
+	if resp, ok := result.(*string); ok {
+		*resp = c.tip
+	}
+	return nil
+}
+
+// fakeRpcCodeError is a json-rpc error response, like the ones of sui_client
+type fakeRpcCodeError struct {
+	code    int
+	message string
+}
+
+func (e *fakeRpcCodeError) Error() string {
+	return e.message
+}
+
+func (e *fakeRpcCodeError) ErrorCode() int {
+	return e.code
+}
+
+func TestRpcPool(t *testing.T) {
+	convey.Convey("TestRpcPool", t, func() {
+		var (
+			ctx     = context.Background()
+			a       = &fakeRpcClient{tip: "1000"}
+			b       = &fakeRpcClient{tip: "1000"}
+			clients = map[string]Client{"a": a, "b": b}
+			pool    = NewPool([]string{"a", "b"}, clients, Config{
+				FailureThreshold: 2,
+				Cooldown:         time.Minute,
+				MaxLag:           10,
+			})
+		)
+
+		convey.Convey("Calls fail over to the next endpoint", func() {
+			// the circuit of a opens after 2 failures, a is only tried once b failed
+			a.err = errors.New("boom")
+			for i := 0; i < 2; i++ {
+				tip, err := pool.Tip(ctx)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(tip, convey.ShouldEqual, 1000)
+			}
+			convey.So(pool.Stats()[0].Open, convey.ShouldBeTrue)
+
+			for i := 0; i < 10; i++ {
+				var resp string
+				convey.So(pool.Call(ctx, 0, &resp, "getCheckpoint", "1"), convey.ShouldBeNil)
+			}
+			convey.So(a.calls, convey.ShouldEqual, 2)
+			convey.So(b.calls, convey.ShouldEqual, 12)
+			convey.So(pool.Calls(), convey.ShouldEqual, 14)
+
+			b.err = errors.New("boom")
+			var resp string
+			convey.So(pool.Call(ctx, 0, &resp, "getCheckpoint", "1"), convey.ShouldNotBeNil)
+
+			// a successful call closes the circuit
+			a.err = nil
+			convey.So(pool.Call(ctx, 0, &resp, "getCheckpoint", "1"), convey.ShouldBeNil)
+			convey.So(pool.Stats()[0].Open, convey.ShouldBeFalse)
+		})
+
+		convey.Convey("Json-rpc errors of the call do not count as endpoint failures", func() {
+			a.err = &fakeRpcCodeError{code: -32602, message: "Could not find the referenced checkpoint"}
+			for i := 0; i < 3; i++ {
+				var resp string
+				convey.So(pool.callEndpoint(ctx, pool.endpoints[0], &resp, "getCheckpoint", "1"), convey.ShouldNotBeNil)
+			}
+			convey.So(pool.Stats()[0].Open, convey.ShouldBeFalse)
+			convey.So(pool.Stats()[0].ErrorRate, convey.ShouldEqual, 0)
+
+			a.err = errors.New("connection refused")
+			for i := 0; i < 2; i++ {
+				var resp string
+				convey.So(pool.callEndpoint(ctx, pool.endpoints[0], &resp, "getCheckpoint", "1"), convey.ShouldNotBeNil)
+			}
+			convey.So(pool.Stats()[0].Open, convey.ShouldBeTrue)
+		})
+
+		convey.Convey("Lagging endpoints are avoided", func() {
+			a.tip = "900"
+			tip, err := pool.Tip(ctx)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(tip, convey.ShouldEqual, 1000)
+
+			for i := 0; i < 10; i++ {
+				var resp string
+				convey.So(pool.Call(ctx, 0, &resp, "getCheckpoint", "1"), convey.ShouldBeNil)
+			}
+			convey.So(a.calls, convey.ShouldEqual, 1)
+			convey.So(b.calls, convey.ShouldEqual, 11)
+
+			// endpoints without the checkpoint are tried last
+			a.tip, b.tip = "1000", "995"
+			_, _ = pool.Tip(ctx)
+			convey.So(lo.Map(pool.order(998), func(endpoint *rpcEndpoint, _ int) string {
+				return endpoint.url
+			}), convey.ShouldResemble, []string{"a", "b"})
+		})
+	})
+}
+
+func TestEndpoints(t *testing.T) {
+	convey.Convey("TestEndpoints", t, func() {
+		convey.So(Endpoints(" a, b ,,a", "c", "d"), convey.ShouldResemble, []string{"a", "b"})
+		convey.So(Endpoints("", "c", "d"), convey.ShouldResemble, []string{"c", "d"})
+		convey.So(Endpoints("", "c", ""), convey.ShouldResemble, []string{"c"})
+	})
+}
@@ -0,0 +1,40 @@
+-- create parquet tables for the object and balance changes written by the s3-parquet sink
+CREATE EXTERNAL TABLE IF NOT EXISTS `final_sui_object_changes` (
+    `checkpoint` bigint,
+    `txDigest` string,
+    `timestampMs` bigint,
+    `index` int,
+    `type` string,
+    `sender` string,
+    `owner` string,
+    `ownerType` string,
+    `objectId` string,
+    `objectType` string,
+    `coinType` string,
+    `version` string,
+    `previousVersion` string,
+    `digest` string
+)
+PARTITIONED BY (`dateKey` string)
+STORED AS PARQUET
+LOCATION 's3://nimbus-sui-indexer/compressed/sui-object-changes/'
+TBLPROPERTIES ('parquet.compression' = 'SNAPPY');
+
+MSCK REPAIR TABLE final_sui_object_changes;
+
+CREATE EXTERNAL TABLE IF NOT EXISTS `final_sui_balance_changes` (
+    `checkpoint` bigint,
+    `txDigest` string,
+    `timestampMs` bigint,
+    `index` int,
+    `owner` string,
+    `ownerType` string,
+    `coinType` string,
+    `amount` string
+)
+PARTITIONED BY (`dateKey` string)
+STORED AS PARQUET
+LOCATION 's3://nimbus-sui-indexer/compressed/sui-balance-changes/'
+TBLPROPERTIES ('parquet.compression' = 'SNAPPY');
+
+MSCK REPAIR TABLE final_sui_balance_changes;
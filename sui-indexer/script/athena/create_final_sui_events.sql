@@ -0,0 +1,36 @@
+-- create parquet tables for the events and index written by the s3-parquet sink
+CREATE EXTERNAL TABLE IF NOT EXISTS `final_sui_events` (
+    `txDigest` string,
+    `eventSeq` bigint,
+    `timestampMs` bigint,
+    `checkpoint` bigint,
+    `packageId` string,
+    `transactionModule` string,
+    `sender` string,
+    `type` string,
+    `bcs` string,
+    `parsedJson` string,
+    `gasUsed` string
+)
+PARTITIONED BY (`dateKey` string)
+STORED AS PARQUET
+LOCATION 's3://nimbus-sui-indexer/compressed/sui-events/'
+TBLPROPERTIES ('parquet.compression' = 'SNAPPY');
+
+MSCK REPAIR TABLE final_sui_events;
+
+CREATE EXTERNAL TABLE IF NOT EXISTS `final_sui_index` (
+    `date_key` string,
+    `checkpoint_digest` string,
+    `checkpoint_seq` bigint,
+    `tx_digest` string,
+    `event_seq` bigint,
+    `package_id` string,
+    `event_type` string
+)
+PARTITIONED BY (`dateKey` string)
+STORED AS PARQUET
+LOCATION 's3://nimbus-sui-indexer/compressed/sui-index/'
+TBLPROPERTIES ('parquet.compression' = 'SNAPPY');
+
+MSCK REPAIR TABLE final_sui_index;
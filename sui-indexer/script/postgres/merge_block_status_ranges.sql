@@ -0,0 +1,46 @@
+-- Merge consecutive single-block DONE rows of block_status into ranges,
+-- run it once after upgrading to range-based work units to shrink the table.
+BEGIN;
+
+CREATE TEMP TABLE merged_block_status ON COMMIT DROP AS
+WITH islands AS (
+    SELECT
+        id,
+        chain,
+        type,
+        block_number,
+        digest,
+        previous_digest,
+        event_count,
+        block_number - row_number() OVER (
+            PARTITION BY chain, type
+            ORDER BY block_number
+        ) AS grp
+    FROM block_status
+    WHERE status = 2 AND block_number = to_block_number
+)
+SELECT
+    (array_agg(id ORDER BY block_number))[1] AS id,
+    array_agg(id ORDER BY block_number) AS ids,
+    MAX(block_number) AS to_block_number,
+    (array_agg(previous_digest ORDER BY block_number))[1] AS previous_digest,
+    (array_agg(digest ORDER BY block_number DESC))[1] AS digest,
+    SUM(event_count) AS event_count
+FROM islands
+GROUP BY chain, type, grp
+HAVING COUNT(*) > 1;
+
+UPDATE block_status b
+SET
+    to_block_number = m.to_block_number,
+    previous_digest = m.previous_digest,
+    digest = m.digest,
+    event_count = m.event_count
+FROM merged_block_status m
+WHERE b.id = m.id;
+
+DELETE FROM block_status b
+USING merged_block_status m
+WHERE b.id = ANY(m.ids[2:]);
+
+COMMIT;
@@ -3,6 +3,7 @@ package conf
 import (
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/creasty/defaults"
 	"github.com/getnimbus/ultrago/u_logger"
@@ -45,6 +46,13 @@ type config struct {
 	SuiRpc         string `mapstructure:"SUI_RPC" default:"https://fullnode.mainnet.sui.io"`
 	FallbackSuiRpc string `mapstructure:"FALLBACK_SUI_RPC" default:"https://sui-mainnet-rpc.nodereal.io"`
 	DiscordWebhook string `mapstructure:"DISCORD_WEBHOOK" default:"-"`
+
+	// rpc pool, SUI_RPCS is a comma separated list of endpoints replacing SUI_RPC and FALLBACK_SUI_RPC
+	SuiRpcs             string        `mapstructure:"SUI_RPCS" default:"-"`
+	RpcFailureThreshold int           `mapstructure:"RPC_FAILURE_THRESHOLD" default:"5"`
+	RpcCooldown         time.Duration `mapstructure:"RPC_COOLDOWN" default:"30s"`
+	RpcMaxLag           int64         `mapstructure:"RPC_MAX_LAG" default:"50"`
+	RpcTipInterval      time.Duration `mapstructure:"RPC_TIP_INTERVAL" default:"10s"`
 }
 
 func (c *config) IsLocal() bool {
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"net/http"
+
+	"feng-sui-core/pkg/sui_rpc"
+
+	"sui-price-feed/internal/conf"
+)
+
+// NewSuiRpcPool dials the endpoints of SUI_RPCS, or SUI_RPC and FALLBACK_SUI_RPC when it is not set
+func NewSuiRpcPool() (*sui_rpc.Pool, error) {
+	return sui_rpc.Dial(sui_rpc.Endpoints(conf.Config.SuiRpcs, conf.Config.SuiRpc, conf.Config.FallbackSuiRpc), http.DefaultTransport.(*http.Transport).Clone(), sui_rpc.Config{
+		FailureThreshold: conf.Config.RpcFailureThreshold,
+		Cooldown:         conf.Config.RpcCooldown,
+		MaxLag:           conf.Config.RpcMaxLag,
+		TipInterval:      conf.Config.RpcTipInterval,
+	})
+}
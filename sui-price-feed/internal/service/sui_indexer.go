@@ -2,43 +2,26 @@ package service
 
 import (
 	"context"
-	"net/http"
-	"strconv"
-	"time"
 
-	sui_client "github.com/coming-chat/go-sui/v2/client"
-
-	"sui-price-feed/internal/conf"
+	"feng-sui-core/pkg/sui_rpc"
 )
 
 func NewSuiIndexer() (*SuiIndexer, error) {
-	client, err := sui_client.DialWithClient(conf.Config.SuiRpc, &http.Client{
-		Transport: http.DefaultTransport.(*http.Transport).Clone(),
-		Timeout:   2 * 60 * time.Second, // 2 mins
-	})
+	pool, err := NewSuiRpcPool()
 	if err != nil {
 		return nil, err
 	}
-	fallBackClient, _ := sui_client.DialWithClient(conf.Config.FallbackSuiRpc, &http.Client{
-		Transport: http.DefaultTransport.(*http.Transport).Clone(),
-		Timeout:   2 * 60 * time.Second, // 2 mins
-	})
 
 	return &SuiIndexer{
-		client:         client,
-		fallbackClient: fallBackClient,
+		pool: pool,
 	}, nil
 }
 
 type SuiIndexer struct {
-	client         *sui_client.Client
-	fallbackClient *sui_client.Client
+	pool *sui_rpc.Pool
 }
 
+// FetchLatestCheckpoint returns the highest latest checkpoint of the rpc endpoints
 func (svc *SuiIndexer) FetchLatestCheckpoint(ctx context.Context) (int64, error) {
-	checkpoint, err := svc.client.GetLatestCheckpointSequenceNumber(ctx)
-	if err != nil {
-		return -1, err
-	}
-	return strconv.ParseInt(checkpoint, 10, 64)
+	return svc.pool.Tip(ctx)
 }
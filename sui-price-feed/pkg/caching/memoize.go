@@ -7,8 +7,8 @@ import (
 	"github.com/kofalt/go-memoize"
 	"github.com/mitchellh/hashstructure/v2"
 
-	"feng-sui-core/internal/conf"
-	"feng-sui-core/pkg/util"
+	"sui-price-feed/internal/conf"
+	"sui-price-feed/pkg/util"
 )
 
 // Cache expensive calls in memory for 5 minutes, purging old entries every 10 minutes.